@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Severity 告警级别
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Alert 表示一条告警或恢复通知
+type Alert struct {
+	Key      string    `json:"key"` // 同一告警条件的唯一标识，用于去重
+	Severity Severity  `json:"severity"`
+	Title    string    `json:"title"`
+	Message  string    `json:"message"`
+	Rule     string    `json:"rule,omitempty"`     // 产生告警的规则名，只有规则告警可以被屏蔽
+	Channels []int     `json:"channels,omitempty"` // 受影响的渠道
+	StartsAt time.Time `json:"starts_at"`
+	Resolved bool      `json:"resolved"`
+}
+
+// Notifier 负责把告警发送出去
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// LogNotifier 把告警写入日志，始终启用
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, alert Alert) error {
+	if alert.Resolved {
+		slog.InfoContext(ctx, "告警恢复", "key", alert.Key, "title", alert.Title)
+		return nil
+	}
+	slog.WarnContext(ctx, "告警", "severity", alert.Severity, "key", alert.Key, "title", alert.Title, "message", alert.Message)
+	return nil
+}
+
+// WebhookNotifier 以 JSON 格式把告警 POST 到指定地址
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier 创建 webhook 通知器
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook 返回 %s", resp.Status)
+	}
+	return nil
+}
+
+// multiNotifier 依次调用多个通知器，单个失败不影响其他通知器
+type multiNotifier []Notifier
+
+func (m multiNotifier) Notify(ctx context.Context, alert Alert) error {
+	var firstErr error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, alert); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// newNotifier 根据配置组合通知器，日志通知器始终启用，email 为 nil 时不发送邮件
+func newNotifier(cfg *Config, email *EmailNotifier) Notifier {
+	notifiers := multiNotifier{LogNotifier{}}
+	if cfg.AlertWebhookURL != "" {
+		notifiers = append(notifiers, NewWebhookNotifier(cfg.AlertWebhookURL))
+	}
+	if email != nil {
+		notifiers = append(notifiers, email)
+	}
+	return notifiers
+}
+
+// Alerter 记录正在触发的告警，只在条件开始成立和恢复时各通知一次。
+// 被屏蔽的告警不发送通知；屏蔽到期时告警仍在触发则补发，恢复通知只发给已通知过的告警
+type Alerter struct {
+	notifier Notifier
+	silences *SilenceStore
+
+	mu     sync.Mutex
+	firing map[string]firingAlert
+}
+
+// firingAlert 正在触发的告警以及是否已经发送过通知
+type firingAlert struct {
+	Alert
+	notified bool
+}
+
+// NewAlerter 创建告警状态管理器
+func NewAlerter(notifier Notifier) *Alerter {
+	return &Alerter{notifier: notifier, firing: make(map[string]firingAlert)}
+}
+
+// SetSilences 设置屏蔽规则，未设置时所有告警都会通知
+func (a *Alerter) SetSilences(silences *SilenceStore) {
+	a.silences = silences
+}
+
+// Silenced 判断告警当前是否被屏蔽
+func (a *Alerter) Silenced(alert Alert) bool {
+	return a.silences != nil && a.silences.Silenced(alert, time.Now())
+}
+
+// Update 根据条件是否成立更新 alert.Key 对应的告警：由不成立变为成立时发送告警，恢复时发送恢复通知
+func (a *Alerter) Update(ctx context.Context, active bool, alert Alert) {
+	a.mu.Lock()
+	previous, wasFiring := a.firing[alert.Key]
+	switch {
+	case active && !wasFiring:
+		if alert.StartsAt.IsZero() {
+			alert.StartsAt = time.Now()
+		}
+		notify := !a.Silenced(alert)
+		a.firing[alert.Key] = firingAlert{Alert: alert, notified: notify}
+		if !notify {
+			a.mu.Unlock()
+			return
+		}
+	case !active && wasFiring:
+		delete(a.firing, alert.Key)
+		if !previous.notified {
+			a.mu.Unlock()
+			return
+		}
+		alert = previous.Alert
+		alert.Resolved = true
+	case active:
+		// 持续触发时只更新内容，保留开始时间；之前被屏蔽而现在屏蔽已到期时补发通知
+		alert.StartsAt = previous.StartsAt
+		notify := !previous.notified && !a.Silenced(alert)
+		a.firing[alert.Key] = firingAlert{Alert: alert, notified: previous.notified || notify}
+		if !notify {
+			a.mu.Unlock()
+			return
+		}
+	default:
+		a.mu.Unlock()
+		return
+	}
+	a.mu.Unlock()
+
+	if err := a.notifier.Notify(ctx, alert); err != nil {
+		slog.ErrorContext(ctx, "发送告警失败", "key", alert.Key, "err", err)
+	}
+}
+
+// Firing 返回正在触发的告警，按开始时间排序
+func (a *Alerter) Firing() []Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	alerts := make([]Alert, 0, len(a.firing))
+	for _, firing := range a.firing {
+		alerts = append(alerts, firing.Alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].StartsAt.Equal(alerts[j].StartsAt) {
+			return alerts[i].Key < alerts[j].Key
+		}
+		return alerts[i].StartsAt.Before(alerts[j].StartsAt)
+	})
+	return alerts
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AlertEmailConfig 告警邮件的收件人和合并发送的时间窗口
+type AlertEmailConfig struct {
+	Recipients  map[Severity][]string // 每个级别的收件人，没有收件人的级别不发送邮件
+	BatchWindow time.Duration         // 第一条告警到达后等待多久再发送，期间到达的告警合并为一封
+}
+
+// Enabled 判断是否有任何级别配置了收件人
+func (c AlertEmailConfig) Enabled() bool {
+	for _, to := range c.Recipients {
+		if len(to) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// severityRank 告警级别的严重程度，越严重越大
+func severityRank(s Severity) int {
+	switch s {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// alertEmail 一封告警邮件的内容
+type alertEmail struct {
+	Alerts   []Alert       // 按严重程度从高到低排序
+	Channels []ChannelView // 告警涉及的渠道，有采集结果时取自最近一次采集
+	Missing  []int         // 告警涉及但采集结果中没有的渠道
+	Summary  *Snapshot     // 最近一次的采集结果，尚未采集时为 nil
+}
+
+// newAlertEmail 整理一批告警，并从采集结果中找出涉及的渠道
+func newAlertEmail(alerts []Alert, snapshot *Snapshot) alertEmail {
+	email := alertEmail{Alerts: append([]Alert(nil), alerts...), Summary: snapshot}
+	sort.SliceStable(email.Alerts, func(i, j int) bool {
+		return severityRank(email.Alerts[i].Severity) > severityRank(email.Alerts[j].Severity)
+	})
+
+	seen := make(map[int]bool)
+	var ids []int
+	for _, alert := range email.Alerts {
+		for _, id := range alert.Channels {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Ints(ids)
+	views := make(map[int]ChannelView)
+	if snapshot != nil {
+		for _, view := range snapshot.Channels {
+			views[view.ID] = view
+		}
+	}
+	for _, id := range ids {
+		if view, ok := views[id]; ok {
+			email.Channels = append(email.Channels, view)
+		} else {
+			email.Missing = append(email.Missing, id)
+		}
+	}
+	return email
+}
+
+// alertStatus 返回告警在邮件中的状态文字
+func alertStatus(alert Alert) string {
+	if alert.Resolved {
+		return "已恢复"
+	}
+	return "触发"
+}
+
+// Subject 返回邮件主题，多条告警时以最严重的一条为代表
+func (e alertEmail) Subject() string {
+	first := e.Alerts[0]
+	if len(e.Alerts) == 1 {
+		return fmt.Sprintf("[%s] %s：%s", first.Severity, alertStatus(first), first.Title)
+	}
+	return fmt.Sprintf("[%s] %d 条告警：%s 等", first.Severity, len(e.Alerts), first.Title)
+}
+
+// Text 返回邮件的纯文本正文
+func (e alertEmail) Text() string {
+	var b strings.Builder
+	for _, alert := range e.Alerts {
+		fmt.Fprintf(&b, "[%s] %s：%s\n", alert.Severity, alertStatus(alert), alert.Title)
+		if alert.Message != "" {
+			fmt.Fprintf(&b, "  %s\n", alert.Message)
+		}
+		fmt.Fprintf(&b, "  开始于 %s\n", alert.StartsAt.Format("2006-01-02 15:04:05"))
+		if len(alert.Channels) > 0 {
+			ids := make([]string, len(alert.Channels))
+			for i, id := range alert.Channels {
+				ids[i] = fmt.Sprintf("#%d", id)
+			}
+			fmt.Fprintf(&b, "  渠道 %s\n", strings.Join(ids, "、"))
+		}
+		b.WriteString("\n")
+	}
+	if len(e.Channels) > 0 || len(e.Missing) > 0 {
+		b.WriteString("受影响的渠道：\n")
+		for _, view := range e.Channels {
+			status := view.StatusDisplay
+			if view.IsCoolingDown {
+				status += "（" + view.CooldownReason + "）"
+			}
+			fmt.Fprintf(&b, "  #%d %s %s，天使用 %d/%d\n", view.ID, view.TagDisplay, status, view.CountDayUsage, view.DayLimit)
+		}
+		for _, id := range e.Missing {
+			fmt.Fprintf(&b, "  #%d\n", id)
+		}
+		b.WriteString("\n")
+	}
+	if s := e.Summary; s != nil {
+		fmt.Fprintf(&b, "总使用情况（%s）：\n", s.CollectedAt.Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(&b, "  %s：%d/%d（%.1f%%）\n", s.MinuteWindowLabel, s.Summary.TotalMinuteUsage, s.Summary.TotalMinuteLimit, s.Summary.MinutePercentage)
+		fmt.Fprintf(&b, "  %s：%d/%d（%.1f%%）\n", s.DayWindowLabel, s.Summary.TotalDayUsage, s.Summary.TotalDayLimit, s.Summary.DayPercentage)
+		fmt.Fprintf(&b, "  自动禁用普号：%d/%d（%.1f%%）\n", s.Summary.DisabledNormalChannels, s.Summary.TotalNormalChannels, s.Summary.DisabledNormalPercentage)
+	}
+	return b.String()
+}
+
+// EmailNotifier 通过邮件发送告警，BatchWindow 内到达的告警合并为一封，
+// 每个收件人只收到其订阅的级别的告警
+type EmailNotifier struct {
+	mailer   *Mailer
+	renderer *Renderer
+	config   AlertEmailConfig
+	snapshot func() *Snapshot // 返回最近一次的采集结果，可以为 nil
+
+	mu      sync.Mutex
+	pending []Alert
+	timer   *time.Timer
+}
+
+// NewEmailNotifier 创建邮件通知器，snapshot 用于在邮件中附上渠道状态和总使用情况
+func NewEmailNotifier(mailer *Mailer, renderer *Renderer, config AlertEmailConfig, snapshot func() *Snapshot) *EmailNotifier {
+	return &EmailNotifier{mailer: mailer, renderer: renderer, config: config, snapshot: snapshot}
+}
+
+// newEmailNotifier 根据配置创建邮件通知器，未配置 SMTP 或收件人时返回 nil
+func newEmailNotifier(cfg *Config, renderer *Renderer, snapshot func() *Snapshot) *EmailNotifier {
+	mailer := newMailer(cfg)
+	if mailer == nil || !cfg.AlertEmail.Enabled() {
+		return nil
+	}
+	return NewEmailNotifier(mailer, renderer, cfg.AlertEmail, snapshot)
+}
+
+// Notify 把告警加入等待发送的队列，BatchWindow 后统一发送
+func (n *EmailNotifier) Notify(ctx context.Context, alert Alert) error {
+	if len(n.config.Recipients[alert.Severity]) == 0 {
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.pending = append(n.pending, alert)
+	if n.timer == nil {
+		n.timer = time.AfterFunc(n.config.BatchWindow, func() {
+			if err := n.Flush(context.Background()); err != nil {
+				slog.Error("发送告警邮件失败", "err", err)
+			}
+		})
+	}
+	return nil
+}
+
+// Flush 立即发送所有等待中的告警，退出前调用以免丢失
+func (n *EmailNotifier) Flush(ctx context.Context) error {
+	n.mu.Lock()
+	pending := n.pending
+	n.pending = nil
+	if n.timer != nil {
+		n.timer.Stop()
+		n.timer = nil
+	}
+	n.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// 先找出每个收件人应收到的告警，再把收到相同告警的收件人合并为一封邮件
+	received := make(map[string][]int)
+	for i, alert := range pending {
+		for _, to := range n.config.Recipients[alert.Severity] {
+			received[to] = append(received[to], i)
+		}
+	}
+	groups := make(map[string][]string)
+	for to, indexes := range received {
+		key := fmt.Sprint(indexes)
+		groups[key] = append(groups[key], to)
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		sort.Strings(groups[key])
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var snapshot *Snapshot
+	if n.snapshot != nil {
+		snapshot = n.snapshot()
+	}
+	var firstErr error
+	for _, key := range keys {
+		to := groups[key]
+		var alerts []Alert
+		for _, i := range received[to[0]] {
+			alerts = append(alerts, pending[i])
+		}
+		if err := n.send(ctx, to, newAlertEmail(alerts, snapshot)); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("发送给 %s 失败: %w", strings.Join(to, ","), err)
+		}
+	}
+	return firstErr
+}
+
+func (n *EmailNotifier) send(ctx context.Context, to []string, email alertEmail) error {
+	var html bytes.Buffer
+	if err := n.renderer.Render(&html, "alert_email.html", email); err != nil {
+		return err
+	}
+	return n.mailer.Send(ctx, to, email.Subject(), email.Text(), html.String())
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailNotifierBatchesBySeverity(t *testing.T) {
+	mailer, messages := newStandInMailer(t, SMTPPlain)
+	renderer, err := NewRenderer(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := AlertEmailConfig{
+		Recipients: map[Severity][]string{
+			SeverityCritical: {"oncall@example.com", "lead@example.com"},
+			SeverityWarning:  {"lead@example.com"},
+		},
+		BatchWindow: 50 * time.Millisecond,
+	}
+	snapshot := buildSnapshot([]Channel{{ID: 1, Status: "1", Tag: "gcp"}, {ID: 2, Status: "3"}}, nil, testQuota, testNow)
+	notifier := NewEmailNotifier(mailer, renderer, config, func() *Snapshot { return snapshot })
+
+	ctx := context.Background()
+	alerts := []Alert{
+		{Key: "a", Severity: SeverityWarning, Title: "渠道接近天限制", Channels: []int{2}, StartsAt: testNow},
+		{Key: "b", Severity: SeverityCritical, Title: "付费号被禁用", Channels: []int{1}, StartsAt: testNow},
+		{Key: "c", Severity: SeverityCritical, Title: "渠道不存在", Channels: []int{9}, StartsAt: testNow},
+		// 没有收件人的级别不发送
+		{Key: "d", Severity: SeverityInfo, Title: "提示", StartsAt: testNow},
+	}
+	for _, alert := range alerts {
+		if err := notifier.Notify(ctx, alert); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 一批告警按收件人合并：lead 收到全部 3 条，oncall 只收到 2 条严重告警
+	received := make(map[string]smtpMessage)
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-messages:
+			sort.Strings(msg.To)
+			received[strings.Join(msg.To, ",")] = msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("没有收到合并后的告警邮件")
+		}
+	}
+	lead, oncall := received["lead@example.com"], received["oncall@example.com"]
+	if lead.Data == "" || oncall.Data == "" {
+		t.Fatalf("收件人 = %v", received)
+	}
+	if !strings.Contains(lead.Data, "[warning] 触发：渠道接近天限制") || !strings.Contains(lead.Data, "<td>#2</td><td>普号</td>") {
+		t.Errorf("lead 的邮件缺少警告或受影响的渠道:\n%s", lead.Data)
+	}
+	if strings.Contains(oncall.Data, "渠道接近天限制") || !strings.Contains(oncall.Data, "#1 付费号") ||
+		!strings.Contains(oncall.Data, "<td>#9</td><td colspan=\"4\" class=\"capacity-note\">没有采集数据</td>") {
+		t.Errorf("oncall 的邮件内容不正确:\n%s", oncall.Data)
+	}
+	if !strings.Contains(oncall.Data, "自动禁用普号：1/1") {
+		t.Errorf("邮件中缺少总使用情况:\n%s", oncall.Data)
+	}
+
+	// 没有等待中的告警时 Flush 不发送
+	if err := notifier.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-messages:
+		t.Errorf("不应再发送邮件: %+v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestAlertEmailSubject(t *testing.T) {
+	single := newAlertEmail([]Alert{{Severity: SeverityWarning, Title: "延迟偏高", Resolved: true}}, nil)
+	if got := single.Subject(); got != "[warning] 已恢复：延迟偏高" {
+		t.Errorf("Subject() = %q", got)
+	}
+	batch := newAlertEmail([]Alert{
+		{Severity: SeverityInfo, Title: "提示"},
+		{Severity: SeverityCritical, Title: "超出预算"},
+	}, nil)
+	if got := batch.Subject(); got != "[critical] 2 条告警：超出预算 等" {
+		t.Errorf("Subject() = %q", got)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAlerterNotifiesOnTransitions(t *testing.T) {
+	notifier := &recordingNotifier{}
+	alerter := NewAlerter(notifier)
+	ctx := context.Background()
+	alert := Alert{Key: "test", Severity: SeverityWarning, Title: "测试"}
+
+	alerter.Update(ctx, false, alert)
+	alerter.Update(ctx, true, alert)
+	alerter.Update(ctx, true, Alert{Key: "test", Severity: SeverityWarning, Title: "测试", Message: "更新"})
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Resolved {
+		t.Fatalf("持续触发只应通知一次: %+v", notifier.alerts)
+	}
+	if firing := alerter.Firing(); len(firing) != 1 || firing[0].Message != "更新" || !firing[0].StartsAt.Equal(notifier.alerts[0].StartsAt) {
+		t.Errorf("Firing() = %+v", firing)
+	}
+
+	alerter.Update(ctx, false, alert)
+	if len(notifier.alerts) != 2 || !notifier.alerts[1].Resolved {
+		t.Fatalf("恢复时应发送恢复通知: %+v", notifier.alerts)
+	}
+	if len(alerter.Firing()) != 0 {
+		t.Error("恢复后不应再有触发中的告警")
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received Alert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	alert := Alert{Key: "budget:daily", Severity: SeverityCritical, Title: "超出预算"}
+	if err := NewWebhookNotifier(server.URL).Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	if received.Key != alert.Key || received.Severity != alert.Severity {
+		t.Errorf("received = %+v", received)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	if err := NewWebhookNotifier(failing.URL).Notify(context.Background(), alert); err == nil {
+		t.Error("webhook 返回 502 时应当返回错误")
+	}
+}
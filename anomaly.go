@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AnomalyConfig 请求量异常检测的配置
+type AnomalyConfig struct {
+	BaselineDays int     // 基线使用之前多少天同一时段的请求量，为 0 时不检测
+	Alpha        float64 // 按天计算 EWMA 的平滑系数，越大越看重最近几天
+	SpikeFactor  float64 // 请求量超过基线的多少倍视为突增
+	DropFactor   float64 // 请求量低于基线的多少倍视为骤降
+	MinExpected  float64 // 按基线窗口内应有的请求数少于该值时不判断，避免请求很少时误报
+	Interval     time.Duration
+}
+
+// validate 检查配置的取值范围
+func (c AnomalyConfig) validate() error {
+	switch {
+	case c.BaselineDays < 0:
+		return fmt.Errorf("ANOMALY_BASELINE_DAYS 不能为负数: %d", c.BaselineDays)
+	case c.Alpha <= 0 || c.Alpha > 1:
+		return fmt.Errorf("ANOMALY_EWMA_ALPHA 需要在 (0, 1] 之间: %g", c.Alpha)
+	case c.SpikeFactor <= 1:
+		return fmt.Errorf("ANOMALY_SPIKE_FACTOR 需要大于 1: %g", c.SpikeFactor)
+	case c.DropFactor < 0 || c.DropFactor >= 1:
+		return fmt.Errorf("ANOMALY_DROP_FACTOR 需要在 [0, 1) 之间: %g", c.DropFactor)
+	}
+	return nil
+}
+
+// 异常的类型和比较的窗口
+const (
+	AnomalySpike = "spike"
+	AnomalyDrop  = "drop"
+
+	AnomalyScopeMinute = "minute" // 最近 5 分钟，用于尽快发现流量归零
+	AnomalyScopeHour   = "hour"   // 最近 1 小时
+)
+
+// anomalyScopes 每种窗口的长度，基线按长度从每小时请求量折算
+var anomalyScopes = []struct {
+	scope  string
+	length time.Duration
+}{
+	{AnomalyScopeMinute, 5 * time.Minute},
+	{AnomalyScopeHour, time.Hour},
+}
+
+// anomalyKindNames、anomalyScopeNames 中文显示名，同时是翻译的原文
+var (
+	anomalyKindNames  = map[string]string{AnomalySpike: "突增", AnomalyDrop: "骤降"}
+	anomalyScopeNames = map[string]string{AnomalyScopeMinute: "最近5分钟", AnomalyScopeHour: "最近1小时"}
+)
+
+// Anomaly 表示一个渠道或整个渠道池的请求量偏离基线
+type Anomaly struct {
+	ChannelID int     `json:"channel_id"` // 0 表示整个渠道池
+	Kind      string  `json:"kind"`       // spike 或 drop
+	Scope     string  `json:"scope"`      // minute 或 hour
+	Current   int     `json:"current"`    // 窗口内的请求数
+	Expected  float64 `json:"expected"`   // 按基线窗口内应有的请求数
+}
+
+// KindDisplay 返回异常类型的中文显示名
+func (a Anomaly) KindDisplay() string {
+	return anomalyKindNames[a.Kind]
+}
+
+// ScopeDisplay 返回比较窗口的中文显示名
+func (a Anomaly) ScopeDisplay() string {
+	return anomalyScopeNames[a.Scope]
+}
+
+// alertKey 返回异常对应的告警 key
+func (a Anomaly) alertKey() string {
+	if a.ChannelID == 0 {
+		return fmt.Sprintf("anomaly:%s:%s:pool", a.Kind, a.Scope)
+	}
+	return fmt.Sprintf("anomaly:%s:%s:%d", a.Kind, a.Scope, a.ChannelID)
+}
+
+// VolumeBaseline 渠道或渠道池最近 1 小时的请求数和基线
+type VolumeBaseline struct {
+	ChannelID int     `json:"channel_id"` // 0 表示整个渠道池
+	Current   int     `json:"current"`
+	Baseline  float64 `json:"baseline"` // 之前几天同一时段每小时请求数的 EWMA
+}
+
+// AnomalyReport 一次异常检测的结果
+type AnomalyReport struct {
+	GeneratedAt  time.Time        `json:"generated_at"`
+	BaselineDays int              `json:"baseline_days"`
+	Pool         VolumeBaseline   `json:"pool"`
+	Channels     []VolumeBaseline `json:"channels"`
+	Anomalies    []Anomaly        `json:"anomalies"`
+}
+
+// ewma 按从旧到新的顺序计算指数加权移动平均
+func ewma(samples []float64, alpha float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	average := samples[0]
+	for _, sample := range samples[1:] {
+		average = alpha*sample + (1-alpha)*average
+	}
+	return average
+}
+
+// detectVolume 比较一个渠道（或渠道池）最近一小时每分钟的请求数和每小时基线，checkDrop 为 false 时只检查突增
+func detectVolume(channelID int, minutes []int, baseline float64, checkDrop bool, config AnomalyConfig) []Anomaly {
+	var anomalies []Anomaly
+	for _, s := range anomalyScopes {
+		n := min(int(s.length/time.Minute), len(minutes))
+		current := 0
+		for _, count := range minutes[len(minutes)-n:] {
+			current += count
+		}
+		expected := baseline * float64(s.length) / float64(time.Hour)
+		if expected < config.MinExpected {
+			continue
+		}
+		anomaly := Anomaly{ChannelID: channelID, Scope: s.scope, Current: current, Expected: expected}
+		switch {
+		case float64(current) > expected*config.SpikeFactor:
+			anomaly.Kind = AnomalySpike
+		case checkDrop && float64(current) < expected*config.DropFactor:
+			anomaly.Kind = AnomalyDrop
+		default:
+			continue
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+	return anomalies
+}
+
+// buildAnomalyReport 根据最近一小时按分钟分桶的请求数和之前每天同一小时的请求数（从旧到新）检测异常。
+// 不可用的渠道请求量下降是预期的，只检查突增
+func buildAnomalyReport(recent []ActivityCounts, since time.Time, history []map[int]int, channels []Channel, config AnomalyConfig, now time.Time) *AnomalyReport {
+	minutes := minuteBuckets(recent, since)
+	baselineOf := func(id int) float64 {
+		samples := make([]float64, len(history))
+		for i, day := range history {
+			samples[i] = float64(day[id])
+		}
+		return ewma(samples, config.Alpha)
+	}
+
+	report := &AnomalyReport{GeneratedAt: now, BaselineDays: config.BaselineDays}
+	poolBaseline := baselineOf(0)
+	report.Pool = VolumeBaseline{Current: sumInts(minutes[0]), Baseline: poolBaseline}
+	report.Anomalies = detectVolume(0, minutes[0], poolBaseline, true, config)
+
+	sorted := slices.Clone(channels)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	for _, channel := range sorted {
+		recentMinutes := minutes[channel.ID]
+		if recentMinutes == nil {
+			recentMinutes = make([]int, len(minutes[0]))
+		}
+		baseline := baselineOf(channel.ID)
+		report.Channels = append(report.Channels, VolumeBaseline{ChannelID: channel.ID, Current: sumInts(recentMinutes), Baseline: baseline})
+		report.Anomalies = append(report.Anomalies, detectVolume(channel.ID, recentMinutes, baseline, channel.Status == "1", config)...)
+	}
+	return report
+}
+
+// sumInts 返回所有元素之和
+func sumInts(values []int) int {
+	total := 0
+	for _, value := range values {
+		total += value
+	}
+	return total
+}
+
+// applyAnomalies 把异常填入面板数据，整个渠道池的异常用于页面顶部的提示
+func applyAnomalies(snapshot *Snapshot, report *AnomalyReport) {
+	byChannel := make(map[int][]Anomaly)
+	for _, anomaly := range report.Anomalies {
+		if anomaly.ChannelID == 0 {
+			snapshot.PoolAnomalies = append(snapshot.PoolAnomalies, anomaly)
+			continue
+		}
+		byChannel[anomaly.ChannelID] = append(byChannel[anomaly.ChannelID], anomaly)
+	}
+	for i := range snapshot.Channels {
+		snapshot.Channels[i].Anomalies = byChannel[snapshot.Channels[i].ID]
+	}
+}
+
+// AnomalyDetector 在后台定期把请求量与之前几天同一时段的基线比较，偏离时告警
+type AnomalyDetector struct {
+	store    Store
+	location *time.Location
+	config   AnomalyConfig
+	alerter  *Alerter
+
+	mu     sync.RWMutex
+	report *AnomalyReport
+	firing map[string]bool // 上一次检测中触发告警的 key，用于发送恢复通知
+}
+
+// NewAnomalyDetector 创建异常检测器
+func NewAnomalyDetector(store Store, location *time.Location, config AnomalyConfig, alerter *Alerter) *AnomalyDetector {
+	return &AnomalyDetector{store: store, location: location, config: config, alerter: alerter, firing: make(map[string]bool)}
+}
+
+// Run 立即检测一次，然后按间隔循环检测，直到 ctx 被取消；BaselineDays 为 0 时不检测
+func (d *AnomalyDetector) Run(ctx context.Context) {
+	if d.config.BaselineDays == 0 {
+		return
+	}
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.Refresh(ctx); err != nil {
+			slog.ErrorContext(ctx, "检测请求量异常失败", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh 立即检测一次，并根据结果发送或恢复告警
+func (d *AnomalyDetector) Refresh(ctx context.Context) (*AnomalyReport, error) {
+	return d.detect(ctx, time.Now().In(d.location))
+}
+
+// detect 统计 now 之前一小时的请求量和之前每天同一时段的基线
+func (d *AnomalyDetector) detect(ctx context.Context, now time.Time) (*AnomalyReport, error) {
+	since := now.Add(-trendSpan)
+	recent, err := d.store.Activity(ctx, since, now, trendBucket)
+	if err != nil {
+		return nil, fmt.Errorf("统计最近一小时的请求数失败: %w", err)
+	}
+	history := make([]map[int]int, d.config.BaselineDays)
+	for day := 1; day <= d.config.BaselineDays; day++ {
+		end := now.AddDate(0, 0, -day)
+		activity, err := d.store.Activity(ctx, end.Add(-time.Hour), end, time.Hour)
+		if err != nil {
+			return nil, fmt.Errorf("统计 %d 天前同一时段的请求数失败: %w", day, err)
+		}
+		// key 0 为所有渠道之和，与 minuteBuckets 一致
+		counts := make(map[int]int)
+		for _, item := range activity {
+			if item.ChannelID != 0 {
+				counts[item.ChannelID] += item.Requests
+			}
+			counts[0] += item.Requests
+		}
+		history[d.config.BaselineDays-day] = counts
+	}
+	channels, err := d.store.Channels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询渠道失败: %w", err)
+	}
+	report := buildAnomalyReport(recent, since, history, channels, d.config, now)
+
+	d.mu.Lock()
+	d.report = report
+	d.mu.Unlock()
+
+	d.notify(ctx, report)
+	return report, nil
+}
+
+// notify 为每个异常发送告警，上一次存在而这一次消失的异常发送恢复通知
+func (d *AnomalyDetector) notify(ctx context.Context, report *AnomalyReport) {
+	firing := make(map[string]bool)
+	for _, anomaly := range report.Anomalies {
+		key := anomaly.alertKey()
+		firing[key] = true
+		alert := Alert{
+			Key:      key,
+			Severity: SeverityWarning,
+			Title:    "渠道请求量" + anomaly.KindDisplay(),
+			Message: fmt.Sprintf("渠道 #%d %s请求 %d 次，按之前 %d 天同一时段的基线应为 %.0f 次",
+				anomaly.ChannelID, anomaly.ScopeDisplay(), anomaly.Current, report.BaselineDays, anomaly.Expected),
+			Channels: []int{anomaly.ChannelID},
+		}
+		if anomaly.ChannelID == 0 {
+			alert.Title = "整体请求量" + anomaly.KindDisplay()
+			alert.Message = fmt.Sprintf("所有渠道%s共请求 %d 次，按之前 %d 天同一时段的基线应为 %.0f 次",
+				anomaly.ScopeDisplay(), anomaly.Current, report.BaselineDays, anomaly.Expected)
+			alert.Channels = nil
+			// 整体请求量骤降通常意味着 newapi 出现故障
+			if anomaly.Kind == AnomalyDrop {
+				alert.Severity = SeverityCritical
+			}
+		}
+		d.alerter.Update(ctx, true, alert)
+	}
+
+	var resolved []string
+	for key := range d.firing {
+		if !firing[key] {
+			resolved = append(resolved, key)
+		}
+	}
+	sort.Strings(resolved)
+	for _, key := range resolved {
+		d.alerter.Update(ctx, false, Alert{Key: key})
+	}
+	d.firing = firing
+}
+
+// Latest 返回最近一次的检测结果，尚未检测成功时返回 nil
+func (d *AnomalyDetector) Latest() *AnomalyReport {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.report
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func anomalyTestConfig() AnomalyConfig {
+	return AnomalyConfig{BaselineDays: 3, Alpha: 0.5, SpikeFactor: 3, DropFactor: 0.3, MinExpected: 20, Interval: time.Minute}
+}
+
+func TestEWMA(t *testing.T) {
+	tests := []struct {
+		samples []float64
+		alpha   float64
+		want    float64
+	}{
+		{nil, 0.5, 0},
+		{[]float64{100}, 0.5, 100},
+		{[]float64{100, 200}, 0.5, 150},
+		{[]float64{100, 200, 300}, 0.5, 225},
+		{[]float64{100, 200, 300}, 1, 300},
+	}
+	for _, tt := range tests {
+		if got := ewma(tt.samples, tt.alpha); !closeTo(got, tt.want) {
+			t.Errorf("ewma(%v, %g) = %g, want %g", tt.samples, tt.alpha, got, tt.want)
+		}
+	}
+}
+
+func TestDetectVolume(t *testing.T) {
+	config := anomalyTestConfig()
+	tests := []struct {
+		name      string
+		minutes   []int
+		baseline  float64
+		checkDrop bool
+		want      []string // kind:scope
+	}{
+		{"正常", repeat(5, 60), 300, true, nil},
+		{"流量归零", make([]int, 60), 300, true, []string{"drop:minute", "drop:hour"}},
+		{"最近 5 分钟归零", append(repeat(5, 55), 0, 0, 0, 0, 0), 300, true, []string{"drop:minute"}},
+		{"不可用渠道只检查突增", make([]int, 60), 300, false, nil},
+		{"突增", append(repeat(5, 55), 50, 50, 50, 50, 50), 300, true, []string{"spike:minute"}},
+		// 基线每小时 60 次时，5 分钟窗口只应有 5 次，样本太少不判断
+		{"样本太少", make([]int, 60), 60, true, []string{"drop:hour"}},
+		{"没有基线", repeat(5, 60), 0, true, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, anomaly := range detectVolume(1, tt.minutes, tt.baseline, tt.checkDrop, config) {
+			got = append(got, anomaly.Kind+":"+anomaly.Scope)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestAnomalyDetectorAlerts(t *testing.T) {
+	now := testNow
+	// 之前 3 天同一时段：渠道 1 每天 120 次，渠道 2 每天 240 次
+	var logs []LogEntry
+	for day := 1; day <= 3; day++ {
+		end := now.AddDate(0, 0, -day).Unix()
+		for i := 0; i < 120; i++ {
+			logs = append(logs, LogEntry{ChannelID: 1, CreatedAt: end - int64(i*30) - 1})
+		}
+		for i := 0; i < 240; i++ {
+			logs = append(logs, LogEntry{ChannelID: 2, CreatedAt: end - int64(i*15) - 1})
+		}
+	}
+	history := len(logs)
+	// 最近一小时渠道 1 正常（5 分钟内只应有 10 次，样本太少不按分钟判断），渠道 2 只在最后 5 分钟有 100 次
+	for i := 0; i < 120; i++ {
+		logs = append(logs, LogEntry{ChannelID: 1, CreatedAt: now.Unix() - int64(i*30) - 1})
+	}
+	for i := 0; i < 100; i++ {
+		logs = append(logs, LogEntry{ChannelID: 2, CreatedAt: now.Unix() - int64(i%300) - 1})
+	}
+	store := NewMemoryStore([]Channel{{ID: 1, Status: "1"}, {ID: 2, Status: "1"}}, logs)
+	notifier := &recordingNotifier{}
+	detector := NewAnomalyDetector(store, time.UTC, anomalyTestConfig(), NewAlerter(notifier))
+	ctx := context.Background()
+
+	report, err := detector.detect(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Pool.Baseline != 360 || report.Pool.Current != 220 {
+		t.Errorf("pool = %+v, want current 220, baseline 360", report.Pool)
+	}
+	if len(notifier.alerts) != 2 || notifier.alerts[0].Key != "anomaly:spike:minute:pool" || notifier.alerts[1].Key != "anomaly:spike:minute:2" {
+		t.Fatalf("alerts = %+v, want anomaly:spike:minute:pool, anomaly:spike:minute:2", notifier.alerts)
+	}
+
+	// 最近一小时没有任何请求：整体骤降为 critical，之前的突增恢复
+	notifier.alerts = nil
+	detector.store = NewMemoryStore(store.channels, logs[:history])
+	if _, err := detector.detect(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]Alert)
+	for _, alert := range notifier.alerts {
+		got[alert.Key] = alert
+	}
+	if alert, ok := got["anomaly:drop:hour:pool"]; !ok || alert.Severity != SeverityCritical {
+		t.Errorf("alerts = %+v, want critical anomaly:drop:hour:pool", notifier.alerts)
+	}
+	if alert, ok := got["anomaly:spike:minute:2"]; !ok || !alert.Resolved {
+		t.Errorf("alerts = %+v, want anomaly:spike:minute:2 resolved", notifier.alerts)
+	}
+}
+
+func TestAnomalyConfigValidate(t *testing.T) {
+	valid := anomalyTestConfig()
+	tests := []struct {
+		name   string
+		modify func(*AnomalyConfig)
+		ok     bool
+	}{
+		{"默认", func(c *AnomalyConfig) {}, true},
+		{"不检测", func(c *AnomalyConfig) { c.BaselineDays = 0 }, true},
+		{"天数为负", func(c *AnomalyConfig) { c.BaselineDays = -1 }, false},
+		{"alpha 为 0", func(c *AnomalyConfig) { c.Alpha = 0 }, false},
+		{"突增倍数不大于 1", func(c *AnomalyConfig) { c.SpikeFactor = 1 }, false},
+		{"骤降倍数为 1", func(c *AnomalyConfig) { c.DropFactor = 1 }, false},
+	}
+	for _, tt := range tests {
+		config := valid
+		tt.modify(&config)
+		if err := config.validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok = %v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CapacityConfig 容量规划的参数
+type CapacityConfig struct {
+	HistoryDays     int // 参与统计的历史天数
+	HeadroomPercent int // 在历史峰值的基础上预留的余量百分比
+}
+
+// DayDemand 一个天窗口内的历史需求
+type DayDemand struct {
+	Start      time.Time `json:"start"`
+	Requests   int       `json:"requests"`
+	PeakMinute int       `json:"peak_minute"` // 该天窗口内单个分钟窗口的最大请求数
+	Percentage float64   `json:"percentage"`  // 占当前天容量的百分比，最大 100
+}
+
+// CapacityPlan 根据历史需求计算出的渠道数量建议
+type CapacityPlan struct {
+	HistoryDays       int         `json:"history_days"`
+	HeadroomPercent   int         `json:"headroom_percent"`
+	MinuteWindowLabel Message     `json:"minute_window_label"`
+	DayWindowLabel    Message     `json:"day_window_label"`
+	Days              []DayDemand `json:"days"`
+
+	PeakMinuteDemand   int       `json:"peak_minute_demand"`
+	PeakMinuteAt       time.Time `json:"peak_minute_at"`
+	PeakDayDemand      int       `json:"peak_day_demand"`
+	PeakDayAt          time.Time `json:"peak_day_at"`
+	AverageDayDemand   float64   `json:"average_day_demand"` // 不含当前未结束的天窗口
+	TargetMinuteDemand int       `json:"target_minute_demand"`
+	TargetDayDemand    int       `json:"target_day_demand"`
+
+	NormalChannels int `json:"normal_channels"`
+	PaidChannels   int `json:"paid_channels"`
+	MinuteCapacity int `json:"minute_capacity"`
+	DayCapacity    int `json:"day_capacity"`
+
+	RequiredNormalChannels int `json:"required_normal_channels"` // 付费号数量不变时需要的普号数
+	RequiredPaidChannels   int `json:"required_paid_channels"`   // 普号数量不变时需要的付费号数
+	AddNormalChannels      int `json:"add_normal_channels"`
+	AddPaidChannels        int `json:"add_paid_channels"`
+
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// demandDayWindow 返回按天汇总历史需求时使用的固定窗口：
+// 固定的天窗口直接使用；滚动窗口没有固定边界，按同样长度、从 00:00 对齐的固定窗口近似，
+// 长度不满足固定窗口的要求（整除 24h 且为整数秒）时按 24h 近似
+func demandDayWindow(window Window) Window {
+	if window.Kind == WindowFixed {
+		return window
+	}
+	if window.Length <= 24*time.Hour && (24*time.Hour)%window.Length == 0 && window.Length%time.Second == 0 {
+		return Window{Kind: WindowFixed, Length: window.Length}
+	}
+	return Window{Kind: WindowFixed, Length: 24 * time.Hour}
+}
+
+// demandBucket 返回统计分钟需求时的分桶长度，至少 1 秒
+func demandBucket(window Window) time.Duration {
+	if window.Length < time.Second {
+		return time.Second
+	}
+	return window.Length
+}
+
+// capacitySince 返回参与统计的历史起点：当前天窗口往前 HistoryDays 天
+func capacitySince(quota QuotaConfig, config CapacityConfig, now time.Time) time.Time {
+	return demandDayWindow(quota.DayWindow).Start(now).Add(-time.Duration(config.HistoryDays) * 24 * time.Hour)
+}
+
+// requiredChannels 返回在已有容量 existing 之外，满足 target 还需要多少个容量为 perChannel 的渠道
+func requiredChannels(target, existing, perChannel int) int {
+	need := target - existing
+	if need <= 0 || perChannel <= 0 {
+		return 0
+	}
+	return (need + perChannel - 1) / perChannel
+}
+
+// withHeadroom 返回加上余量后的需求，向上取整
+func withHeadroom(demand, headroomPercent int) int {
+	return (demand*(100+headroomPercent) + 99) / 100
+}
+
+// planCapacity 根据分桶的历史需求和当前渠道计算容量规划，demand 的分桶长度为 demandBucket(quota.MinuteWindow)
+func planCapacity(demand map[int64]int, channels []Channel, quota QuotaConfig, config CapacityConfig, now time.Time) *CapacityPlan {
+	plan := &CapacityPlan{
+		HistoryDays:       config.HistoryDays,
+		HeadroomPercent:   config.HeadroomPercent,
+		MinuteWindowLabel: formatWindowLength(quota.MinuteWindow.Length),
+		DayWindowLabel:    formatWindowLength(quota.DayWindow.Length),
+		GeneratedAt:       now,
+	}
+
+	for _, channel := range channels {
+		if _, paid := quota.LimitsFor(channel); paid {
+			plan.PaidChannels++
+		} else {
+			plan.NormalChannels++
+		}
+	}
+	plan.MinuteCapacity = plan.NormalChannels*quota.Normal.Minute + plan.PaidChannels*quota.Paid.Minute
+	plan.DayCapacity = plan.NormalChannels*quota.Normal.Day + plan.PaidChannels*quota.Paid.Day
+
+	// 先列出历史范围内的每个天窗口，没有请求的天也要参与平均值
+	dayWindow := demandDayWindow(quota.DayWindow)
+	current := dayWindow.Start(now)
+	days := make(map[int64]*DayDemand)
+	for start := capacitySince(quota, config, now); !start.After(current); start = start.Add(dayWindow.Length) {
+		days[start.Unix()] = &DayDemand{Start: start}
+	}
+	for bucket, count := range demand {
+		at := time.Unix(bucket, 0).In(now.Location())
+		start := dayWindow.Start(at)
+		day, ok := days[start.Unix()]
+		if !ok {
+			day = &DayDemand{Start: start}
+			days[start.Unix()] = day
+		}
+		day.Requests += count
+		day.PeakMinute = max(day.PeakMinute, count)
+		if count > plan.PeakMinuteDemand || (count == plan.PeakMinuteDemand && at.Before(plan.PeakMinuteAt)) {
+			plan.PeakMinuteDemand, plan.PeakMinuteAt = count, at
+		}
+	}
+
+	var completed, completedRequests int
+	for _, day := range days {
+		if day.Requests > plan.PeakDayDemand || (day.Requests == plan.PeakDayDemand && day.Start.Before(plan.PeakDayAt)) {
+			plan.PeakDayDemand, plan.PeakDayAt = day.Requests, day.Start
+		}
+		if day.Start.Before(current) {
+			completed++
+			completedRequests += day.Requests
+		}
+		if plan.DayCapacity > 0 {
+			day.Percentage = float64(day.Requests) / float64(plan.DayCapacity) * 100
+			if day.Percentage > 100 {
+				day.Percentage = 100
+			}
+		}
+		plan.Days = append(plan.Days, *day)
+	}
+	sort.Slice(plan.Days, func(i, j int) bool { return plan.Days[i].Start.Before(plan.Days[j].Start) })
+	if completed > 0 {
+		plan.AverageDayDemand = float64(completedRequests) / float64(completed)
+	}
+
+	plan.TargetMinuteDemand = withHeadroom(plan.PeakMinuteDemand, config.HeadroomPercent)
+	plan.TargetDayDemand = withHeadroom(plan.PeakDayDemand, config.HeadroomPercent)
+	plan.RequiredNormalChannels = max(
+		requiredChannels(plan.TargetMinuteDemand, plan.PaidChannels*quota.Paid.Minute, quota.Normal.Minute),
+		requiredChannels(plan.TargetDayDemand, plan.PaidChannels*quota.Paid.Day, quota.Normal.Day))
+	plan.RequiredPaidChannels = max(
+		requiredChannels(plan.TargetMinuteDemand, plan.NormalChannels*quota.Normal.Minute, quota.Paid.Minute),
+		requiredChannels(plan.TargetDayDemand, plan.NormalChannels*quota.Normal.Day, quota.Paid.Day))
+	plan.AddNormalChannels = max(0, plan.RequiredNormalChannels-plan.NormalChannels)
+	plan.AddPaidChannels = max(0, plan.RequiredPaidChannels-plan.PaidChannels)
+	return plan
+}
+
+// capacityCacheTTL 容量规划结果的缓存时间，历史需求变化缓慢，没必要每次请求都扫描整段日志
+const capacityCacheTTL = 5 * time.Minute
+
+// CapacityPlanner 按需计算容量规划并缓存结果
+type CapacityPlanner struct {
+	store    Store
+	quota    QuotaConfig
+	location *time.Location
+	config   CapacityConfig
+
+	mu   sync.Mutex
+	plan *CapacityPlan
+}
+
+// NewCapacityPlanner 创建容量规划器
+func NewCapacityPlanner(store Store, quota QuotaConfig, location *time.Location, config CapacityConfig) *CapacityPlanner {
+	return &CapacityPlanner{store: store, quota: quota, location: location, config: config}
+}
+
+// Plan 返回容量规划，缓存未过期时直接返回缓存的结果
+func (p *CapacityPlanner) Plan(ctx context.Context) (*CapacityPlan, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now().In(p.location)
+	if p.plan != nil && now.Sub(p.plan.GeneratedAt) < capacityCacheTTL {
+		return p.plan, nil
+	}
+
+	demand, err := p.store.Demand(ctx, capacitySince(p.quota, p.config, now), now, demandBucket(p.quota.MinuteWindow))
+	if err != nil {
+		return nil, fmt.Errorf("统计历史需求失败: %w", err)
+	}
+	channels, err := p.store.Channels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询渠道失败: %w", err)
+	}
+	p.plan = planCapacity(demand, channels, p.quota, p.config, now)
+	return p.plan, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCapacityPlanner(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, testNow.Location())
+	}
+	var logs []LogEntry
+	addLogs := func(start time.Time, count int, spacing time.Duration) {
+		for i := 0; i < count; i++ {
+			logs = append(logs, LogEntry{ChannelID: 1, CreatedAt: start.Add(time.Duration(i) * spacing).Unix()})
+		}
+	}
+	addLogs(at(15, 7, 59), 50, 0)            // 早于统计范围
+	addLogs(at(16, 10, 0), 40, 0)            // 分钟峰值
+	addLogs(at(17, 12, 0), 200, time.Minute) // 天峰值，每分钟一次
+	addLogs(at(18, 8, 30), 10, 0)            // 当前天窗口
+	store := NewMemoryStore([]Channel{
+		{ID: 1, Status: "1"},
+		{ID: 2, Status: "2"},
+		{ID: 3, Status: "1", Tag: "gcp"},
+	}, logs)
+
+	config := CapacityConfig{HistoryDays: 3, HeadroomPercent: 20}
+	demand, err := store.Demand(context.Background(), capacitySince(testQuota, config, testNow), testNow, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := planCapacity(demand, store.channels, testQuota, config, testNow)
+
+	checks := []struct {
+		name      string
+		got, want int
+	}{
+		{"Days", len(plan.Days), 4},
+		{"PeakMinuteDemand", plan.PeakMinuteDemand, 40},
+		{"PeakDayDemand", plan.PeakDayDemand, 200},
+		{"TargetMinuteDemand", plan.TargetMinuteDemand, 48},
+		{"TargetDayDemand", plan.TargetDayDemand, 240},
+		{"NormalChannels", plan.NormalChannels, 2},
+		{"PaidChannels", plan.PaidChannels, 1},
+		{"MinuteCapacity", plan.MinuteCapacity, 30},
+		{"DayCapacity", plan.DayCapacity, 150},
+		{"RequiredNormalChannels", plan.RequiredNormalChannels, 6},
+		{"RequiredPaidChannels", plan.RequiredPaidChannels, 2},
+		{"AddNormalChannels", plan.AddNormalChannels, 4},
+		{"AddPaidChannels", plan.AddPaidChannels, 1},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %d, want %d", c.name, c.got, c.want)
+		}
+	}
+	if !plan.PeakMinuteAt.Equal(at(16, 10, 0)) || !plan.PeakDayAt.Equal(at(17, 8, 0)) {
+		t.Errorf("峰值时间 = %v / %v", plan.PeakMinuteAt, plan.PeakDayAt)
+	}
+	// 平均值只计算已结束的 3 个天窗口，没有请求的天也要计入
+	if plan.AverageDayDemand != 80 {
+		t.Errorf("AverageDayDemand = %v, want 80", plan.AverageDayDemand)
+	}
+	if !plan.Days[0].Start.Equal(at(15, 8, 0)) || plan.Days[2].Percentage != 100 {
+		t.Errorf("Days = %+v", plan.Days)
+	}
+
+	renderer, err := NewRenderer(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, "capacity.html", plan); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "建议新增 4 个普号") {
+		t.Error("页面中缺少新增普号的建议")
+	}
+}
+
+func TestRequiredChannels(t *testing.T) {
+	tests := []struct {
+		target, existing, perChannel, want int
+	}{
+		{100, 0, 25, 4},
+		{101, 0, 25, 5},
+		{100, 100, 25, 0},
+		{100, 150, 25, 0},
+		{10, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := requiredChannels(tt.target, tt.existing, tt.perChannel); got != tt.want {
+			t.Errorf("requiredChannels(%d, %d, %d) = %d, want %d", tt.target, tt.existing, tt.perChannel, got, tt.want)
+		}
+	}
+}
+
+// TestDemandDayWindow 不满足固定窗口要求的滚动窗口按 24h 近似，避免按极短的周期逐个遍历
+func TestDemandDayWindow(t *testing.T) {
+	tests := []struct {
+		window Window
+		want   Window
+	}{
+		{Window{Kind: WindowFixed, Length: 24 * time.Hour, Offset: 8 * time.Hour}, Window{Kind: WindowFixed, Length: 24 * time.Hour, Offset: 8 * time.Hour}},
+		{Window{Kind: WindowRolling, Length: 6 * time.Hour}, Window{Kind: WindowFixed, Length: 6 * time.Hour}},
+		{Window{Kind: WindowRolling, Length: 7 * time.Hour}, Window{Kind: WindowFixed, Length: 24 * time.Hour}},
+		{Window{Kind: WindowRolling, Length: time.Millisecond}, Window{Kind: WindowFixed, Length: 24 * time.Hour}},
+	}
+	for _, tt := range tests {
+		if got := demandDayWindow(tt.window); got != tt.want {
+			t.Errorf("demandDayWindow(%v) = %v, want %v", tt.window, got, tt.want)
+		}
+	}
+}
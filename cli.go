@@ -0,0 +1,681 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// openDB 连接数据库并测试连接
+func openDB(cfg *Config) (*sql.DB, error) {
+	db, err := sql.Open("mysql", cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("无法连接到数据库: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("数据库连接测试失败: %w", err)
+	}
+	return db, nil
+}
+
+// signalContext 返回在收到 SIGINT/SIGTERM 时取消的 ctx
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// runServe 启动监控面板
+func runServe(cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	enforce := flags.Bool("enforce", false, "同时在后台按 ENFORCE_INTERVAL 执行配额检查，替代定时调用存储过程")
+	flags.Parse(args)
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		db.Close()
+		slog.Info("数据库连接已关闭")
+	}()
+	slog.Info("数据库连接测试成功")
+
+	// 收到 SIGINT/SIGTERM 时取消 ctx，通知后台任务和服务器退出
+	ctx, stop := signalContext()
+	defer stop()
+
+	// 页面模板和静态资源，启动时解析一次
+	renderer, err := NewRenderer(cfg.DevMode, cfg.TemplateDir)
+	if err != nil {
+		return fmt.Errorf("模板解析失败: %w", err)
+	}
+	renderer.SetUI(cfg.UI)
+
+	// pageLang 确定页面的语言，通过 lang 参数切换时记住选择
+	pageLang := func(w http.ResponseWriter, r *http.Request) string {
+		lang := requestLang(r, cfg.Lang)
+		if r.URL.Query().Has("lang") {
+			http.SetCookie(w, &http.Cookie{Name: langCookie, Value: lang, Path: "/", MaxAge: 365 * 24 * 3600, SameSite: http.SameSiteLaxMode})
+		}
+		return lang
+	}
+
+	// 后台定期采集渠道数据，每次查询都会记录 span 和耗时
+	store := traceStore(NewMySQLStore(db))
+	collector := NewCollector(store, cfg.Quota, cfg.Location, cfg.CollectInterval)
+	// 后台定期估算花费并检查预算、统计延迟
+	email := newEmailNotifier(cfg, renderer, collector.Latest)
+	alerter := NewAlerter(newNotifier(cfg, email))
+	silences, err := NewSilenceStore(cfg.SilencesFile)
+	if err != nil {
+		return fmt.Errorf("读取告警屏蔽失败: %w", err)
+	}
+	alerter.SetSilences(silences)
+	// 每次采集后按规则检查每个渠道
+	rules := NewRuleEvaluator(cfg.AlertRules, alerter, collector.Latest, cfg.CollectInterval)
+	costs := NewCostTracker(store, cfg.Quota, cfg.Location, cfg.Cost, alerter)
+	collector.SetCostTracker(costs)
+	latency := NewLatencyTracker(store, cfg.Location, cfg.Latency)
+	collector.SetLatencyTracker(latency)
+	collector.SetLabels(cfg.Labels)
+	// 请求量与之前几天同一时段的基线比较，偏离时告警
+	anomalies := NewAnomalyDetector(store, cfg.Location, cfg.Anomaly, alerter)
+	collector.SetAnomalyDetector(anomalies)
+	// 每次采集时检查存储过程是否仍在更新 channels 表中的计数
+	counters := NewCounterChecker(store, cfg.Quota, cfg.Counters, alerter)
+	collector.SetCounterChecker(counters)
+	// 每次天窗口重置后生成日报
+	archive := NewReportArchive(cfg.Report.Dir)
+	reporter := NewReporter(store, cfg.Quota, cfg.Location, cfg.Report, archive, renderer, newMailer(cfg))
+	var background sync.WaitGroup
+	for _, run := range []func(context.Context){collector.Run, costs.Run, latency.Run, anomalies.Run, reporter.Run, rules.Run} {
+		background.Add(1)
+		go func(run func(context.Context)) {
+			defer background.Done()
+			run(ctx)
+		}(run)
+	}
+	if *enforce {
+		enforcer := NewEnforcer(store, cfg.Quota, cfg.Location, cfg.EnforceInterval)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			enforcer.Run(ctx)
+		}()
+	}
+
+	// 健康检查与版本信息
+	health := &healthHandler{
+		store:     store,
+		collector: collector,
+		counters:  counters,
+		maxAge:    3 * cfg.CollectInterval,
+		config:    cfg.Summary(),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/static/", renderer.StaticHandler())
+	mux.HandleFunc("/healthz", health.healthz)
+	mux.HandleFunc("/readyz", health.readyz)
+	mux.HandleFunc("/healthz/counters", health.countersz)
+	mux.HandleFunc("/version", health.version)
+	mux.HandleFunc("/api/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		snapshot := collector.Latest()
+		if snapshot == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "尚未采集到数据"})
+			return
+		}
+		// 接口默认不分页，tui --remote 需要全部渠道
+		query, err := parseChannelQuery(r.URL.Query(), 0, cfg.GroupBy)
+		if err == nil {
+			snapshot, err = snapshot.Queried(query)
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, snapshot)
+	})
+
+	mux.HandleFunc("/api/cost", func(w http.ResponseWriter, r *http.Request) {
+		report := costs.Latest()
+		if report == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "尚未估算花费"})
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	})
+
+	// 消耗排行
+	leaderboards := NewLeaderboardBuilder(store, cfg.Quota, cfg.Location)
+	mux.HandleFunc("/api/leaderboard", func(w http.ResponseWriter, r *http.Request) {
+		leaderboard, err := leaderboards.Leaderboard(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "统计消耗排行失败"})
+			slog.ErrorContext(r.Context(), "统计消耗排行失败", "err", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, leaderboard.Truncate(leaderboardSize(r)))
+	})
+	mux.HandleFunc("/leaderboard", func(w http.ResponseWriter, r *http.Request) {
+		leaderboard, err := leaderboards.Leaderboard(r.Context())
+		if err != nil {
+			http.Error(w, "统计消耗排行失败", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "统计消耗排行失败", "err", err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := renderer.RenderLang(w, pageLang(w, r), "leaderboard.html", leaderboard.Truncate(leaderboardSize(r))); err != nil {
+			http.Error(w, "模板执行失败", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "模板执行失败", "err", err)
+		}
+	})
+
+	// 容量规划
+	planner := NewCapacityPlanner(store, cfg.Quota, cfg.Location, cfg.Capacity)
+	mux.HandleFunc("/api/capacity", func(w http.ResponseWriter, r *http.Request) {
+		plan, err := planner.Plan(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "容量规划计算失败"})
+			slog.ErrorContext(r.Context(), "容量规划计算失败", "err", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, plan)
+	})
+	mux.HandleFunc("/capacity", func(w http.ResponseWriter, r *http.Request) {
+		plan, err := planner.Plan(r.Context())
+		if err != nil {
+			http.Error(w, "容量规划计算失败", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "容量规划计算失败", "err", err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := renderer.RenderLang(w, pageLang(w, r), "capacity.html", plan); err != nil {
+			http.Error(w, "模板执行失败", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "模板执行失败", "err", err)
+		}
+	})
+
+	// 日报
+	mux.HandleFunc("/api/reports", func(w http.ResponseWriter, r *http.Request) {
+		reports, err := archive.List()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "读取日报失败"})
+			slog.ErrorContext(r.Context(), "读取日报失败", "err", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, reports)
+	})
+	mux.HandleFunc("/api/reports/", func(w http.ResponseWriter, r *http.Request) {
+		report, err := archive.Load(strings.TrimPrefix(r.URL.Path, "/api/reports/"))
+		if errors.Is(err, fs.ErrNotExist) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "日报不存在"})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "读取日报失败"})
+			slog.ErrorContext(r.Context(), "读取日报失败", "err", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	})
+	mux.HandleFunc("/reports", func(w http.ResponseWriter, r *http.Request) {
+		reports, err := archive.List()
+		if err != nil {
+			http.Error(w, "读取日报失败", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "读取日报失败", "err", err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := renderer.RenderLang(w, pageLang(w, r), "reports.html", reports); err != nil {
+			http.Error(w, "模板执行失败", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "模板执行失败", "err", err)
+		}
+	})
+	mux.HandleFunc("/reports/", func(w http.ResponseWriter, r *http.Request) {
+		// /reports/2024-01-02 为页面，/reports/2024-01-02.md 为 Markdown
+		id, markdown := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/reports/"), ".md")
+		report, err := archive.Load(id)
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, "读取日报失败", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "读取日报失败", "err", err)
+			return
+		}
+		if markdown {
+			text, err := renderMarkdown(report)
+			if err != nil {
+				http.Error(w, "模板执行失败", http.StatusInternalServerError)
+				slog.ErrorContext(r.Context(), "模板执行失败", "err", err)
+				return
+			}
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			io.WriteString(w, text)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := renderer.RenderLang(w, pageLang(w, r), "report.html", report); err != nil {
+			http.Error(w, "模板执行失败", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "模板执行失败", "err", err)
+		}
+	})
+
+	// 告警与屏蔽
+	mux.HandleFunc("/api/alerts", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, newAlertsPage(alerter, rules.Rules(), silences, time.Now()))
+	})
+	mux.HandleFunc("/alerts", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := renderer.RenderLang(w, pageLang(w, r), "alerts.html", newAlertsPage(alerter, rules.Rules(), silences, time.Now())); err != nil {
+			http.Error(w, "模板执行失败", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "模板执行失败", "err", err)
+		}
+	})
+	mux.HandleFunc("/alerts/silences", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "只支持 POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if !sameOrigin(r) {
+			http.Error(w, "拒绝跨站请求", http.StatusForbidden)
+			slog.WarnContext(r.Context(), "拒绝跨站修改告警屏蔽", "origin", r.Header.Get("Origin"), "referer", r.Header.Get("Referer"))
+			return
+		}
+		parsed, err := parseSilenceForm(r, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, silence := range parsed {
+			if silence, err = silences.Add(silence); err != nil {
+				http.Error(w, "保存告警屏蔽失败", http.StatusInternalServerError)
+				slog.ErrorContext(r.Context(), "保存告警屏蔽失败", "err", err)
+				return
+			}
+			slog.InfoContext(r.Context(), "新增告警屏蔽", "silence", silence.ID, "rule", silence.Rule, "channel", silence.ChannelID, "expires_at", silence.ExpiresAt.Format(time.DateTime))
+		}
+		// 从渠道面板批量屏蔽时回到原来的页面
+		http.Redirect(w, r, localRedirect(r, "/alerts"), http.StatusSeeOther)
+	})
+	mux.HandleFunc("/alerts/silences/expire", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "只支持 POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if !sameOrigin(r) {
+			http.Error(w, "拒绝跨站请求", http.StatusForbidden)
+			slog.WarnContext(r.Context(), "拒绝跨站修改告警屏蔽", "origin", r.Header.Get("Origin"), "referer", r.Header.Get("Referer"))
+			return
+		}
+		id, err := strconv.Atoi(r.PostFormValue("id"))
+		if err != nil {
+			http.Error(w, "屏蔽 ID 无效", http.StatusBadRequest)
+			return
+		}
+		if err := silences.Expire(id); errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			http.Error(w, "保存告警屏蔽失败", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "保存告警屏蔽失败", "err", err)
+			return
+		}
+		slog.InfoContext(r.Context(), "结束告警屏蔽", "silence", id)
+		http.Redirect(w, r, "/alerts", http.StatusSeeOther)
+	})
+
+	// 优先使用后台采集的结果，服务刚启动尚未采集成功时当场采集一次
+	latestSnapshot := func(w http.ResponseWriter, r *http.Request) *Snapshot {
+		data := collector.Latest()
+		if data == nil {
+			var err error
+			data, err = collector.Refresh(r.Context())
+			if err != nil {
+				http.Error(w, "查询数据库失败", http.StatusInternalServerError)
+				slog.ErrorContext(r.Context(), "查询失败", "err", err)
+				return nil
+			}
+		}
+		return data
+	}
+
+	// 延迟与渠道详情
+	mux.HandleFunc("/api/latency", func(w http.ResponseWriter, r *http.Request) {
+		report := latency.Latest()
+		if report == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "尚未统计延迟"})
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	})
+	mux.HandleFunc("/api/anomalies", func(w http.ResponseWriter, r *http.Request) {
+		report := anomalies.Latest()
+		if report == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "尚未检测请求量异常"})
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	})
+	mux.HandleFunc("/api/channels/", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := channelIDFromPath(r.URL.Path, "/api/channels/")
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "渠道不存在"})
+			return
+		}
+		data := collector.Latest()
+		if data == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "尚未采集到数据"})
+			return
+		}
+		detail, ok := buildChannelDetail(data, latency.Latest(), id)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "渠道不存在"})
+			return
+		}
+		writeJSON(w, http.StatusOK, detail)
+	})
+	mux.HandleFunc("/channels/", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := channelIDFromPath(r.URL.Path, "/channels/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data := latestSnapshot(w, r)
+		if data == nil {
+			return
+		}
+		detail, ok := buildChannelDetail(data, latency.Latest(), id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := renderer.RenderLang(w, pageLang(w, r), "channel.html", detail); err != nil {
+			http.Error(w, "模板执行失败", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "模板执行失败", "err", err)
+		}
+	})
+
+	// 处理主页请求
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		data := latestSnapshot(w, r)
+		if data == nil {
+			return
+		}
+		// 没有指定显示方式时沿用上次的选择
+		values := r.URL.Query()
+		if cookie, err := r.Cookie(viewCookie); err == nil && !values.Has("view") && (cookie.Value == ViewCards || cookie.Value == ViewTable) {
+			values.Set("view", cookie.Value)
+		}
+		query, err := parseChannelQuery(values, cfg.PageSize, cfg.GroupBy)
+		if err == nil {
+			data, err = data.Queried(query)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: viewCookie, Value: query.View, Path: "/", MaxAge: 365 * 24 * 3600, SameSite: http.SameSiteLaxMode})
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := renderer.RenderLang(w, pageLang(w, r), "index.html", data); err != nil {
+			http.Error(w, "模板执行失败", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "模板执行失败", "err", err)
+			return
+		}
+	})
+
+	// 启动服务器
+	server := &http.Server{
+		Handler:           instrument(mux),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+	listener, err := listen(cfg.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("服务器启动失败: %w", err)
+	}
+	slog.Info("服务器已启动", "addr", listener.Addr().String())
+	serveErr := serve(ctx, server, listener, cfg.ShutdownTimeout)
+	stop()
+
+	// 服务器异常退出时同样等待后台任务退出后再关闭数据库连接，最后返回错误使进程以非 0 状态退出
+	background.Wait()
+	slog.Info("后台任务已停止")
+	if email != nil {
+		if err := email.Flush(context.Background()); err != nil {
+			slog.Error("发送告警邮件失败", "err", err)
+		}
+	}
+	if serveErr != nil {
+		return fmt.Errorf("服务器异常退出: %w", serveErr)
+	}
+	return nil
+}
+
+// runHealthcheck 请求本机 serve 的健康检查接口，按 LISTEN_ADDR 连接，因此修改监听地址或使用 Unix socket 后仍然可用
+func runHealthcheck(cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	path := flags.String("path", "/readyz", "请求的路径")
+	timeout := flags.Duration("timeout", 5*time.Second, "请求超时")
+	flags.Parse(args)
+
+	client, base, err := localClient(cfg.ListenAddr, *timeout)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Get(base + *path)
+	if err != nil {
+		return fmt.Errorf("请求 %s 失败: %w", *path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s 返回 %d: %s", *path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// runSnapshot 采集一次并把渠道列表和总使用情况输出到标准输出
+func runSnapshot(cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	format := flags.String("format", "text", "输出格式：text 或 json")
+	flags.Parse(args)
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("不支持的输出格式 %q", *format)
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	collector := NewCollector(traceStore(NewMySQLStore(db)), cfg.Quota, cfg.Location, cfg.CollectInterval)
+	snapshot, err := collector.Refresh(context.Background())
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(snapshot)
+	}
+	return writeSnapshotText(os.Stdout, snapshot)
+}
+
+// writeSnapshotText 以对齐的表格输出面板数据
+func writeSnapshotText(out io.Writer, snapshot *Snapshot) error {
+	usage := func(count, limit int, percentage float64) string {
+		return fmt.Sprintf("%d/%d (%.1f%%)", count, limit, percentage)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\t类型\t状态\t%s\t%s\n", snapshot.MinuteWindowLabel, snapshot.DayWindowLabel)
+	for _, view := range snapshot.Channels {
+		status := view.StatusDisplay
+		if view.IsCoolingDown {
+			status += "（" + view.CooldownReason + "，剩余 " + formatRemaining(view.CooldownSeconds).String() + "）"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", view.ID, view.TagDisplay, status,
+			usage(view.CountMinuteUsage, view.MinuteLimit, view.MinutePercentage),
+			usage(view.CountDayUsage, view.DayLimit, view.DayPercentage))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	summary := snapshot.Summary
+	fmt.Fprintf(out, "\n总使用情况（%s）\n", snapshot.CollectedAt.Format("2006-01-02 15:04:05"))
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "  %s总使用次数\t%s\n", snapshot.MinuteWindowLabel,
+		usage(summary.TotalMinuteUsage, summary.TotalMinuteLimit, summary.MinutePercentage))
+	fmt.Fprintf(w, "  %s总使用次数\t%s\n", snapshot.DayWindowLabel,
+		usage(summary.TotalDayUsage, summary.TotalDayLimit, summary.DayPercentage))
+	fmt.Fprintf(w, "  自动禁用普号数\t%s\n",
+		usage(summary.DisabledNormalChannels, summary.TotalNormalChannels, summary.DisabledNormalPercentage))
+	return w.Flush()
+}
+
+// runEnforce 执行配额检查，--once 时执行一次后退出，否则按 ENFORCE_INTERVAL 循环执行
+func runEnforce(cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("enforce", flag.ExitOnError)
+	once := flags.Bool("once", false, "只执行一次配额检查后退出，适合由 cron 调用")
+	flags.Parse(args)
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	enforcer := NewEnforcer(traceStore(NewMySQLStore(db)), cfg.Quota, cfg.Location, cfg.EnforceInterval)
+	if *once {
+		result, err := enforcer.RunOnce(context.Background())
+		if err != nil {
+			return err
+		}
+		slog.Info("配额检查完成", "channels", result.Channels, "disabled", result.Disabled, "enabled", result.Enabled)
+		return nil
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	slog.Info("开始定期执行配额检查", "interval", cfg.EnforceInterval.String())
+	enforcer.Run(ctx)
+	return nil
+}
+
+// runInstallProcedure 按配置渲染内嵌的存储过程并安装到数据库
+func runInstallProcedure(cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("install-procedure", flag.ExitOnError)
+	printOnly := flags.Bool("print", false, "只输出渲染后的 SQL，不连接数据库")
+	flags.Parse(args)
+
+	if *printOnly {
+		script, err := renderProcedure(cfg.Quota, cfg.Location, time.Now())
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(os.Stdout, script)
+		return err
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := installProcedure(context.Background(), db, cfg.Quota, cfg.Location); err != nil {
+		return err
+	}
+	slog.Info("存储过程 UpdateChannelStats 已安装",
+		"normal_minute", cfg.Quota.Normal.Minute, "normal_day", cfg.Quota.Normal.Day, "paid_minute", cfg.Quota.Paid.Minute, "paid_day", cfg.Quota.Paid.Day,
+		"timezone", cfg.Location.String())
+	// 存储过程使用安装时的 UTC 偏移，夏令时切换后天窗口会与监控相差一小时
+	year := time.Now().In(cfg.Location).Year()
+	_, winter := time.Date(year, time.January, 1, 0, 0, 0, 0, cfg.Location).Zone()
+	_, summer := time.Date(year, time.July, 1, 0, 0, 0, 0, cfg.Location).Zone()
+	if winter != summer {
+		slog.Warn("TIMEZONE 有夏令时，切换后需要重新执行 install-procedure", "timezone", cfg.Location.String())
+	}
+	return nil
+}
+
+// runReport 生成一份日报并保存，默认为最近一个已结束的天窗口
+func runReport(cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	date := flags.String("date", "", "生成在该日期（YYYY-MM-DD）开始的天窗口的日报，默认为最近一个已结束的天窗口")
+	send := flags.Bool("send", false, "按配置通过邮件和 webhook 发送日报")
+	flags.Parse(args)
+
+	now := time.Now().In(cfg.Location)
+	start, window := reportWindow(cfg.Quota, now)
+	if *date != "" {
+		day, err := time.ParseInLocation("2006-01-02", *date, cfg.Location)
+		if err != nil {
+			return fmt.Errorf("日期无效: %w", err)
+		}
+		// 取该日期内开始的第一个天窗口
+		start = window.Start(day.Add(window.Length - time.Second))
+		if start.Add(window.Length).After(now) {
+			return fmt.Errorf("%s 开始的天窗口尚未结束", start.Format("2006-01-02 15:04"))
+		}
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	renderer, err := NewRenderer(cfg.DevMode, cfg.TemplateDir)
+	if err != nil {
+		return fmt.Errorf("模板解析失败: %w", err)
+	}
+
+	archive := NewReportArchive(cfg.Report.Dir)
+	reporter := NewReporter(traceStore(NewMySQLStore(db)), cfg.Quota, cfg.Location, cfg.Report, archive, renderer, newMailer(cfg))
+	ctx := context.Background()
+	report, err := reporter.Generate(ctx, start)
+	if err != nil {
+		return err
+	}
+	if err := archive.Save(report); err != nil {
+		return fmt.Errorf("保存日报失败: %w", err)
+	}
+	if *send {
+		reporter.Deliver(ctx, report)
+	}
+	markdown, err := renderMarkdown(report)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(os.Stdout, markdown)
+	return err
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Snapshot 表示一次采集得到的完整面板数据
+type Snapshot struct {
+	Channels           []ChannelView  `json:"channels"`
+	Summary            SummaryData    `json:"summary"`
+	MinuteWindowLabel  Message        `json:"minute_window_label"`
+	DayWindowLabel     Message        `json:"day_window_label"`
+	CollectedAt        time.Time      `json:"collected_at"`
+	Cost               *CostReport    `json:"cost,omitempty"` // 未启用花费估算或尚未估算时为 nil
+	LatencyWindowLabel Message        `json:"latency_window_label,omitempty"`
+	LabelKeys          []string       `json:"label_keys,omitempty"` // 自定义标签名，用于选择分组方式
+	GroupBy            string         `json:"group_by,omitempty"`
+	Groups             []ChannelGroup `json:"groups,omitempty"` // 按 GroupBy 分组后的渠道，不分组时为空
+	Query              *ChannelQuery  `json:"query,omitempty"`  // 筛选、排序和分页条件，未筛选时为 nil
+	Page               *PageInfo      `json:"page,omitempty"`
+	AvailableGroups    []string       `json:"available_groups,omitempty"` // 全部渠道的 newapi 分组，用于分组筛选
+	PoolAnomalies      []Anomaly      `json:"pool_anomalies,omitempty"`   // 整个渠道池的请求量偏离基线
+	Counters           *CounterStatus `json:"counters,omitempty"`         // channels 表中计数的检查结果，不检查时为 nil
+}
+
+// Collector 在后台定期从数据库采集渠道数据，页面和健康检查读取最近一次的结果
+type Collector struct {
+	store    Store
+	quota    QuotaConfig
+	location *time.Location
+	interval time.Duration
+	costs    *CostTracker
+	latency  *LatencyTracker
+	labels   ChannelLabels
+	anomaly  *AnomalyDetector
+	counters *CounterChecker
+
+	mu          sync.RWMutex
+	snapshot    *Snapshot
+	lastErr     error
+	lastAttempt time.Time
+}
+
+// NewCollector 创建采集器，interval 为两次采集之间的间隔
+func NewCollector(store Store, quota QuotaConfig, location *time.Location, interval time.Duration) *Collector {
+	return &Collector{
+		store:    store,
+		quota:    quota,
+		location: location,
+		interval: interval,
+	}
+}
+
+// SetCostTracker 设置花费估算器，之后采集的面板数据会带上最近一次的花费
+func (c *Collector) SetCostTracker(costs *CostTracker) {
+	c.costs = costs
+}
+
+// SetLatencyTracker 设置延迟统计器，之后采集的面板数据会带上最近一次的延迟
+func (c *Collector) SetLatencyTracker(latency *LatencyTracker) {
+	c.latency = latency
+}
+
+// SetAnomalyDetector 设置异常检测器，之后采集的面板数据会带上最近一次检测到的异常
+func (c *Collector) SetAnomalyDetector(anomaly *AnomalyDetector) {
+	c.anomaly = anomaly
+}
+
+// SetCounterChecker 设置计数检查器，之后每次采集都会对照 channels 表中的计数和日志统计
+func (c *Collector) SetCounterChecker(counters *CounterChecker) {
+	c.counters = counters
+}
+
+// SetLabels 设置自定义标签，之后采集的面板数据会带上每个渠道的标签
+func (c *Collector) SetLabels(labels ChannelLabels) {
+	c.labels = labels
+}
+
+// Run 立即采集一次，然后按间隔循环采集，直到 ctx 被取消
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := c.Refresh(ctx); err != nil {
+			slog.ErrorContext(ctx, "采集渠道数据失败", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh 立即执行一次采集并保存结果
+func (c *Collector) Refresh(ctx context.Context) (*Snapshot, error) {
+	ctx, span := tracer.Start(ctx, "collect")
+	snapshot, err := c.collect(ctx, time.Now().In(c.location))
+	if err == nil {
+		span.SetAttributes(attribute.Int("channels", len(snapshot.Channels)))
+	}
+	endSpan(span, err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastAttempt = time.Now()
+	c.lastErr = err
+	if err != nil {
+		return nil, err
+	}
+	c.snapshot = snapshot
+	return snapshot, nil
+}
+
+// Latest 返回最近一次成功采集的结果，尚未采集成功时返回 nil
+func (c *Collector) Latest() *Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.snapshot
+}
+
+// Status 返回最近一次采集尝试的时间和错误
+func (c *Collector) Status() (lastAttempt time.Time, lastErr error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastAttempt, c.lastErr
+}
+
+// collect 查询数据库并构建面板数据
+func (c *Collector) collect(ctx context.Context, now time.Time) (*Snapshot, error) {
+	usage, err := c.store.Usage(ctx, c.quota.MinuteWindow.Start(now), c.quota.DayWindow.Start(now), now)
+	if err != nil {
+		return nil, fmt.Errorf("统计日志失败: %w", err)
+	}
+	channels, err := c.store.Channels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询渠道失败: %w", err)
+	}
+	since := now.Add(-trendSpan)
+	activity, err := c.store.Activity(ctx, since, now, trendBucket)
+	if err != nil {
+		return nil, fmt.Errorf("统计请求趋势失败: %w", err)
+	}
+	snapshot := buildSnapshot(channels, usage, c.quota, now)
+	applyTrends(snapshot, activity, since)
+	applyLabels(snapshot, c.labels)
+	if c.costs != nil {
+		if report := c.costs.Latest(); report != nil {
+			applyCost(snapshot, report)
+		}
+	}
+	if c.latency != nil {
+		if report := c.latency.Latest(); report != nil {
+			applyLatency(snapshot, report)
+		}
+	}
+	if c.counters != nil {
+		// 计数检查失败不影响面板，面板的使用量直接来自日志
+		if status, err := c.counters.Check(ctx, channels, usage, now); err != nil {
+			slog.WarnContext(ctx, "检查渠道计数失败", "err", err)
+		} else {
+			snapshot.Counters = status
+		}
+	}
+	if c.anomaly != nil {
+		if report := c.anomaly.Latest(); report != nil {
+			applyAnomalies(snapshot, report)
+		}
+	}
+	return snapshot, nil
+}
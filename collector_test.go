@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCollectorCollectFromMemoryStore(t *testing.T) {
+	now := testNow.Unix()
+	store := NewMemoryStore(
+		[]Channel{
+			{ID: 1, Status: "1"},
+			{ID: 2, Status: "1", Tag: "gcp"},
+			{ID: 3, Status: "1"},
+		},
+		[]LogEntry{
+			{ChannelID: 1, CreatedAt: now - 10},     // 分钟窗口和天窗口内
+			{ChannelID: 1, CreatedAt: now - 59},     // 分钟窗口和天窗口内
+			{ChannelID: 1, CreatedAt: now - 61},     // 仅天窗口内
+			{ChannelID: 2, CreatedAt: now - 3600},   // 仅天窗口内（08:00 之后）
+			{ChannelID: 2, CreatedAt: now - 2*3600}, // 08:00 之前，不计入
+			{ChannelID: 3, CreatedAt: now + 5},      // 未来的记录，不计入
+			{ChannelID: 99, CreatedAt: now - 5},     // 不存在的渠道，忽略
+		},
+	)
+	collector := NewCollector(store, testQuota, testNow.Location(), time.Minute)
+
+	snapshot, err := collector.collect(context.Background(), testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[int]UsageCounts)
+	for _, view := range snapshot.Channels {
+		got[view.ID] = UsageCounts{Minute: view.CountMinuteUsage, Day: view.CountDayUsage}
+	}
+	want := map[int]UsageCounts{
+		1: {Minute: 2, Day: 3},
+		2: {Minute: 0, Day: 1},
+		3: {Minute: 0, Day: 0},
+	}
+	for id, counts := range want {
+		if got[id] != counts {
+			t.Errorf("channel %d usage = %+v, want %+v", id, got[id], counts)
+		}
+	}
+	if len(snapshot.Channels) != 3 {
+		t.Errorf("got %d channels, want 3", len(snapshot.Channels))
+	}
+	// 趋势按分钟统计最近一小时：now-10、now-59 在最后一分钟，now-61 在倒数第二分钟
+	for _, view := range snapshot.Channels {
+		if view.ID != 1 {
+			continue
+		}
+		if minutes := view.Trend.Minutes; minutes[59] != 2 || minutes[58] != 1 {
+			t.Errorf("channel 1 trend minutes[58:] = %v, want [1 2]", minutes[58:])
+		}
+	}
+	if got := snapshot.Summary.Trend.Minutes[0]; got != 1 {
+		t.Errorf("summary trend minutes[0] = %d, want 1（渠道 2 在 now-3600 的请求）", got)
+	}
+}
+
+// failingStore 模拟数据库不可用
+type failingStore struct{ MemoryStore }
+
+func (s *failingStore) Channels(ctx context.Context) ([]Channel, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestCollectorRefreshKeepsLastSnapshotOnError(t *testing.T) {
+	store := NewMemoryStore([]Channel{{ID: 1, Status: "1"}}, nil)
+	collector := NewCollector(store, testQuota, time.UTC, time.Minute)
+	if collector.Latest() != nil {
+		t.Fatal("Latest() before first refresh should be nil")
+	}
+
+	first, err := collector.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collector.Latest() != first {
+		t.Fatal("Latest() should return the refreshed snapshot")
+	}
+
+	collector.store = &failingStore{}
+	if _, err := collector.Refresh(context.Background()); err == nil {
+		t.Fatal("Refresh() with failing store should return an error")
+	}
+	if collector.Latest() != first {
+		t.Error("a failed refresh should keep the previous snapshot")
+	}
+	if _, lastErr := collector.Status(); lastErr == nil {
+		t.Error("Status() should report the last error")
+	}
+}
+
+func TestCollectorRefreshAppliesAnomaliesOnce(t *testing.T) {
+	store := NewMemoryStore([]Channel{{ID: 1, Status: "1"}, {ID: 2, Status: "1"}}, nil)
+	collector := NewCollector(store, testQuota, time.UTC, time.Minute)
+	detector := NewAnomalyDetector(store, time.UTC, anomalyTestConfig(), nil)
+	detector.report = &AnomalyReport{Anomalies: []Anomaly{
+		{ChannelID: 0, Kind: AnomalyDrop, Scope: "hour", Current: 10, Expected: 100},
+		{ChannelID: 2, Kind: AnomalySpike, Scope: "minute", Current: 50, Expected: 5},
+	}}
+	collector.SetAnomalyDetector(detector)
+
+	snapshot, err := collector.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.PoolAnomalies) != 1 {
+		t.Errorf("PoolAnomalies = %+v, want 1", snapshot.PoolAnomalies)
+	}
+	for _, view := range snapshot.Channels {
+		if want := map[int]int{1: 0, 2: 1}[view.ID]; len(view.Anomalies) != want {
+			t.Errorf("channel %d anomalies = %+v, want %d", view.ID, view.Anomalies, want)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TierLimits 表示一类渠道（普号或付费号）的配额限制
+type TierLimits struct {
+	Minute int
+	Day    int
+}
+
+// QuotaConfig 描述配额的统计窗口、各类渠道的限制和超限后的冷却策略
+type QuotaConfig struct {
+	MinuteWindow   Window
+	DayWindow      Window
+	Normal         TierLimits
+	Paid           TierLimits
+	PaidTag        string        // tag 等于该值的渠道视为付费号
+	MinuteCooldown time.Duration // 分钟超限后保持禁用的时长
+}
+
+// LimitsFor 返回渠道所属类别的限制，以及该渠道是否为付费号
+func (q QuotaConfig) LimitsFor(channel Channel) (TierLimits, bool) {
+	if channel.Tag == q.PaidTag {
+		return q.Paid, true
+	}
+	return q.Normal, false
+}
+
+// Config 汇总所有子命令共用的配置，全部来自环境变量
+type Config struct {
+	DBUser     string
+	DBPassword string
+	DBHost     string
+	DBPort     string
+	DBName     string
+
+	Quota    QuotaConfig
+	Location *time.Location
+	Capacity CapacityConfig
+	Cost     CostConfig
+	Latency  LatencyConfig
+	Anomaly  AnomalyConfig
+	Counters CounterConfig
+	Report   ReportConfig
+	SMTP     SMTPConfig
+
+	Labels   ChannelLabels // 自定义标签
+	GroupBy  string        // 面板默认的分组方式
+	PageSize int           // 面板每页显示的渠道数，0 表示不分页
+	Lang     string        // 浏览器没有指定语言时页面使用的语言
+	UI       UIConfig      // 主题、使用率颜色阈值和大屏模式
+
+	AlertWebhookURL string
+	AlertEmail      AlertEmailConfig
+	AlertRules      []AlertRule // 针对每个渠道判断的告警规则
+	SilencesFile    string      // 保存告警屏蔽规则的文件
+
+	ListenAddr       string
+	CollectInterval  time.Duration
+	EnforceInterval  time.Duration
+	HTTPReadTimeout  time.Duration
+	HTTPWriteTimeout time.Duration
+	HTTPIdleTimeout  time.Duration
+	ShutdownTimeout  time.Duration
+
+	Log     LogConfig     // 日志格式和级别
+	Tracing TracingConfig // OpenTelemetry 链路追踪
+
+	DevMode     bool
+	TemplateDir string
+}
+
+// 从环境变量获取配置，如果不存在则使用默认值
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvDuration 从环境变量读取时长配置（如 15s、1m），必须大于 0
+func getEnvDuration(key, defaultValue string) (time.Duration, error) {
+	value := getEnv(key, defaultValue)
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s 配置无效: %q", key, value)
+	}
+	return d, nil
+}
+
+// getEnvInt 从环境变量读取正整数配置
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value := getEnv(key, strconv.Itoa(defaultValue))
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s 配置无效: %q", key, value)
+	}
+	return n, nil
+}
+
+// getEnvCount 从环境变量读取非负整数配置，用于 0 有特殊含义（如不分页）的配置
+func getEnvCount(key string, defaultValue int) (int, error) {
+	value := getEnv(key, strconv.Itoa(defaultValue))
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s 配置无效: %q", key, value)
+	}
+	return n, nil
+}
+
+// getEnvFloat 从环境变量读取非负小数配置
+func getEnvFloat(key string, defaultValue float64) (float64, error) {
+	value := getEnv(key, strconv.FormatFloat(defaultValue, 'f', -1, 64))
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("%s 配置无效: %q", key, value)
+	}
+	return f, nil
+}
+
+// splitList 解析逗号分隔的列表，忽略空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// loadConfig 从环境变量读取配置
+func loadConfig() (*Config, error) {
+	cfg := &Config{
+		DBUser:     getEnv("DB_USER", "root"),
+		DBPassword: getEnv("DB_PASSWORD", ""),
+		DBHost:     getEnv("DB_HOST", "localhost"),
+		DBPort:     getEnv("DB_PORT", "3306"),
+		DBName:     getEnv("DB_NAME", "gemini"),
+		// 监听地址，默认监听所有网卡的 SERVER_PORT，也可以设置为 unix:/path/to.sock
+		ListenAddr:  getEnv("LISTEN_ADDR", ":"+getEnv("SERVER_PORT", "8080")),
+		DevMode:     getEnv("DEV_MODE", "") == "true",
+		TemplateDir: getEnv("TEMPLATE_DIR", ""),
+
+		AlertWebhookURL: getEnv("ALERT_WEBHOOK_URL", ""),
+		SilencesFile:    getEnv("ALERT_SILENCES_FILE", "silences.json"),
+		Report: ReportConfig{
+			Dir:        getEnv("REPORT_DIR", "reports"),
+			EmailTo:    splitList(getEnv("REPORT_EMAIL_TO", "")),
+			WebhookURL: getEnv("REPORT_WEBHOOK_URL", ""),
+		},
+		SMTP: SMTPConfig{
+			Host:     getEnv("SMTP_HOST", ""),
+			Port:     getEnv("SMTP_PORT", "587"),
+			TLS:      getEnv("SMTP_TLS", SMTPStartTLS),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+		},
+	}
+	cfg.SMTP.From = getEnv("SMTP_FROM", cfg.SMTP.Username)
+	if cfg.SMTP.Host != "" && cfg.SMTP.From == "" {
+		return nil, fmt.Errorf("设置 SMTP_HOST 时需要 SMTP_FROM 或 SMTP_USERNAME")
+	}
+	switch cfg.SMTP.TLS {
+	case SMTPStartTLS, SMTPTLS, SMTPPlain:
+	default:
+		return nil, fmt.Errorf("SMTP_TLS 配置无效: %q，可选 starttls、tls 或 none", cfg.SMTP.TLS)
+	}
+	// ALERT_EMAIL_TO 对所有级别生效，ALERT_EMAIL_TO_<级别> 覆盖该级别的收件人，设置为 - 表示该级别不发送
+	defaultRecipients := getEnv("ALERT_EMAIL_TO", "")
+	cfg.AlertEmail.Recipients = make(map[Severity][]string)
+	for _, severity := range []Severity{SeverityInfo, SeverityWarning, SeverityCritical} {
+		value := getEnv("ALERT_EMAIL_TO_"+strings.ToUpper(string(severity)), defaultRecipients)
+		if value != "-" {
+			cfg.AlertEmail.Recipients[severity] = splitList(value)
+		}
+	}
+	var err error
+	if cfg.Labels, err = loadChannelLabels(getEnv("CHANNEL_LABELS_FILE", "")); err != nil {
+		return nil, fmt.Errorf("CHANNEL_LABELS_FILE 配置无效: %w", err)
+	}
+	if cfg.Lang = getEnv("DEFAULT_LANG", LangZH); !supportedLang(cfg.Lang) {
+		return nil, fmt.Errorf("DEFAULT_LANG 配置无效: %q，可选 zh 或 en", cfg.Lang)
+	}
+	cfg.GroupBy = getEnv("GROUP_BY", GroupByNone)
+	if err := validGroupBy(cfg.GroupBy, cfg.Labels.Keys()); err != nil {
+		return nil, fmt.Errorf("GROUP_BY 配置无效: %w", err)
+	}
+	// 未设置 ALERT_RULES_FILE 时使用内置的默认规则
+	rules, err := loadAlertRules(getEnv("ALERT_RULES_FILE", ""))
+	if err != nil {
+		return nil, fmt.Errorf("ALERT_RULES_FILE 配置无效: %w", err)
+	}
+	cfg.AlertRules = rules
+	cfg.Quota.PaidTag = getEnv("PAID_TAG", "gcp")
+	cfg.Cost.Currency = getEnv("COST_CURRENCY", "$")
+
+	// 配额窗口配置：分钟限制默认为过去 60 秒的滚动窗口，天限制默认为每天 08:00 重置的固定窗口
+	if cfg.Quota.MinuteWindow, err = parseWindow(getEnv("MINUTE_WINDOW", "rolling:60s")); err != nil {
+		return nil, fmt.Errorf("MINUTE_WINDOW 配置无效: %w", err)
+	}
+	if cfg.Quota.DayWindow, err = parseWindow(getEnv("DAY_WINDOW", "fixed:24h@08:00")); err != nil {
+		return nil, fmt.Errorf("DAY_WINDOW 配置无效: %w", err)
+	}
+	if cfg.Location, err = time.LoadLocation(getEnv("TIMEZONE", "Local")); err != nil {
+		return nil, fmt.Errorf("TIMEZONE 配置无效: %w", err)
+	}
+	// 价格为每百万 token 的价格，默认只包含 Gemini 2.5 Pro 的标准价格
+	if cfg.Cost.Prices, err = parsePriceTable(getEnv("MODEL_PRICES", "gemini-2.5-pro*=1.25/10")); err != nil {
+		return nil, fmt.Errorf("MODEL_PRICES 配置无效: %w", err)
+	}
+	if cfg.Latency.Windows, err = parseDurations(getEnv("LATENCY_WINDOWS", "1h,24h")); err != nil {
+		return nil, fmt.Errorf("LATENCY_WINDOWS 配置无效: %w", err)
+	}
+	if cfg.Latency.SlowFactor, err = getEnvFloat("LATENCY_SLOW_FACTOR", 1.5); err != nil {
+		return nil, err
+	}
+	if cfg.Anomaly.Alpha, err = getEnvFloat("ANOMALY_EWMA_ALPHA", 0.3); err != nil {
+		return nil, err
+	}
+	if cfg.Anomaly.SpikeFactor, err = getEnvFloat("ANOMALY_SPIKE_FACTOR", 3); err != nil {
+		return nil, err
+	}
+	if cfg.Anomaly.DropFactor, err = getEnvFloat("ANOMALY_DROP_FACTOR", 0.3); err != nil {
+		return nil, err
+	}
+	if cfg.Anomaly.MinExpected, err = getEnvFloat("ANOMALY_MIN_EXPECTED", 20); err != nil {
+		return nil, err
+	}
+	if cfg.Cost.DailyBudget, err = getEnvFloat("DAILY_BUDGET", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Cost.MonthlyBudget, err = getEnvFloat("MONTHLY_BUDGET", 0); err != nil {
+		return nil, err
+	}
+	if cfg.UI.Thresholds.Warn, err = getEnvFloat("USAGE_WARN_PERCENT", 50); err != nil {
+		return nil, err
+	}
+	if cfg.UI.Thresholds.Critical, err = getEnvFloat("USAGE_CRITICAL_PERCENT", 80); err != nil {
+		return nil, err
+	}
+	if err := cfg.UI.Thresholds.validate(); err != nil {
+		return nil, err
+	}
+	switch cfg.Log.Format = getEnv("LOG_FORMAT", LogFormatJSON); cfg.Log.Format {
+	case LogFormatJSON, LogFormatText:
+	default:
+		return nil, fmt.Errorf("LOG_FORMAT 配置无效: %q，可选 json 或 text", cfg.Log.Format)
+	}
+	if cfg.Log.Level, err = parseLogLevel(getEnv("LOG_LEVEL", "info")); err != nil {
+		return nil, fmt.Errorf("LOG_LEVEL 配置无效: %w", err)
+	}
+	// 使用 OpenTelemetry 的标准环境变量，只导出 trace 时可以单独设置 OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+	cfg.Tracing.Endpoint = getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""))
+	if cfg.UI.Theme = getEnv("THEME", ThemeLight); !validTheme(cfg.UI.Theme) {
+		return nil, fmt.Errorf("THEME 配置无效: %q，可选 light、dark 或 auto", cfg.UI.Theme)
+	}
+	switch cfg.Cost.BudgetAction = getEnv("BUDGET_ACTION", BudgetActionAlert); cfg.Cost.BudgetAction {
+	case BudgetActionAlert, BudgetActionDisable:
+	default:
+		return nil, fmt.Errorf("BUDGET_ACTION 配置无效: %q，可选 alert 或 disable", cfg.Cost.BudgetAction)
+	}
+
+	ints := []struct {
+		key          string
+		defaultValue int
+		dst          *int
+	}{
+		{"NORMAL_MINUTE_LIMIT", 5, &cfg.Quota.Normal.Minute},
+		{"NORMAL_DAY_LIMIT", 25, &cfg.Quota.Normal.Day},
+		{"PAID_MINUTE_LIMIT", 20, &cfg.Quota.Paid.Minute},
+		{"PAID_DAY_LIMIT", 100, &cfg.Quota.Paid.Day},
+		{"LATENCY_MIN_SAMPLES", 20, &cfg.Latency.MinSamples},
+		{"COUNTER_DRIFT_TOLERANCE", 5, &cfg.Counters.DriftTolerance},
+		{"CAPACITY_HISTORY_DAYS", 14, &cfg.Capacity.HistoryDays},
+		{"CAPACITY_HEADROOM_PERCENT", 20, &cfg.Capacity.HeadroomPercent},
+	}
+	for _, item := range ints {
+		if *item.dst, err = getEnvInt(item.key, item.defaultValue); err != nil {
+			return nil, err
+		}
+	}
+	// 以下配置为 0 时关闭对应的功能
+	counts := []struct {
+		key          string
+		defaultValue int
+		dst          *int
+	}{
+		{"PAGE_SIZE", 100, &cfg.PageSize},
+		{"ANOMALY_BASELINE_DAYS", 7, &cfg.Anomaly.BaselineDays},
+	}
+	for _, item := range counts {
+		if *item.dst, err = getEnvCount(item.key, item.defaultValue); err != nil {
+			return nil, err
+		}
+	}
+
+	durations := []struct {
+		key          string
+		defaultValue string
+		dst          *time.Duration
+	}{
+		{"MINUTE_COOLDOWN", "5m", &cfg.Quota.MinuteCooldown},
+		{"COLLECT_INTERVAL", "15s", &cfg.CollectInterval},
+		{"ENFORCE_INTERVAL", "1m", &cfg.EnforceInterval},
+		{"COST_INTERVAL", "5m", &cfg.Cost.Interval},
+		{"LATENCY_INTERVAL", "1m", &cfg.Latency.Interval},
+		{"ANOMALY_INTERVAL", "1m", &cfg.Anomaly.Interval},
+		{"COUNTER_STALE_AFTER", "5m", &cfg.Counters.StaleAfter},
+		{"ALERT_EMAIL_BATCH", "1m", &cfg.AlertEmail.BatchWindow},
+		{"HTTP_READ_TIMEOUT", "10s", &cfg.HTTPReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", "30s", &cfg.HTTPWriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", "60s", &cfg.HTTPIdleTimeout},
+		{"SHUTDOWN_TIMEOUT", "15s", &cfg.ShutdownTimeout},
+		{"KIOSK_INTERVAL", "20s", &cfg.UI.KioskInterval},
+	}
+	for _, item := range durations {
+		if *item.dst, err = getEnvDuration(item.key, item.defaultValue); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.Anomaly.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Counters.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// DSN 返回 MySQL 连接字符串
+func (c *Config) DSN() string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s",
+		c.DBUser,
+		c.DBPassword,
+		c.DBHost,
+		c.DBPort,
+		c.DBName)
+}
+
+// Summary 返回不含密码等敏感信息的配置摘要，用于 /version
+func (c *Config) Summary() map[string]string {
+	return map[string]string{
+		"db_host":          c.DBHost,
+		"db_port":          c.DBPort,
+		"db_name":          c.DBName,
+		"db_user":          c.DBUser,
+		"listen_addr":      c.ListenAddr,
+		"minute_window":    c.Quota.MinuteWindow.String(),
+		"day_window":       c.Quota.DayWindow.String(),
+		"timezone":         c.Location.String(),
+		"collect_interval": c.CollectInterval.String(),
+		"paid_tag":         c.Quota.PaidTag,
+		"normal_limits":    fmt.Sprintf("%d/min, %d/day", c.Quota.Normal.Minute, c.Quota.Normal.Day),
+		"paid_limits":      fmt.Sprintf("%d/min, %d/day", c.Quota.Paid.Minute, c.Quota.Paid.Day),
+		"minute_cooldown":  c.Quota.MinuteCooldown.String(),
+		"model_prices":     c.Cost.Prices.String(),
+		"budget":           fmt.Sprintf("%s%g/day, %s%g/month, %s", c.Cost.Currency, c.Cost.DailyBudget, c.Cost.Currency, c.Cost.MonthlyBudget, c.Cost.BudgetAction),
+		"latency":          fmt.Sprintf("%v, slow factor %g, min %d samples", c.Latency.Windows, c.Latency.SlowFactor, c.Latency.MinSamples),
+		"anomaly":          fmt.Sprintf("%d days, alpha %g, spike x%g, drop x%g, min %g expected", c.Anomaly.BaselineDays, c.Anomaly.Alpha, c.Anomaly.SpikeFactor, c.Anomaly.DropFactor, c.Anomaly.MinExpected),
+		"counters":         fmt.Sprintf("stale after %s, drift tolerance %d", c.Counters.StaleAfter, c.Counters.DriftTolerance),
+		"log":              fmt.Sprintf("%s, %s", c.Log.Format, c.Log.Level),
+		"tracing":          c.Tracing.Endpoint,
+		"labels":           strings.Join(c.Labels.Keys(), ","),
+		"group_by":         c.GroupBy,
+		"page_size":        strconv.Itoa(c.PageSize),
+		"default_lang":     c.Lang,
+		"theme":            c.UI.Theme,
+		"usage_thresholds": fmt.Sprintf("warn %g%%, critical %g%%", c.UI.Thresholds.Warn, c.UI.Thresholds.Critical),
+		"kiosk_interval":   c.UI.KioskInterval.String(),
+		"alert_webhook":    strconv.FormatBool(c.AlertWebhookURL != ""),
+		"report_dir":       c.Report.Dir,
+		"report_email_to":  strings.Join(c.Report.EmailTo, ","),
+		"report_webhook":   strconv.FormatBool(c.Report.WebhookURL != ""),
+		"smtp_host":        c.SMTP.Host,
+		"smtp_tls":         c.SMTP.TLS,
+		"alert_email":      strconv.FormatBool(c.AlertEmail.Enabled()),
+		"alert_rules":      strconv.Itoa(len(c.AlertRules)),
+		"silences_file":    c.SilencesFile,
+		"capacity":         fmt.Sprintf("%d days, %d%% headroom", c.Capacity.HistoryDays, c.Capacity.HeadroomPercent),
+		"dev_mode":         strconv.FormatBool(c.DevMode),
+		"template_dir":     c.TemplateDir,
+	}
+}
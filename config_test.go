@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestLoadConfigCounts(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		ok    bool
+		get   func(*Config) int
+		want  int
+	}{
+		{"PAGE_SIZE", "", true, func(c *Config) int { return c.PageSize }, 100},
+		{"PAGE_SIZE", "0", true, func(c *Config) int { return c.PageSize }, 0},
+		{"PAGE_SIZE", "-1", false, nil, 0},
+		{"PAGE_SIZE", "all", false, nil, 0},
+		{"ANOMALY_BASELINE_DAYS", "", true, func(c *Config) int { return c.Anomaly.BaselineDays }, 7},
+		{"ANOMALY_BASELINE_DAYS", "0", true, func(c *Config) int { return c.Anomaly.BaselineDays }, 0},
+		{"ANOMALY_BASELINE_DAYS", "-1", false, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			cfg, err := loadConfig()
+			if (err == nil) != tt.ok {
+				t.Fatalf("err = %v, want ok = %v", err, tt.ok)
+			}
+			if tt.ok && tt.get(cfg) != tt.want {
+				t.Errorf("got %d, want %d", tt.get(cfg), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModelPrice 模型每百万 token 的价格
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Cost 返回给定 token 数的花费
+func (p ModelPrice) Cost(promptTokens, completionTokens int64) float64 {
+	return (float64(promptTokens)*p.Input + float64(completionTokens)*p.Output) / 1e6
+}
+
+// priceRule 价格表中的一项，pattern 以 * 结尾时按前缀匹配，单独的 * 匹配所有模型
+type priceRule struct {
+	pattern string
+	price   ModelPrice
+}
+
+// PriceTable 按模型名查找价格
+type PriceTable struct {
+	rules []priceRule
+}
+
+// parsePriceTable 解析价格表配置，格式为逗号分隔的 模型=输入价格/输出价格，例如
+//
+//	gemini-2.5-pro*=1.25/10,gemini-2.5-flash=0.3/2.5,*=0/0
+func parsePriceTable(spec string) (PriceTable, error) {
+	var table PriceTable
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		pattern, prices, ok := strings.Cut(item, "=")
+		input, output, ok2 := strings.Cut(prices, "/")
+		if !ok || !ok2 || strings.TrimSpace(pattern) == "" {
+			return PriceTable{}, fmt.Errorf("价格配置 %q 的格式应为 模型=输入价格/输出价格", item)
+		}
+		var price ModelPrice
+		var err error
+		if price.Input, err = strconv.ParseFloat(strings.TrimSpace(input), 64); err != nil || price.Input < 0 {
+			return PriceTable{}, fmt.Errorf("价格配置 %q 的输入价格无效", item)
+		}
+		if price.Output, err = strconv.ParseFloat(strings.TrimSpace(output), 64); err != nil || price.Output < 0 {
+			return PriceTable{}, fmt.Errorf("价格配置 %q 的输出价格无效", item)
+		}
+		table.rules = append(table.rules, priceRule{pattern: strings.TrimSpace(pattern), price: price})
+	}
+	return table, nil
+}
+
+// Lookup 返回模型的价格：精确匹配优先，其次是最长的前缀匹配
+func (t PriceTable) Lookup(model string) (ModelPrice, bool) {
+	var best *priceRule
+	for i, rule := range t.rules {
+		if rule.pattern == model {
+			return rule.price, true
+		}
+		prefix, isPrefix := strings.CutSuffix(rule.pattern, "*")
+		if isPrefix && strings.HasPrefix(model, prefix) && (best == nil || len(prefix) >= len(best.pattern)) {
+			best = &t.rules[i]
+		}
+	}
+	if best == nil {
+		return ModelPrice{}, false
+	}
+	return best.price, true
+}
+
+// String 返回与配置格式相同的价格表
+func (t PriceTable) String() string {
+	items := make([]string, len(t.rules))
+	for i, rule := range t.rules {
+		items[i] = fmt.Sprintf("%s=%g/%g", rule.pattern, rule.price.Input, rule.price.Output)
+	}
+	return strings.Join(items, ",")
+}
+
+// 超出预算时的处理方式
+const (
+	BudgetActionAlert   = "alert"   // 只发送告警
+	BudgetActionDisable = "disable" // 发送告警并禁用所有付费号，直到预算周期结束
+)
+
+// CostConfig 花费估算和预算的配置
+type CostConfig struct {
+	Prices        PriceTable
+	Currency      string
+	DailyBudget   float64 // 付费号每天的预算，0 表示不限制
+	MonthlyBudget float64 // 付费号每月的预算，0 表示不限制
+	BudgetAction  string
+	Interval      time.Duration
+}
+
+// TierCost 按类别汇总的花费
+type TierCost struct {
+	Paid   float64 `json:"paid"`
+	Normal float64 `json:"normal"`
+	Total  float64 `json:"total"`
+}
+
+func (c *TierCost) add(cost float64, isPaid bool) {
+	if isPaid {
+		c.Paid += cost
+	} else {
+		c.Normal += cost
+	}
+	c.Total += cost
+}
+
+// ChannelCost 单个渠道的花费
+type ChannelCost struct {
+	ChannelID   int     `json:"channel_id"`
+	IsPaid      bool    `json:"is_paid"`
+	Today       float64 `json:"today"`
+	MonthToDate float64 `json:"month_to_date"`
+}
+
+// DayCost 一个天窗口内的花费
+type DayCost struct {
+	Start time.Time `json:"start"`
+	TierCost
+}
+
+// CostReport 花费估算结果，预算只针对付费号
+type CostReport struct {
+	Currency                string        `json:"currency"`
+	Today                   TierCost      `json:"today"`
+	MonthToDate             TierCost      `json:"month_to_date"`
+	DailyBudget             float64       `json:"daily_budget"`
+	MonthlyBudget           float64       `json:"monthly_budget"`
+	DailyBudgetPercentage   float64       `json:"daily_budget_percentage"`
+	MonthlyBudgetPercentage float64       `json:"monthly_budget_percentage"`
+	OverDailyBudget         bool          `json:"over_daily_budget"`
+	OverMonthlyBudget       bool          `json:"over_monthly_budget"`
+	Channels                []ChannelCost `json:"channels"`
+	Days                    []DayCost     `json:"days"`
+	UnpricedModels          []string      `json:"unpriced_models"` // 价格表中找不到、未计入花费的模型
+	DayStart                time.Time     `json:"day_start"`
+	MonthStart              time.Time     `json:"month_start"`
+	GeneratedAt             time.Time     `json:"generated_at"`
+}
+
+// costPeriods 返回汇总花费使用的天窗口，以及当前天窗口和本月的起点。
+// 本月从当月 1 日的天窗口起点算起，例如天窗口为 fixed:24h@08:00 时从 1 日 08:00 开始
+func costPeriods(quota QuotaConfig, now time.Time) (dayWindow Window, dayStart, monthStart time.Time) {
+	dayWindow = demandDayWindow(quota.DayWindow)
+	dayStart = dayWindow.Start(now)
+	y, m, _ := dayStart.Date()
+	monthStart = dayWindow.Start(time.Date(y, m, 1, 0, 0, 0, 0, now.Location()).Add(dayWindow.Offset))
+	return dayWindow, dayStart, monthStart
+}
+
+// budgetPercentage 返回花费占预算的百分比，最大 100，预算为 0 时返回 0
+func budgetPercentage(spent, budget float64) float64 {
+	if budget <= 0 {
+		return 0
+	}
+	return min(spent/budget*100, 100)
+}
+
+// buildCostReport 按价格表计算每个渠道、每个类别、每天的花费，tokens 的分桶长度为 dayWindow.Length，从 monthStart 开始
+func buildCostReport(tokens []TokenCounts, channels []Channel, quota QuotaConfig, config CostConfig, now time.Time) *CostReport {
+	dayWindow, dayStart, monthStart := costPeriods(quota, now)
+	report := &CostReport{
+		Currency:      config.Currency,
+		DailyBudget:   config.DailyBudget,
+		MonthlyBudget: config.MonthlyBudget,
+		DayStart:      dayStart,
+		MonthStart:    monthStart,
+		GeneratedAt:   now,
+	}
+
+	byChannel := make(map[int]*ChannelCost, len(channels))
+	for _, channel := range channels {
+		_, isPaid := quota.LimitsFor(channel)
+		byChannel[channel.ID] = &ChannelCost{ChannelID: channel.ID, IsPaid: isPaid}
+	}
+	days := make(map[int64]*DayCost)
+	for start := monthStart; !start.After(dayStart); start = start.Add(dayWindow.Length) {
+		days[start.Unix()] = &DayCost{Start: start}
+	}
+	unpriced := make(map[string]bool)
+
+	for _, counts := range tokens {
+		price, ok := config.Prices.Lookup(counts.Model)
+		if !ok {
+			if counts.PromptTokens > 0 || counts.CompletionTokens > 0 {
+				unpriced[counts.Model] = true
+			}
+			continue
+		}
+		cost := price.Cost(counts.PromptTokens, counts.CompletionTokens)
+
+		// 已删除的渠道仍计入花费，按普号处理
+		channel, ok := byChannel[counts.ChannelID]
+		if !ok {
+			channel = &ChannelCost{ChannelID: counts.ChannelID}
+			byChannel[counts.ChannelID] = channel
+		}
+		channel.MonthToDate += cost
+		report.MonthToDate.add(cost, channel.IsPaid)
+		if counts.Bucket >= dayStart.Unix() {
+			channel.Today += cost
+			report.Today.add(cost, channel.IsPaid)
+		}
+		day, ok := days[counts.Bucket]
+		if !ok {
+			day = &DayCost{Start: time.Unix(counts.Bucket, 0).In(now.Location())}
+			days[counts.Bucket] = day
+		}
+		day.add(cost, channel.IsPaid)
+	}
+
+	for _, channel := range byChannel {
+		report.Channels = append(report.Channels, *channel)
+	}
+	sort.Slice(report.Channels, func(i, j int) bool {
+		if report.Channels[i].MonthToDate != report.Channels[j].MonthToDate {
+			return report.Channels[i].MonthToDate > report.Channels[j].MonthToDate
+		}
+		return report.Channels[i].ChannelID < report.Channels[j].ChannelID
+	})
+	for _, day := range days {
+		report.Days = append(report.Days, *day)
+	}
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Start.Before(report.Days[j].Start) })
+	for model := range unpriced {
+		report.UnpricedModels = append(report.UnpricedModels, model)
+	}
+	sort.Strings(report.UnpricedModels)
+
+	report.DailyBudgetPercentage = budgetPercentage(report.Today.Paid, config.DailyBudget)
+	report.MonthlyBudgetPercentage = budgetPercentage(report.MonthToDate.Paid, config.MonthlyBudget)
+	report.OverDailyBudget = config.DailyBudget > 0 && report.Today.Paid >= config.DailyBudget
+	report.OverMonthlyBudget = config.MonthlyBudget > 0 && report.MonthToDate.Paid >= config.MonthlyBudget
+	return report
+}
+
+// applyCost 把花费填入面板数据
+func applyCost(snapshot *Snapshot, report *CostReport) {
+	snapshot.Cost = report
+	byChannel := make(map[int]ChannelCost, len(report.Channels))
+	for _, channel := range report.Channels {
+		byChannel[channel.ChannelID] = channel
+	}
+	for i := range snapshot.Channels {
+		cost := byChannel[snapshot.Channels[i].ID]
+		snapshot.Channels[i].TodayCost = cost.Today
+		snapshot.Channels[i].MonthCost = cost.MonthToDate
+	}
+}
+
+// CostTracker 在后台定期估算花费，并在付费号超出预算时告警或禁用付费号
+type CostTracker struct {
+	store    Store
+	quota    QuotaConfig
+	location *time.Location
+	config   CostConfig
+	alerter  *Alerter
+
+	mu     sync.RWMutex
+	report *CostReport
+}
+
+// NewCostTracker 创建花费估算器
+func NewCostTracker(store Store, quota QuotaConfig, location *time.Location, config CostConfig, alerter *Alerter) *CostTracker {
+	return &CostTracker{store: store, quota: quota, location: location, config: config, alerter: alerter}
+}
+
+// Run 立即估算一次，然后按间隔循环估算，直到 ctx 被取消
+func (t *CostTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := t.Refresh(ctx); err != nil {
+			slog.ErrorContext(ctx, "估算花费失败", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh 立即估算一次花费并检查预算
+func (t *CostTracker) Refresh(ctx context.Context) (*CostReport, error) {
+	now := time.Now().In(t.location)
+	dayWindow, _, monthStart := costPeriods(t.quota, now)
+	tokens, err := t.store.Tokens(ctx, monthStart, now, dayWindow.Length)
+	if err != nil {
+		return nil, fmt.Errorf("统计 token 失败: %w", err)
+	}
+	channels, err := t.store.Channels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询渠道失败: %w", err)
+	}
+	report := buildCostReport(tokens, channels, t.quota, t.config, now)
+
+	t.mu.Lock()
+	t.report = report
+	t.mu.Unlock()
+
+	if err := t.checkBudget(ctx, report, channels); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// Latest 返回最近一次的估算结果，尚未估算成功时返回 nil
+func (t *CostTracker) Latest() *CostReport {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.report
+}
+
+// checkBudget 超出预算时发送告警，BudgetAction 为 disable 时禁用所有付费号直到预算周期结束
+func (t *CostTracker) checkBudget(ctx context.Context, report *CostReport, channels []Channel) error {
+	// 需要禁用付费号时在告警中列出受影响的渠道
+	disable := t.config.BudgetAction == BudgetActionDisable
+	var ids []int
+	if disable {
+		for _, channel := range channels {
+			if _, isPaid := t.quota.LimitsFor(channel); isPaid {
+				ids = append(ids, channel.ID)
+			}
+		}
+	}
+
+	currency := t.config.Currency
+	t.alerter.Update(ctx, report.OverDailyBudget, Alert{
+		Key:      "budget:daily",
+		Severity: SeverityCritical,
+		Title:    "付费号今日花费超出预算",
+		Message:  fmt.Sprintf("今日花费 %s%.2f，预算 %s%.2f", currency, report.Today.Paid, currency, report.DailyBudget),
+		Channels: ids,
+	})
+	t.alerter.Update(ctx, report.OverMonthlyBudget, Alert{
+		Key:      "budget:monthly",
+		Severity: SeverityCritical,
+		Title:    "付费号本月花费超出预算",
+		Message:  fmt.Sprintf("本月花费 %s%.2f，预算 %s%.2f", currency, report.MonthToDate.Paid, currency, report.MonthlyBudget),
+		Channels: ids,
+	})
+	if !disable || len(ids) == 0 || (!report.OverDailyBudget && !report.OverMonthlyBudget) {
+		return nil
+	}
+
+	// 天预算超出时禁用到下一个天窗口，月预算超出时禁用到下个月
+	dayWindow, dayStart, monthStart := costPeriods(t.quota, report.GeneratedAt)
+	var until time.Time
+	if report.OverDailyBudget {
+		until = dayStart.Add(dayWindow.Length)
+	}
+	if report.OverMonthlyBudget {
+		until = dayWindow.Start(monthStart.AddDate(0, 1, 0))
+	}
+	if err := t.store.DisableChannels(ctx, ids, Cooldown{Reason: "budget", Until: until.Unix()}); err != nil {
+		return fmt.Errorf("禁用付费号失败: %w", err)
+	}
+	return nil
+}
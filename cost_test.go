@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPriceTableLookup(t *testing.T) {
+	table, err := parsePriceTable("gemini-2.5-pro*=1.25/10, gemini-2.5-pro-long=2.5/15, gemini*=0.1/0.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		model string
+		want  ModelPrice
+		ok    bool
+	}{
+		{"gemini-2.5-pro", ModelPrice{1.25, 10}, true},
+		{"gemini-2.5-pro-preview-06-05", ModelPrice{1.25, 10}, true},
+		{"gemini-2.5-pro-long", ModelPrice{2.5, 15}, true},
+		{"gemini-2.5-flash", ModelPrice{0.1, 0.4}, true},
+		{"gpt-4o", ModelPrice{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := table.Lookup(tt.model)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Lookup(%q) = %v, %v, want %v, %v", tt.model, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	for _, spec := range []string{"gemini", "gemini=1", "=1/2", "gemini=a/2", "gemini=1/-2"} {
+		if _, err := parsePriceTable(spec); err == nil {
+			t.Errorf("parsePriceTable(%q) 应当返回错误", spec)
+		}
+	}
+}
+
+// costTestConfig 付费号每天预算 $10，每月 $100
+func costTestConfig() CostConfig {
+	prices, _ := parsePriceTable("gemini-2.5-pro*=1.25/10")
+	return CostConfig{
+		Prices:        prices,
+		Currency:      "$",
+		DailyBudget:   10,
+		MonthlyBudget: 100,
+		BudgetAction:  BudgetActionDisable,
+		Interval:      time.Minute,
+	}
+}
+
+// costTestStore 付费号 1 今天花费 $12.5，10 月 1 日花费 $10；普号 2 今天花费 $1.25
+func costTestStore() *MemoryStore {
+	at := func(day, hour int) int64 {
+		return time.Date(2026, 10, day, hour, 0, 0, 0, testNow.Location()).Unix()
+	}
+	return NewMemoryStore([]Channel{
+		{ID: 1, Status: "1", Tag: "gcp"},
+		{ID: 2, Status: "1"},
+	}, []LogEntry{
+		{ChannelID: 1, CreatedAt: at(1, 7), Model: "gemini-2.5-pro", PromptTokens: 8_000_000}, // 9 月的天窗口
+		{ChannelID: 1, CreatedAt: at(1, 9), Model: "gemini-2.5-pro", PromptTokens: 8_000_000},
+		{ChannelID: 1, CreatedAt: at(18, 8), Model: "gemini-2.5-pro", CompletionTokens: 1_000_000},
+		{ChannelID: 1, CreatedAt: at(18, 9), Model: "gemini-2.5-pro-preview", PromptTokens: 2_000_000},
+		{ChannelID: 2, CreatedAt: at(18, 9), Model: "gemini-2.5-pro", PromptTokens: 1_000_000},
+		{ChannelID: 2, CreatedAt: at(18, 9), Model: "unknown-model", PromptTokens: 1_000_000},
+	})
+}
+
+func TestBuildCostReport(t *testing.T) {
+	store := costTestStore()
+	dayWindow, dayStart, monthStart := costPeriods(testQuota, testNow)
+	if want := time.Date(2026, 10, 1, 8, 0, 0, 0, testNow.Location()); !monthStart.Equal(want) {
+		t.Fatalf("monthStart = %v, want %v", monthStart, want)
+	}
+	tokens, err := store.Tokens(context.Background(), monthStart, testNow, dayWindow.Length)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := buildCostReport(tokens, store.channels, testQuota, costTestConfig(), testNow)
+
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"Today.Paid", report.Today.Paid, 12.5},
+		{"Today.Normal", report.Today.Normal, 1.25},
+		{"Today.Total", report.Today.Total, 13.75},
+		{"MonthToDate.Paid", report.MonthToDate.Paid, 22.5},
+		{"DailyBudgetPercentage", report.DailyBudgetPercentage, 100},
+		{"MonthlyBudgetPercentage", report.MonthlyBudgetPercentage, 22.5},
+	}
+	for _, c := range checks {
+		if !near(c.got, c.want) {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+	if !report.OverDailyBudget || report.OverMonthlyBudget {
+		t.Errorf("OverDailyBudget = %v, OverMonthlyBudget = %v", report.OverDailyBudget, report.OverMonthlyBudget)
+	}
+	if len(report.Days) != 18 || !report.Days[17].Start.Equal(dayStart) || !near(report.Days[0].Paid, 10) {
+		t.Errorf("Days = %+v", report.Days)
+	}
+	if len(report.Channels) != 2 || report.Channels[0].ChannelID != 1 || !near(report.Channels[0].Today, 12.5) {
+		t.Errorf("Channels = %+v", report.Channels)
+	}
+	if len(report.UnpricedModels) != 1 || report.UnpricedModels[0] != "unknown-model" {
+		t.Errorf("UnpricedModels = %v", report.UnpricedModels)
+	}
+
+	// 面板上的花费
+	snapshot := goldenSnapshot()
+	applyCost(snapshot, report)
+	renderer, err := NewRenderer(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, "index.html", queried(t, snapshot, "")); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"$12.50 / $10.00", "花费：今日 $12.50 · 本月 $22.50", "未计入花费：unknown-model"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("页面中缺少 %q", want)
+		}
+	}
+}
+
+// recordingNotifier 记录收到的告警
+type recordingNotifier struct{ alerts []Alert }
+
+func (n *recordingNotifier) Notify(ctx context.Context, alert Alert) error {
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+func TestCostTrackerDisablesPaidChannels(t *testing.T) {
+	store := costTestStore()
+	notifier := &recordingNotifier{}
+	tracker := NewCostTracker(store, testQuota, testNow.Location(), costTestConfig(), NewAlerter(notifier))
+	dayWindow, _, monthStart := costPeriods(testQuota, testNow)
+	tokens, _ := store.Tokens(context.Background(), monthStart, testNow, dayWindow.Length)
+	report := buildCostReport(tokens, store.channels, testQuota, costTestConfig(), testNow)
+
+	if err := tracker.checkBudget(context.Background(), report, store.channels); err != nil {
+		t.Fatal(err)
+	}
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Key != "budget:daily" {
+		t.Fatalf("alerts = %+v", notifier.alerts)
+	}
+	channels, _ := store.Channels(context.Background())
+	wantUntil := time.Date(2026, 10, 19, 8, 0, 0, 0, testNow.Location()).Unix()
+	if channels[0].Status != "2" || channels[0].CooldownReason.String != "budget" || channels[0].DisabledUntil.Int64 != wantUntil {
+		t.Errorf("付费号 = %+v，应被禁用到下一个天窗口", channels[0])
+	}
+	if channels[1].Status != "1" || channels[1].DisabledUntil.Valid {
+		t.Errorf("普号 = %+v，不应被禁用", channels[1])
+	}
+
+	// 禁用后的渠道显示为超出预算
+	view := buildSnapshot(channels, nil, testQuota, testNow).Channels[0]
+	if !view.IsCoolingDown || view.CooldownReason != "超出预算" {
+		t.Errorf("view = %+v", view)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CounterConfig 检查 channels 表中计数（count_minute_usage、count_day_usage）的配置
+type CounterConfig struct {
+	StaleAfter     time.Duration // 计数超过该时长没有更新视为停止更新，为 0 时不检查
+	DriftTolerance int           // 计数与日志统计相差超过该值视为不一致
+}
+
+func (c CounterConfig) validate() error {
+	switch {
+	case c.StaleAfter < 0:
+		return fmt.Errorf("COUNTER_STALE_AFTER 不能为负数: %s", c.StaleAfter)
+	case c.DriftTolerance < 0:
+		return fmt.Errorf("COUNTER_DRIFT_TOLERANCE 不能为负数: %d", c.DriftTolerance)
+	}
+	return nil
+}
+
+// 最近一次更新时间的来源
+const (
+	CounterSourceHeartbeat = "heartbeat" // 存储过程写入心跳表的时间
+	CounterSourceObserved  = "observed"  // 旧版本存储过程没有心跳表，使用监控观察到计数变化的时间
+)
+
+// CounterDrift 某个渠道的计数与日志统计不一致
+type CounterDrift struct {
+	ChannelID     int `json:"channel_id"`
+	CounterMinute int `json:"counter_minute"`
+	LogsMinute    int `json:"logs_minute"`
+	CounterDay    int `json:"counter_day"`
+	LogsDay       int `json:"logs_day"`
+}
+
+// CounterStatus 一次计数检查的结果
+type CounterStatus struct {
+	CheckedAt time.Time      `json:"checked_at"`
+	UpdatedAt *time.Time     `json:"updated_at,omitempty"` // 最近一次更新计数的时间，未知时为 nil
+	Source    string         `json:"source"`
+	Stale     bool           `json:"stale"`           // 超过 StaleAfter 没有更新
+	Drift     []CounterDrift `json:"drift,omitempty"` // 计数与日志统计不一致的渠道
+}
+
+// Healthy 判断计数是否在正常更新且与日志一致
+func (s *CounterStatus) Healthy() bool {
+	return !s.Stale && len(s.Drift) == 0
+}
+
+// AgeSeconds 返回距最近一次更新的秒数，更新时间未知时返回 0
+func (s *CounterStatus) AgeSeconds() int64 {
+	if s.UpdatedAt == nil {
+		return 0
+	}
+	return int64(s.CheckedAt.Sub(*s.UpdatedAt).Seconds())
+}
+
+// countersOf 返回渠道记录中存储过程写入的计数
+func countersOf(channels []Channel) map[int]UsageCounts {
+	counters := make(map[int]UsageCounts, len(channels))
+	for _, channel := range channels {
+		counters[channel.ID] = UsageCounts{Minute: channel.CountMinuteUsage, Day: channel.CountDayUsage}
+	}
+	return counters
+}
+
+// counterDrift 列出计数与日志统计相差超过 tolerance 的渠道，按 ID 排序
+func counterDrift(counters, logs map[int]UsageCounts, tolerance int) []CounterDrift {
+	var drift []CounterDrift
+	for id, counter := range counters {
+		actual := logs[id]
+		if abs(counter.Minute-actual.Minute) > tolerance || abs(counter.Day-actual.Day) > tolerance {
+			drift = append(drift, CounterDrift{
+				ChannelID:     id,
+				CounterMinute: counter.Minute,
+				LogsMinute:    actual.Minute,
+				CounterDay:    counter.Day,
+				LogsDay:       actual.Day,
+			})
+		}
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].ChannelID < drift[j].ChannelID })
+	return drift
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// CounterChecker 在每次采集时把 channels 表中的计数与 logs 表的统计对照，
+// 发现存储过程停止运行（计数不再更新）或计数与日志不一致时告警
+type CounterChecker struct {
+	store   Store
+	quota   QuotaConfig
+	config  CounterConfig
+	alerter *Alerter
+
+	mu     sync.RWMutex
+	status *CounterStatus
+
+	// checking 保证同时只有一次检查，页面上手动刷新时也会触发采集
+	checking sync.Mutex
+	// 心跳时刻的日志统计，心跳不变时不重复查询
+	heartbeat     time.Time
+	heartbeatLogs map[int]UsageCounts
+	// 没有心跳表时，记录上一次看到的计数和计数最近一次变化的时间
+	lastCounters map[int]UsageCounts
+	lastChange   time.Time
+}
+
+// NewCounterChecker 创建计数检查器
+func NewCounterChecker(store Store, quota QuotaConfig, config CounterConfig, alerter *Alerter) *CounterChecker {
+	return &CounterChecker{store: store, quota: quota, config: config, alerter: alerter}
+}
+
+// Check 检查采集到的渠道计数，live 为截至 now 的日志统计；StaleAfter 为 0 时不检查，返回 nil
+func (c *CounterChecker) Check(ctx context.Context, channels []Channel, live map[int]UsageCounts, now time.Time) (*CounterStatus, error) {
+	if c.config.StaleAfter == 0 {
+		return nil, nil
+	}
+	c.checking.Lock()
+	defer c.checking.Unlock()
+
+	heartbeat, err := c.store.CountersUpdatedAt(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询计数更新时间失败: %w", err)
+	}
+	counters := countersOf(channels)
+	status := &CounterStatus{CheckedAt: now}
+
+	if !heartbeat.IsZero() {
+		// 计数是心跳时刻的统计结果，与同一时刻的日志统计比较
+		heartbeat = heartbeat.In(now.Location())
+		if !heartbeat.Equal(c.heartbeat) {
+			logs, err := c.store.Usage(ctx, c.quota.MinuteWindow.Start(heartbeat), c.quota.DayWindow.Start(heartbeat), heartbeat)
+			if err != nil {
+				return nil, fmt.Errorf("统计心跳时刻的日志失败: %w", err)
+			}
+			c.heartbeat, c.heartbeatLogs = heartbeat, logs
+		}
+		status.Source = CounterSourceHeartbeat
+		status.UpdatedAt = &heartbeat
+		status.Stale = now.Sub(heartbeat) > c.config.StaleAfter
+		status.Drift = counterDrift(counters, c.heartbeatLogs, c.config.DriftTolerance)
+	} else {
+		// 没有心跳表时只能观察计数是否变化：计数长时间不变且与当前日志统计不一致才算停止更新，
+		// 没有请求时计数本来就不会变化
+		if c.lastCounters == nil || changed(c.lastCounters, counters) {
+			if c.lastCounters != nil {
+				changedAt := now
+				status.UpdatedAt = &changedAt
+			}
+			c.lastChange = now
+		} else if previous := c.Latest(); previous != nil {
+			status.UpdatedAt = previous.UpdatedAt
+		}
+		c.lastCounters = counters
+		status.Source = CounterSourceObserved
+		if now.Sub(c.lastChange) > c.config.StaleAfter {
+			status.Drift = counterDrift(counters, live, c.config.DriftTolerance)
+			status.Stale = len(status.Drift) > 0
+		}
+	}
+
+	c.mu.Lock()
+	c.status = status
+	c.mu.Unlock()
+
+	c.notify(ctx, status)
+	return status, nil
+}
+
+// changed 判断两次都存在的渠道中是否有计数发生了变化，新增或删除渠道不算
+func changed(previous, current map[int]UsageCounts) bool {
+	for id, counts := range current {
+		if last, ok := previous[id]; ok && last != counts {
+			return true
+		}
+	}
+	return false
+}
+
+// notify 根据检查结果发送或恢复计数停止更新、计数不一致两种告警
+func (c *CounterChecker) notify(ctx context.Context, status *CounterStatus) {
+	updated := "未知"
+	if status.UpdatedAt != nil {
+		updated = status.UpdatedAt.Format("2006-01-02 15:04:05")
+	}
+	c.alerter.Update(ctx, status.Stale, Alert{
+		Key:      "counters:stale",
+		Severity: SeverityCritical,
+		Title:    "渠道计数停止更新",
+		Message: fmt.Sprintf("channels 表中的计数超过 %s 没有更新（最近一次更新于 %s），UpdateChannelStats 可能已停止运行，渠道不会再被自动禁用或恢复",
+			formatWindowLength(c.config.StaleAfter), updated),
+	})
+
+	// 计数停止更新时不一致是必然的，只报告停止更新
+	drifted := !status.Stale && len(status.Drift) > 0
+	alert := Alert{Key: "counters:drift", Severity: SeverityWarning, Title: "渠道计数与日志不一致"}
+	if drifted {
+		first := status.Drift[0]
+		alert.Message = fmt.Sprintf("%d 个渠道的计数与 logs 表的统计相差超过 %d，例如渠道 #%d 天计数 %d，日志统计 %d；请检查存储过程的限制和窗口是否与监控配置一致",
+			len(status.Drift), c.config.DriftTolerance, first.ChannelID, first.CounterDay, first.LogsDay)
+		for _, drift := range status.Drift {
+			alert.Channels = append(alert.Channels, drift.ChannelID)
+		}
+	}
+	c.alerter.Update(ctx, drifted, alert)
+}
+
+// Latest 返回最近一次的检查结果，尚未检查或不检查时返回 nil
+func (c *CounterChecker) Latest() *CounterStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
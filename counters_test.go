@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var counterTestConfig = CounterConfig{StaleAfter: 5 * time.Minute, DriftTolerance: 5}
+
+func TestCounterCheckerHeartbeat(t *testing.T) {
+	heartbeat := testNow
+	var logs []LogEntry
+	for i := 0; i < 3; i++ {
+		logs = append(logs, LogEntry{ChannelID: 1, CreatedAt: heartbeat.Unix() - 10})
+	}
+	tests := []struct {
+		name    string
+		channel Channel
+		now     time.Time
+		stale   bool
+		drift   int
+		alert   string
+	}{
+		{"正常", Channel{ID: 1, CountMinuteUsage: 3, CountDayUsage: 3}, heartbeat.Add(2 * time.Minute), false, 0, ""},
+		// 心跳之后的请求不影响比较：计数和日志都以心跳时刻为准
+		{"心跳之后有新请求", Channel{ID: 1, CountMinuteUsage: 3, CountDayUsage: 3}, heartbeat.Add(4 * time.Minute), false, 0, ""},
+		{"停止更新", Channel{ID: 1, CountMinuteUsage: 3, CountDayUsage: 3}, heartbeat.Add(10 * time.Minute), true, 0, "counters:stale"},
+		{"不一致", Channel{ID: 1, CountMinuteUsage: 3, CountDayUsage: 30}, heartbeat.Add(time.Minute), false, 1, "counters:drift"},
+		{"在容差内", Channel{ID: 1, CountMinuteUsage: 3, CountDayUsage: 8}, heartbeat.Add(time.Minute), false, 0, ""},
+	}
+	for _, tt := range tests {
+		store := NewMemoryStore([]Channel{tt.channel}, append(logs, LogEntry{ChannelID: 1, CreatedAt: heartbeat.Unix() + 30}))
+		store.SetCountersUpdatedAt(heartbeat)
+		notifier := &recordingNotifier{}
+		checker := NewCounterChecker(store, testQuota, counterTestConfig, NewAlerter(notifier))
+
+		status, err := checker.Check(context.Background(), []Channel{tt.channel}, nil, tt.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Source != CounterSourceHeartbeat || status.UpdatedAt == nil || !status.UpdatedAt.Equal(heartbeat) {
+			t.Errorf("%s: status = %+v, want heartbeat updated at %s", tt.name, status, heartbeat)
+		}
+		if status.Stale != tt.stale || len(status.Drift) != tt.drift {
+			t.Errorf("%s: stale = %v, drift = %+v, want stale = %v, %d drift", tt.name, status.Stale, status.Drift, tt.stale, tt.drift)
+		}
+		var keys []string
+		for _, alert := range notifier.alerts {
+			keys = append(keys, alert.Key)
+		}
+		if (tt.alert == "" && len(keys) != 0) || (tt.alert != "" && (len(keys) != 1 || keys[0] != tt.alert)) {
+			t.Errorf("%s: alerts = %v, want %q", tt.name, keys, tt.alert)
+		}
+	}
+}
+
+func TestCounterCheckerObserved(t *testing.T) {
+	channels := []Channel{{ID: 1, CountMinuteUsage: 0, CountDayUsage: 10}}
+	store := NewMemoryStore(channels, nil)
+	notifier := &recordingNotifier{}
+	checker := NewCounterChecker(store, testQuota, counterTestConfig, NewAlerter(notifier))
+	ctx := context.Background()
+	check := func(channels []Channel, live map[int]UsageCounts, now time.Time) *CounterStatus {
+		t.Helper()
+		status, err := checker.Check(ctx, channels, live, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Source != CounterSourceObserved {
+			t.Errorf("source = %q, want %q", status.Source, CounterSourceObserved)
+		}
+		return status
+	}
+
+	// 第一次检查还不知道计数何时更新
+	if status := check(channels, map[int]UsageCounts{1: {Day: 10}}, testNow); status.UpdatedAt != nil || !status.Healthy() {
+		t.Errorf("first check = %+v, want healthy without updated_at", status)
+	}
+	// 没有新请求时计数不变是正常的
+	if status := check(channels, map[int]UsageCounts{1: {Day: 10}}, testNow.Add(10*time.Minute)); !status.Healthy() {
+		t.Errorf("idle check = %+v, want healthy", status)
+	}
+	// 计数变化后记录变化时间
+	changedAt := testNow.Add(11 * time.Minute)
+	channels = []Channel{{ID: 1, CountMinuteUsage: 2, CountDayUsage: 12}}
+	if status := check(channels, map[int]UsageCounts{1: {Minute: 2, Day: 12}}, changedAt); status.UpdatedAt == nil || !status.UpdatedAt.Equal(changedAt) {
+		t.Errorf("changed check = %+v, want updated at %s", status, changedAt)
+	}
+	// 之后日志中的请求持续增加而计数不变，超过 StaleAfter 视为停止更新
+	status := check(channels, map[int]UsageCounts{1: {Minute: 4, Day: 40}}, changedAt.Add(6*time.Minute))
+	if !status.Stale || status.UpdatedAt == nil || !status.UpdatedAt.Equal(changedAt) || status.AgeSeconds() != 360 {
+		t.Errorf("stale check = %+v, want stale since %s", status, changedAt)
+	}
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Key != "counters:stale" || notifier.alerts[0].Severity != SeverityCritical {
+		t.Errorf("alerts = %+v, want critical counters:stale", notifier.alerts)
+	}
+}
+
+func TestCounterCheckerDisabled(t *testing.T) {
+	checker := NewCounterChecker(NewMemoryStore(nil, nil), testQuota, CounterConfig{}, NewAlerter(&recordingNotifier{}))
+	status, err := checker.Check(context.Background(), nil, nil, testNow)
+	if err != nil || status != nil || checker.Latest() != nil {
+		t.Errorf("Check = %+v, %v, want nil when disabled", status, err)
+	}
+}
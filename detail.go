@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ModelLatencyComparison 渠道上某个模型的延迟与整体的对比
+type ModelLatencyComparison struct {
+	Model   string       `json:"model"`
+	Channel LatencyStats `json:"channel"`
+	Pool    LatencyStats `json:"pool"`
+}
+
+// ChannelLatencyWindow 渠道在一个窗口内的延迟与整体的对比
+type ChannelLatencyWindow struct {
+	Label   Message                  `json:"label"`
+	Channel LatencyStats             `json:"channel"`
+	Pool    LatencyStats             `json:"pool"`
+	Slow    bool                     `json:"slow"`
+	Models  []ModelLatencyComparison `json:"models"`
+}
+
+// ChannelDetail 渠道详情页的数据
+type ChannelDetail struct {
+	Channel           ChannelView            `json:"channel"`
+	MinuteWindowLabel Message                `json:"minute_window_label"`
+	DayWindowLabel    Message                `json:"day_window_label"`
+	Currency          string                 `json:"currency,omitempty"` // 未启用花费估算时为空
+	Latency           []ChannelLatencyWindow `json:"latency"`
+	CollectedAt       time.Time              `json:"collected_at"`
+}
+
+// buildChannelDetail 从面板数据和延迟统计中取出单个渠道的详情，渠道不存在时返回 false
+func buildChannelDetail(snapshot *Snapshot, latency *LatencyReport, id int) (*ChannelDetail, bool) {
+	var detail *ChannelDetail
+	for _, view := range snapshot.Channels {
+		if view.ID == id {
+			detail = &ChannelDetail{
+				Channel:           view,
+				MinuteWindowLabel: snapshot.MinuteWindowLabel,
+				DayWindowLabel:    snapshot.DayWindowLabel,
+				CollectedAt:       snapshot.CollectedAt,
+			}
+			break
+		}
+	}
+	if detail == nil {
+		return nil, false
+	}
+	if snapshot.Cost != nil {
+		detail.Currency = snapshot.Cost.Currency
+	}
+	if latency == nil {
+		return detail, true
+	}
+
+	for _, window := range latency.Windows {
+		channel, _ := window.Channel(id)
+		comparison := ChannelLatencyWindow{
+			Label:   window.Label,
+			Channel: channel.Overall,
+			Pool:    window.Pool,
+			Slow:    channel.Slow,
+		}
+		pool := make(map[string]LatencyStats, len(window.Models))
+		for _, model := range window.Models {
+			pool[model.Model] = model.LatencyStats
+		}
+		for _, model := range channel.Models {
+			comparison.Models = append(comparison.Models, ModelLatencyComparison{
+				Model:   model.Model,
+				Channel: model.LatencyStats,
+				Pool:    pool[model.Model],
+			})
+		}
+		detail.Latency = append(detail.Latency, comparison)
+	}
+	return detail, true
+}
+
+// channelIDFromPath 从 /channels/12 这样的路径中解析渠道 ID
+func channelIDFromPath(path, prefix string) (int, bool) {
+	id, err := strconv.Atoi(strings.TrimPrefix(path, prefix))
+	if err != nil || !strings.HasPrefix(path, prefix) {
+		return 0, false
+	}
+	return id, true
+}
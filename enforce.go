@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Cooldown 表示 channel_cooldowns 表中的一条冷却记录
+type Cooldown struct {
+	Reason string // "minute"、"day" 或 "budget"
+	Until  int64  // 解禁时间（Unix 时间戳）
+}
+
+// ChannelUpdate 表示一次执行配额时对单个渠道的写入
+type ChannelUpdate struct {
+	ChannelID        int
+	Status           string // "1" 可用，"2" 自动禁用
+	CountMinuteUsage int
+	CountDayUsage    int
+	Weight           int
+	Cooldown         *Cooldown // 为 nil 时删除该渠道已到期的冷却记录；已有更长的冷却时保留
+}
+
+// EnforceResult 汇总一次执行配额的结果
+type EnforceResult struct {
+	Channels int // 参与计算的渠道数
+	Disabled int // 本次由可用变为禁用的渠道数
+	Enabled  int // 本次由禁用恢复可用的渠道数
+}
+
+// dayCooldownUntil 返回天超限的渠道应冷却到的时间：
+// 固定窗口冷却到下一次重置，滚动窗口保守地冷却一个完整的窗口长度
+func dayCooldownUntil(window Window, now time.Time) int64 {
+	if window.Kind == WindowFixed {
+		return window.Start(now).Add(window.Length).Unix()
+	}
+	return now.Add(window.Length).Unix()
+}
+
+// extendCooldown 与存储过程一致：已有的冷却只延长不缩短
+func extendCooldown(current *Cooldown, reason string, until int64) *Cooldown {
+	if current != nil && current.Until > until {
+		return current
+	}
+	return &Cooldown{Reason: reason, Until: until}
+}
+
+// planEnforcement 根据窗口内的使用量计算每个渠道应有的状态、计数、权重和冷却记录，
+// 逻辑与 UpdateChannelStats 存储过程相同，但使用配置的窗口统计
+func planEnforcement(channels []Channel, usage map[int]UsageCounts, quota QuotaConfig, now time.Time) []ChannelUpdate {
+	updates := make([]ChannelUpdate, 0, len(channels))
+	for _, channel := range channels {
+		counts := usage[channel.ID]
+		limits, _ := quota.LimitsFor(channel)
+
+		// 未到期的冷却记录继续生效
+		var cooldown *Cooldown
+		if channel.DisabledUntil.Valid && channel.DisabledUntil.Int64 > now.Unix() {
+			cooldown = &Cooldown{Reason: channel.CooldownReason.String, Until: channel.DisabledUntil.Int64}
+		}
+		// 天超限优先，因为它的冷却时间更长
+		if counts.Day >= limits.Day {
+			cooldown = extendCooldown(cooldown, "day", dayCooldownUntil(quota.DayWindow, now))
+		} else if counts.Minute >= limits.Minute {
+			cooldown = extendCooldown(cooldown, "minute", now.Add(quota.MinuteCooldown).Unix())
+		}
+
+		update := ChannelUpdate{
+			ChannelID:        channel.ID,
+			Status:           "1",
+			CountMinuteUsage: counts.Minute,
+			CountDayUsage:    counts.Day,
+			// 当天剩余次数越多权重越高
+			Weight:   max(1, limits.Day-counts.Day),
+			Cooldown: cooldown,
+		}
+		if cooldown != nil {
+			update.Status = "2"
+		}
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+// Enforcer 在 Go 中执行配额检查，替代定时调用 UpdateChannelStats 存储过程
+type Enforcer struct {
+	store    Store
+	quota    QuotaConfig
+	location *time.Location
+	interval time.Duration
+}
+
+// NewEnforcer 创建配额执行器，interval 为循环执行时两次之间的间隔
+func NewEnforcer(store Store, quota QuotaConfig, location *time.Location, interval time.Duration) *Enforcer {
+	return &Enforcer{store: store, quota: quota, location: location, interval: interval}
+}
+
+// RunOnce 执行一次配额检查并写回数据库
+func (e *Enforcer) RunOnce(ctx context.Context) (EnforceResult, error) {
+	ctx, span := tracer.Start(ctx, "enforce")
+	result, err := e.enforce(ctx)
+	span.SetAttributes(attribute.Int("channels", result.Channels), attribute.Int("disabled", result.Disabled), attribute.Int("enabled", result.Enabled))
+	endSpan(span, err)
+	return result, err
+}
+
+// enforce 统计使用量、计算每个渠道的状态并写回
+func (e *Enforcer) enforce(ctx context.Context) (EnforceResult, error) {
+	now := time.Now().In(e.location)
+	usage, err := e.store.Usage(ctx, e.quota.MinuteWindow.Start(now), e.quota.DayWindow.Start(now), now)
+	if err != nil {
+		return EnforceResult{}, fmt.Errorf("统计日志失败: %w", err)
+	}
+	channels, err := e.store.Channels(ctx)
+	if err != nil {
+		return EnforceResult{}, fmt.Errorf("查询渠道失败: %w", err)
+	}
+
+	updates := planEnforcement(channels, usage, e.quota, now)
+	if err := e.store.UpdateChannels(ctx, updates); err != nil {
+		return EnforceResult{}, fmt.Errorf("更新渠道失败: %w", err)
+	}
+
+	result := EnforceResult{Channels: len(channels)}
+	for i, update := range updates {
+		switch previous := channels[i].Status; {
+		case previous == "1" && update.Status != "1":
+			result.Disabled++
+		case previous != "1" && update.Status == "1":
+			result.Enabled++
+		}
+	}
+	return result, nil
+}
+
+// Run 按间隔循环执行配额检查，直到 ctx 被取消
+func (e *Enforcer) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		if result, err := e.RunOnce(ctx); err != nil {
+			slog.ErrorContext(ctx, "执行配额检查失败", "err", err)
+		} else if result.Disabled > 0 || result.Enabled > 0 {
+			slog.InfoContext(ctx, "执行配额检查", "disabled", result.Disabled, "enabled", result.Enabled)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPlanEnforcement(t *testing.T) {
+	nextReset := time.Date(2026, 10, 19, 8, 0, 0, 0, testNow.Location()).Unix()
+	minuteCooldownEnd := testNow.Add(testQuota.MinuteCooldown).Unix()
+	longReason, longUntil := cooldown("day", testNow.Add(time.Hour))
+	shortReason, shortUntil := cooldown("minute", testNow.Add(time.Minute))
+	expiredReason, expiredUntil := cooldown("minute", testNow.Add(-time.Second))
+
+	tests := []struct {
+		name    string
+		channel Channel
+		usage   UsageCounts
+		want    ChannelUpdate
+	}{
+		{
+			name:    "under limits stays available",
+			channel: Channel{ID: 1, Status: "1"},
+			usage:   UsageCounts{Minute: 4, Day: 10},
+			want:    ChannelUpdate{ChannelID: 1, Status: "1", CountMinuteUsage: 4, CountDayUsage: 10, Weight: 15},
+		},
+		{
+			name:    "minute limit starts cooldown",
+			channel: Channel{ID: 2, Status: "1"},
+			usage:   UsageCounts{Minute: 5, Day: 10},
+			want: ChannelUpdate{ChannelID: 2, Status: "2", CountMinuteUsage: 5, CountDayUsage: 10, Weight: 15,
+				Cooldown: &Cooldown{Reason: "minute", Until: minuteCooldownEnd}},
+		},
+		{
+			name:    "day limit cools down until next reset",
+			channel: Channel{ID: 3, Status: "1"},
+			usage:   UsageCounts{Minute: 5, Day: 25},
+			want: ChannelUpdate{ChannelID: 3, Status: "2", CountMinuteUsage: 5, CountDayUsage: 25, Weight: 1,
+				Cooldown: &Cooldown{Reason: "day", Until: nextReset}},
+		},
+		{
+			name:    "paid channel uses paid limits",
+			channel: Channel{ID: 4, Status: "1", Tag: "gcp"},
+			usage:   UsageCounts{Minute: 19, Day: 99},
+			want:    ChannelUpdate{ChannelID: 4, Status: "1", CountMinuteUsage: 19, CountDayUsage: 99, Weight: 1},
+		},
+		{
+			name:    "active cooldown keeps channel disabled after usage drops",
+			channel: Channel{ID: 5, Status: "2", CooldownReason: shortReason, DisabledUntil: shortUntil},
+			usage:   UsageCounts{Minute: 0, Day: 10},
+			want: ChannelUpdate{ChannelID: 5, Status: "2", CountDayUsage: 10, Weight: 15,
+				Cooldown: &Cooldown{Reason: "minute", Until: shortUntil.Int64}},
+		},
+		{
+			name:    "repeated minute hit extends cooldown",
+			channel: Channel{ID: 6, Status: "2", CooldownReason: shortReason, DisabledUntil: shortUntil},
+			usage:   UsageCounts{Minute: 6, Day: 10},
+			want: ChannelUpdate{ChannelID: 6, Status: "2", CountMinuteUsage: 6, CountDayUsage: 10, Weight: 15,
+				Cooldown: &Cooldown{Reason: "minute", Until: minuteCooldownEnd}},
+		},
+		{
+			name:    "longer cooldown is never shortened",
+			channel: Channel{ID: 7, Status: "2", CooldownReason: longReason, DisabledUntil: longUntil},
+			usage:   UsageCounts{Minute: 6, Day: 10},
+			want: ChannelUpdate{ChannelID: 7, Status: "2", CountMinuteUsage: 6, CountDayUsage: 10, Weight: 15,
+				Cooldown: &Cooldown{Reason: "day", Until: longUntil.Int64}},
+		},
+		{
+			name:    "expired cooldown re-enables",
+			channel: Channel{ID: 8, Status: "2", CooldownReason: expiredReason, DisabledUntil: expiredUntil},
+			usage:   UsageCounts{Minute: 1, Day: 10},
+			want:    ChannelUpdate{ChannelID: 8, Status: "1", CountMinuteUsage: 1, CountDayUsage: 10, Weight: 15},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates := planEnforcement([]Channel{tt.channel}, map[int]UsageCounts{tt.channel.ID: tt.usage}, testQuota, testNow)
+			if len(updates) != 1 {
+				t.Fatalf("got %d updates, want 1", len(updates))
+			}
+			got := updates[0]
+			if (got.Cooldown == nil) != (tt.want.Cooldown == nil) ||
+				(got.Cooldown != nil && *got.Cooldown != *tt.want.Cooldown) {
+				t.Errorf("cooldown = %+v, want %+v", got.Cooldown, tt.want.Cooldown)
+			}
+			got.Cooldown, tt.want.Cooldown = nil, nil
+			if got != tt.want {
+				t.Errorf("update = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforcerRunOnce(t *testing.T) {
+	now := time.Now().Unix()
+	reason, until := cooldown("minute", time.Now().Add(-time.Minute))
+	store := NewMemoryStore(
+		[]Channel{
+			{ID: 1, Status: "1"},
+			{ID: 2, Status: "2", CooldownReason: reason, DisabledUntil: until},
+			{ID: 3, Status: "1"},
+		},
+		nil,
+	)
+	for i := 0; i < 5; i++ {
+		store.AddLogs(LogEntry{ChannelID: 1, CreatedAt: now - int64(i)})
+	}
+	store.AddLogs(LogEntry{ChannelID: 3, CreatedAt: now})
+
+	enforcer := NewEnforcer(store, testQuota, time.Local, time.Minute)
+	result, err := enforcer.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (EnforceResult{Channels: 3, Disabled: 1, Enabled: 1}); result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+
+	channels, _ := store.Channels(context.Background())
+	wantStatus := map[int]string{1: "2", 2: "1", 3: "1"}
+	for _, channel := range channels {
+		if channel.Status != wantStatus[channel.ID] {
+			t.Errorf("channel %d status = %s, want %s", channel.ID, channel.Status, wantStatus[channel.ID])
+		}
+	}
+	if !channels[0].DisabledUntil.Valid || channels[0].CooldownReason.String != "minute" {
+		t.Errorf("channel 1 should have a minute cooldown, got %+v", channels[0])
+	}
+	if channels[1].DisabledUntil.Valid {
+		t.Errorf("expired cooldown of channel 2 should be removed, got %+v", channels[1])
+	}
+}
+
+// TestUpdateChannelsKeepsLongerCooldown 读取渠道之后写入的更长冷却（如预算冷却）不会被执行配额的写入删除或缩短
+func TestUpdateChannelsKeepsLongerCooldown(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore([]Channel{{ID: 1, Status: "1"}, {ID: 2, Status: "1"}, {ID: 3, Status: "2"}}, nil)
+	budget := Cooldown{Reason: "budget", Until: now.Add(time.Hour).Unix()}
+	if err := store.DisableChannels(context.Background(), []int{1, 2}, budget); err != nil {
+		t.Fatal(err)
+	}
+	expired := Cooldown{Reason: "minute", Until: now.Add(-time.Second).Unix()}
+	if err := store.DisableChannels(context.Background(), []int{3}, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	updates := []ChannelUpdate{
+		{ChannelID: 1, Status: "1"},
+		{ChannelID: 2, Status: "2", Cooldown: &Cooldown{Reason: "minute", Until: now.Add(time.Minute).Unix()}},
+		{ChannelID: 3, Status: "1"},
+	}
+	if err := store.UpdateChannels(context.Background(), updates); err != nil {
+		t.Fatal(err)
+	}
+	channels, err := store.Channels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, channel := range channels {
+		reason, until := channel.CooldownReason.String, channel.DisabledUntil.Int64
+		switch channel.ID {
+		case 1, 2:
+			if reason != "budget" || until != budget.Until {
+				t.Errorf("channel %d cooldown = %s until %d, want budget until %d", channel.ID, reason, until, budget.Until)
+			}
+		case 3:
+			if channel.DisabledUntil.Valid {
+				t.Errorf("channel 3 expired cooldown should be deleted, got %s until %d", reason, until)
+			}
+		}
+	}
+}
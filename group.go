@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// channelTypeNames newapi 常见渠道类型的名称，其他类型显示为编号
+var channelTypeNames = map[int]string{
+	0:  "未知",
+	1:  "OpenAI",
+	3:  "Azure",
+	14: "Anthropic",
+	24: "Gemini",
+	33: "AWS Claude",
+	41: "Vertex AI",
+}
+
+// channelTypeName 返回渠道类型的名称
+func channelTypeName(t int) Message {
+	if name, ok := channelTypeNames[t]; ok {
+		return newMessage(name)
+	}
+	return newMessage("类型 %d", t)
+}
+
+// ChannelLabels 自定义标签：标签名 -> 渠道 ID -> 取值，例如 project -> 3 -> 搜索
+type ChannelLabels map[string]map[int]string
+
+// Keys 返回排序后的标签名
+func (l ChannelLabels) Keys() []string {
+	keys := make([]string, 0, len(l))
+	for key := range l {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// loadChannelLabels 读取自定义标签文件，path 为空时没有标签。
+// 文件格式为 {"project": {"搜索": [1, 2], "客服": [3]}, "owner": {"张三": [1, 3]}}
+func loadChannelLabels(path string) (ChannelLabels, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]map[string][]int
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+	}
+	labels := make(ChannelLabels)
+	for key, values := range raw {
+		if key == "" || strings.Contains(key, ",") {
+			return nil, fmt.Errorf("标签名 %q 无效", key)
+		}
+		labels[key] = make(map[int]string)
+		for value, ids := range values {
+			for _, id := range ids {
+				if previous, ok := labels[key][id]; ok {
+					return nil, fmt.Errorf("渠道 #%d 的标签 %s 同时为 %q 和 %q", id, key, previous, value)
+				}
+				labels[key][id] = value
+			}
+		}
+	}
+	return labels, nil
+}
+
+// applyLabels 把自定义标签填入面板数据
+func applyLabels(snapshot *Snapshot, labels ChannelLabels) {
+	snapshot.LabelKeys = labels.Keys()
+	for i := range snapshot.Channels {
+		view := &snapshot.Channels[i]
+		for key, values := range labels {
+			if value, ok := values[view.ID]; ok {
+				if view.Labels == nil {
+					view.Labels = make(map[string]string)
+				}
+				view.Labels[key] = value
+			}
+		}
+	}
+}
+
+// 渠道的分组方式，label:<标签名> 按自定义标签分组
+const (
+	GroupByNone   = ""
+	GroupByTier   = "tier"
+	GroupByGroup  = "group"
+	GroupByName   = "name"
+	GroupByType   = "type"
+	GroupByModels = "models"
+	labelPrefix   = "label:"
+)
+
+// ungroupedName 没有对应取值的渠道所在分组的名称
+const ungroupedName = "（未设置）"
+
+// ChannelGroup 一个分组内的渠道及其小计
+type ChannelGroup struct {
+	Name     string        `json:"name"`
+	Title    Message       `json:"-"` // 页面显示的分组名称，按语言翻译
+	Channels []ChannelView `json:"channels"`
+	Summary  SummaryData   `json:"summary"`
+}
+
+// validGroupBy 检查分组方式是否受支持，按标签分组时标签必须存在
+func validGroupBy(by string, labelKeys []string) error {
+	switch by {
+	case GroupByNone, GroupByTier, GroupByGroup, GroupByName, GroupByType, GroupByModels:
+		return nil
+	}
+	if key, ok := strings.CutPrefix(by, labelPrefix); ok {
+		for _, k := range labelKeys {
+			if k == key {
+				return nil
+			}
+		}
+		return fmt.Errorf("没有名为 %q 的标签", key)
+	}
+	return fmt.Errorf("不支持的分组方式 %q，可选 tier、group、name、type、models 或 label:<标签名>", by)
+}
+
+// groupNames 返回渠道所属的分组。分组和模型可以有多个，此时渠道出现在每个分组中
+func groupNames(view ChannelView, by string) []Message {
+	var names []string
+	switch by {
+	case GroupByTier:
+		names = []string{view.TagDisplay}
+	case GroupByGroup:
+		names = view.Groups
+	case GroupByName:
+		if view.Name != "" {
+			names = []string{view.Name}
+		}
+	case GroupByType:
+		return []Message{view.TypeDisplay}
+	case GroupByModels:
+		names = view.Models
+	default:
+		if value, ok := view.Labels[strings.TrimPrefix(by, labelPrefix)]; ok {
+			names = []string{value}
+		}
+	}
+	if len(names) == 0 {
+		return []Message{newMessage(ungroupedName)}
+	}
+	titles := make([]Message, len(names))
+	for i, name := range names {
+		titles[i] = newMessage(name)
+	}
+	return titles
+}
+
+// groupChannels 按 by 把渠道分组，组内保持原有顺序，分组按名称排序，未设置的分组排在最后
+func groupChannels(channels []ChannelView, by string) []ChannelGroup {
+	index := make(map[string]int)
+	var groups []ChannelGroup
+	for _, view := range channels {
+		for _, title := range groupNames(view, by) {
+			name := title.String()
+			i, ok := index[name]
+			if !ok {
+				i = len(groups)
+				index[name] = i
+				groups = append(groups, ChannelGroup{Name: name, Title: title})
+			}
+			groups[i].Channels = append(groups[i].Channels, view)
+		}
+	}
+	for i := range groups {
+		groups[i].Summary = summarize(groups[i].Channels)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if (groups[i].Name == ungroupedName) != (groups[j].Name == ungroupedName) {
+			return groups[j].Name == ungroupedName
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// GroupedBy 返回按 by 分组后的面板数据副本，不修改原数据；by 为空时不分组
+func (s *Snapshot) GroupedBy(by string) (*Snapshot, error) {
+	if err := validGroupBy(by, s.LabelKeys); err != nil {
+		return nil, err
+	}
+	grouped := *s
+	grouped.GroupBy = by
+	grouped.Groups = nil
+	if by != GroupByNone {
+		grouped.Groups = groupChannels(s.Channels, by)
+	}
+	return &grouped, nil
+}
+
+// Sections 返回页面上依次显示的渠道分组，不分组时只有一个没有名称的分组
+func (s *Snapshot) Sections() []ChannelGroup {
+	if s.GroupBy == GroupByNone {
+		return []ChannelGroup{{Channels: s.Channels, Summary: s.Summary}}
+	}
+	return s.Groups
+}
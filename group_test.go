@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// groupedSnapshot 覆盖多个分组、多个模型、标签以及未设置取值的渠道
+func groupedSnapshot(t *testing.T) *Snapshot {
+	t.Helper()
+	channels := []Channel{
+		{ID: 1, Status: "1", Tag: "gcp", Name: "acct-a", Group: "vip", Type: 41, Models: "gemini-2.5-pro"},
+		{ID: 2, Status: "1", Name: "acct-b", Group: "default,vip", Type: 24, Models: "gemini-2.5-pro,gemini-2.5-flash"},
+		{ID: 3, Status: "2", Group: "default", Type: 24},
+	}
+	usage := map[int]UsageCounts{1: {Minute: 2, Day: 10}, 2: {Minute: 1, Day: 5}, 3: {Day: 25}}
+	snapshot := buildSnapshot(channels, usage, testQuota, testNow)
+	applyLabels(snapshot, ChannelLabels{"project": {1: "搜索", 3: "搜索"}})
+	return snapshot
+}
+
+func TestGroupedBy(t *testing.T) {
+	snapshot := groupedSnapshot(t)
+	tests := []struct {
+		by   string
+		want map[string][]int // 分组名 -> 渠道 ID，组内保持面板的排序
+		keys []string         // 分组的顺序
+	}{
+		{GroupByTier, map[string][]int{"付费号": {1}, "普号": {2, 3}}, []string{"付费号", "普号"}},
+		{GroupByGroup, map[string][]int{"default": {2, 3}, "vip": {1, 2}}, []string{"default", "vip"}},
+		{GroupByName, map[string][]int{"acct-a": {1}, "acct-b": {2}, ungroupedName: {3}}, []string{"acct-a", "acct-b", ungroupedName}},
+		{GroupByType, map[string][]int{"Gemini": {2, 3}, "Vertex AI": {1}}, []string{"Gemini", "Vertex AI"}},
+		{GroupByModels, map[string][]int{"gemini-2.5-flash": {2}, "gemini-2.5-pro": {1, 2}, ungroupedName: {3}}, []string{"gemini-2.5-flash", "gemini-2.5-pro", ungroupedName}},
+		{"label:project", map[string][]int{"搜索": {1, 3}, ungroupedName: {2}}, []string{"搜索", ungroupedName}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.by, func(t *testing.T) {
+			grouped, err := snapshot.GroupedBy(tt.by)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			got := make(map[string][]int)
+			for _, group := range grouped.Groups {
+				keys = append(keys, group.Name)
+				for _, view := range group.Channels {
+					got[group.Name] = append(got[group.Name], view.ID)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("groups = %v (%v), want %v (%v)", got, keys, tt.want, tt.keys)
+			}
+		})
+	}
+	if snapshot.Groups != nil {
+		t.Error("GroupedBy 不应修改原数据")
+	}
+
+	// 分组小计只包含组内的渠道
+	grouped, _ := snapshot.GroupedBy(GroupByGroup)
+	if summary := grouped.Groups[0].Summary; summary.TotalDayUsage != 30 || summary.DisabledNormalChannels != 1 || summary.TotalNormalChannels != 2 {
+		t.Errorf("default 分组的小计 = %+v", summary)
+	}
+
+	for _, by := range []string{"owner", "label:owner"} {
+		if _, err := snapshot.GroupedBy(by); err == nil {
+			t.Errorf("GroupedBy(%q) 应返回错误", by)
+		}
+	}
+}
+
+func TestLoadChannelLabels(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		want    ChannelLabels
+		wantErr bool
+	}{
+		{"有效", `{"project": {"搜索": [1, 2], "客服": [3]}, "owner": {"张三": [1]}}`,
+			ChannelLabels{"project": {1: "搜索", 2: "搜索", 3: "客服"}, "owner": {1: "张三"}}, false},
+		{"同一渠道两个取值", `{"project": {"搜索": [1], "客服": [1]}}`, nil, true},
+		{"格式错误", `{"project": [1, 2]}`, nil, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, "labels.json")
+		if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := loadChannelLabels(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v", tt.name, err)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: labels = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderIndexGrouped(t *testing.T) {
+	renderer, err := NewRenderer(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grouped := queried(t, groupedSnapshot(t), "group_by=label:project")
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, "index.html", grouped); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, want := range []string{
+		`<option value="label:project" selected>按标签 project</option>`,
+		`<details class="channel-group" data-group="搜索" open>`,
+		`<details class="channel-group" data-group="（未设置）" open>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("页面中缺少 %q", want)
+		}
+	}
+	if strings.Contains(html, `id="channelsGrid"`) {
+		t.Error("分组时不应有重复的 channelsGrid")
+	}
+}
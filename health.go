@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// 构建信息，通过 -ldflags "-X main.gitCommit=... -X main.buildTime=..." 在构建时注入
+var (
+	gitCommit = ""
+	buildTime = ""
+)
+
+// buildInfo 返回构建信息，未通过 ldflags 注入时尝试读取 Go 自带的 VCS 信息
+func buildInfo() (commit, built string) {
+	commit, built = gitCommit, buildTime
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && commit == "":
+				commit = setting.Value
+			case setting.Key == "vcs.time" && built == "":
+				built = setting.Value
+			}
+		}
+	}
+	if commit == "" {
+		commit = "unknown"
+	}
+	if built == "" {
+		built = "unknown"
+	}
+	return commit, built
+}
+
+// healthHandler 提供存活、就绪和版本信息接口，供 Docker/Kubernetes 探针使用
+type healthHandler struct {
+	store     Store
+	collector *Collector
+	counters  *CounterChecker   // 为 nil 时不报告计数状态
+	maxAge    time.Duration     // 最近一次采集结果的最大允许时长
+	config    map[string]string // 不含密码等敏感信息的配置摘要
+}
+
+// healthz 只表示进程存活，不访问数据库
+func (h *healthHandler) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// readyz 检查数据库是否可达以及最近一次采集是否足够新
+func (h *healthHandler) readyz(w http.ResponseWriter, r *http.Request) {
+	result := map[string]interface{}{}
+	ready := true
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := h.store.Ping(ctx); err != nil {
+		ready = false
+		result["db"] = err.Error()
+	} else {
+		result["db"] = "ok"
+	}
+
+	lastAttempt, lastErr := h.collector.Status()
+	if lastErr != nil {
+		result["last_error"] = lastErr.Error()
+	}
+	if !lastAttempt.IsZero() {
+		result["last_attempt"] = lastAttempt.Format(time.RFC3339)
+	}
+	if snapshot := h.collector.Latest(); snapshot == nil {
+		ready = false
+		result["collection"] = "尚未完成首次采集"
+	} else {
+		age := time.Since(snapshot.CollectedAt)
+		result["last_collection"] = snapshot.CollectedAt.Format(time.RFC3339)
+		result["age_seconds"] = int(age.Seconds())
+		if age > h.maxAge {
+			ready = false
+			result["collection"] = "采集结果已过期"
+		} else {
+			result["collection"] = "ok"
+		}
+	}
+
+	// 计数停止更新是存储过程的问题，不影响监控本身是否就绪，只在结果中报告
+	if h.counters != nil {
+		if counters := h.counters.Latest(); counters != nil {
+			result["counters"] = counters
+		}
+	}
+
+	status := http.StatusOK
+	result["status"] = "ok"
+	if !ready {
+		status = http.StatusServiceUnavailable
+		result["status"] = "unavailable"
+	}
+	writeJSON(w, status, result)
+}
+
+// countersz 报告 channels 表中的计数是否在正常更新：停止更新或与日志不一致时返回 503，
+// 便于外部监控直接探测存储过程是否仍在运行
+func (h *healthHandler) countersz(w http.ResponseWriter, r *http.Request) {
+	var status *CounterStatus
+	if h.counters != nil {
+		status = h.counters.Latest()
+	}
+	switch {
+	case status == nil:
+		writeJSON(w, http.StatusOK, map[string]string{"status": "unknown"})
+	case status.Healthy():
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "counters": status})
+	default:
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "unhealthy", "counters": status})
+	}
+}
+
+// version 返回构建信息和配置摘要
+func (h *healthHandler) version(w http.ResponseWriter, r *http.Request) {
+	commit, built := buildInfo()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"git_commit": commit,
+		"build_time": built,
+		"go_version": runtime.Version(),
+		"config":     h.config,
+	})
+}
+
+// writeJSON 以 JSON 格式写出响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
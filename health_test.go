@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// unreachableStore 模拟 Ping 失败的数据库
+type unreachableStore struct{ MemoryStore }
+
+func (s *unreachableStore) Ping(ctx context.Context) error {
+	return errors.New("dial tcp: connection refused")
+}
+
+func TestReadyz(t *testing.T) {
+	fresh := NewCollector(NewMemoryStore([]Channel{{ID: 1, Status: "1"}}, nil), testQuota, time.UTC, time.Minute)
+	if _, err := fresh.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	stale := NewCollector(NewMemoryStore(nil, nil), testQuota, time.UTC, time.Minute)
+	stale.snapshot = &Snapshot{CollectedAt: time.Now().Add(-time.Hour)}
+
+	tests := []struct {
+		name      string
+		store     Store
+		collector *Collector
+		want      int
+	}{
+		{"ready", NewMemoryStore(nil, nil), fresh, http.StatusOK},
+		{"database unreachable", &unreachableStore{}, fresh, http.StatusServiceUnavailable},
+		{"no collection yet", NewMemoryStore(nil, nil), NewCollector(NewMemoryStore(nil, nil), testQuota, time.UTC, time.Minute), http.StatusServiceUnavailable},
+		{"stale collection", NewMemoryStore(nil, nil), stale, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &healthHandler{store: tt.store, collector: tt.collector, maxAge: 3 * time.Minute}
+			rec := httptest.NewRecorder()
+			h.readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d, body: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestCountersz(t *testing.T) {
+	channels := []Channel{{ID: 1, Status: "1", CountDayUsage: 3}}
+	tests := []struct {
+		name      string
+		heartbeat time.Duration // 心跳距现在的时长，为 0 时不检查计数
+		want      int
+	}{
+		{"not checked", 0, http.StatusOK},
+		{"updating", time.Minute, http.StatusOK},
+		{"stale", time.Hour, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &healthHandler{}
+			if tt.heartbeat > 0 {
+				store := NewMemoryStore(channels, nil)
+				store.SetCountersUpdatedAt(time.Now().Add(-tt.heartbeat))
+				h.counters = NewCounterChecker(store, testQuota, CounterConfig{StaleAfter: 5 * time.Minute, DriftTolerance: 5}, NewAlerter(&recordingNotifier{}))
+				if _, err := h.counters.Check(context.Background(), channels, nil, time.Now()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rec := httptest.NewRecorder()
+			h.countersz(rec, httptest.NewRequest(http.MethodGet, "/healthz/counters", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d, body: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestVersionOmitsSecrets(t *testing.T) {
+	h := &healthHandler{config: map[string]string{"db_host": "db"}}
+	rec := httptest.NewRecorder()
+	h.version(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	for _, field := range []string{`"git_commit"`, `"build_time"`, `"db_host": "db"`} {
+		if !bytes.Contains(rec.Body.Bytes(), []byte(field)) {
+			t.Errorf("response missing %s: %s", field, rec.Body)
+		}
+	}
+}
+
+func TestLocalClient(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	})
+	tcp, err := listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(tcp.Addr().String())
+	socket := "unix:" + filepath.Join(t.TempDir(), "monitor.sock")
+	unix, err := listen(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, listener := range []net.Listener{tcp, unix} {
+		server := &http.Server{Handler: handler}
+		go server.Serve(listener)
+		defer server.Close()
+	}
+
+	// 监听所有网卡时通过 127.0.0.1 访问
+	for _, addr := range []string{":" + port, "0.0.0.0:" + port, "127.0.0.1:" + port, socket} {
+		client, base, err := localClient(addr, time.Second)
+		if err != nil {
+			t.Fatalf("%s: %v", addr, err)
+		}
+		resp, err := client.Get(base + "/readyz")
+		if err != nil {
+			t.Fatalf("%s: %v", addr, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "/readyz" {
+			t.Errorf("%s: body = %q", addr, body)
+		}
+	}
+	if _, _, err := localClient("8080", time.Second); err == nil {
+		t.Error("expected error for address without port")
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// 页面支持的语言，中文为源语言
+const (
+	LangZH = "zh"
+	LangEN = "en"
+)
+
+// langCookie 保存用户选择的语言
+const langCookie = "lang"
+
+// supportedLang 判断是否为支持的语言
+func supportedLang(lang string) bool {
+	return lang == LangZH || lang == LangEN
+}
+
+// catalog 各语言的翻译，键为模板和代码中的中文原文。
+// 原文中的 %s、%d 由模板传入的参数或代码生成的 Message 的参数填入，见 translate
+var catalog = map[string]map[string]string{
+	LangEN: {
+		// 渠道状态和类型
+		"可用":    "Available",
+		"自动禁用":  "Disabled",
+		"冷却中":   "Cooling down",
+		"付费号":   "Paid",
+		"普号":    "Normal",
+		"分钟超限":  "Minute limit exceeded",
+		"天超限":   "Daily limit exceeded",
+		"超出预算":  "Over budget",
+		"未知":    "Unknown",
+		"类型 %d": "Type %d",
+		"（未设置）": "(not set)",
+
+		// 窗口和时长
+		"过去%s":              "Last %s",
+		"今日（每天 %s 重置）":      "Today (resets daily at %s)",
+		"本小时（自 %s 起）":       "This hour (since %s)",
+		"本分钟（自 %s 起）":       "This minute (since %s)",
+		"本周期（自 %s 起，每%s重置）": "This period (since %s, resets every %s)",
+		"%d小时":              "%d h",
+		"%d分钟":              "%d min",
+		"%d秒":               "%d s",
+		"%d小时%d分":           "%dh %dm",
+		"%d分%d秒":            "%dm %ds",
+		"（%s）":              " (%s)",
+		"：":                 ": ",
+		"、":                 ", ",
+		"，剩余":               ", remaining",
+		"%s 至 %s":           "%s to %s",
+		"%s 前":              "before %s",
+		"%s 后":              "after %s ",
+		"1 小时":              "1 hour",
+		"4 小时":              "4 hours",
+		"24 小时":             "24 hours",
+		"1 天":               "1 day",
+		"7 天":               "7 days",
+		"Gemini 2.5 Pro监控":  "Gemini 2.5 Pro Monitor",
+		"渠道面板":              "Channels",
+		"消耗排行":              "Leaderboard",
+		"容量规划":              "Capacity",
+		"日报":                "Reports",
+		"告警":                "Alerts",
+		"渠道 %d":             "Channel %d",
+		"采集于 %s":            "Collected at %s",
+		"统计于 %s，结果缓存 5 分钟。":              "Computed at %s, cached for 5 minutes.",
+		"生成于 %s。自动禁用次数和超限时间根据日志按%s分桶推算。": "Generated at %s. Disable counts and limit times are estimated from logs in %s buckets.",
+
+		// 渠道面板
+		"总使用情况":    "Overall usage",
+		"%s总使用次数：": "%s total usage: ",
+		"自动禁用普号数：": "Disabled normal channels: ",
+		"付费号今日花费：": "Paid spend today: ",
+		"付费号本月花费：": "Paid spend this month: ",
+		"全部渠道今日估算花费 %s，本月 %s（普号 %s）": "Estimated spend for all channels: %s today, %s this month (normal %s)",
+		"以下模型不在价格表中，未计入花费：":          "Models missing from the price table and not counted: ",
+		"搜索ID、名称、模型、分组...":           "Search ID, name, model, group...",
+		"全部":                         "All",
+		"全部类型":                       "All tiers",
+		"全部分组":                       "All groups",
+		"默认排序":                       "Default order",
+		"按 ID":                       "By ID",
+		"按名称":                        "By name",
+		"按%s使用率":                     "By %s usage",
+		"按错误率":                       "By error rate",
+		"按今日花费":                      "By spend today",
+		"按延迟":                        "By latency",
+		"按优先级":                       "By priority",
+		"按测试响应时间":                    "By test response time",
+		"升序":                         "Ascending",
+		"降序":                         "Descending",
+		"不分组":                        "No grouping",
+		"按付费/普号":                     "By tier",
+		"按分组":                        "By group",
+		"按类型":                        "By type",
+		"按模型":                        "By model",
+		"按标签 %s":                     "By label %s",
+		"卡片":                         "Cards",
+		"表格":                         "Table",
+		"大屏模式":                       "Wall display",
+		"隐藏控件并自动轮播分组，按 Esc 退出": "Hide controls and cycle through groups; press Esc to exit",
+		"切换主题":             "Toggle theme",
+		"共 %d 个渠道":         "%d channels",
+		"，第 %d/%d 页":       ", page %d/%d",
+		"第 %d/%d 页":        "Page %d/%d",
+		"上一页":              "Previous",
+		"下一页":              "Next",
+		"%d 个渠道":           "%d channels",
+		"自动禁用普号":           "Disabled normal",
+		"已选":               "Selected",
+		"个渠道":              "channels",
+		"复制 ID":            "Copy IDs",
+		"导出 CSV":           "Export CSV",
+		"屏蔽说明":             "Silence comment",
+		"屏蔽告警":             "Silence alerts",
+		"全选":               "Select all",
+		"名称":               "Name",
+		"付费/普号":            "Tier",
+		"状态":               "Status",
+		"类型":               "Type",
+		"分组":               "Group",
+		"模型":               "Model",
+		"错误率":              "Error rate",
+		"今日花费":             "Spend today",
+		"本月花费":             "Spend this month",
+		"延迟":               "Latency",
+		"优先级":              "Priority",
+		"权重":               "Weight",
+		"测试时间":             "Tested at",
+		"响应":               "Response",
+		"创建时间":             "Created at",
+		"未测试":              "Not tested",
+		"测试于 %s":           "Tested %s",
+		"创建于 %s":           "created %s",
+		"花费：今日 %s · 本月 %s": "Spend: %s today · %s this month",
+		"延迟（%s）：":          "Latency (%s): ",
+		"延迟（%s）":           "Latency (%s)",
+		"偏慢":               "Slow",
+		"请求速率：":            "Request rate: ",
+		"%s 次/分（5分钟）· %s 次/分（15分钟）": "%s/min (5m) · %s/min (15m)",
+		"最近一小时每分钟请求数":               "Requests per minute over the last hour",
+		"上升":                        "Rising",
+		"下降":                        "Falling",
+		"平稳":                        "Steady",
+		"突增":                        "spike",
+		"骤降":                        "drop",
+		"整体请求量%s":                   "Overall traffic %s",
+		"请求量%s":                     "Traffic %s",
+		"最近5分钟":                     "Last 5 min",
+		"最近1小时":                     "Last hour",
+		"%s请求 %d 次，基线 %.0f 次":       "%s: %d requests, baseline %.0f",
+		"请求数":                       "Requests",
+		"渠道计数已停止更新":                 "Channel counters stopped updating",
+		"最近一次更新于 %s 前":              "last updated %s ago",
+		"最近一次更新时间未知":                "last update time unknown",
+		"，渠道不会再被自动禁用或恢复":    "; channels will no longer be disabled or restored automatically",
+		"渠道计数与日志不一致":        "Channel counters disagree with logs",
+		"%d 个渠道的计数与日志统计不一致": "%d channels have counters that differ from the logs",
+		"本渠道":  "This channel",
+		"全部渠道": "All channels",
+
+		// 告警
+		"触发中的告警":       "Firing alerts",
+		"级别":           "Severity",
+		"开始于":          "Started",
+		"已屏蔽":          "Silenced",
+		"已通知":          "Notified",
+		"从告警页面屏蔽":      "Silenced from the alerts page",
+		"屏蔽":           "Silence",
+		"当前没有触发中的告警":   "No alerts are firing",
+		"规则":           "Rule",
+		"渠道":           "Channel",
+		"说明":           "Comment",
+		"到期时间":         "Expires",
+		"全部规则":         "All rules",
+		"结束":           "Expire",
+		"没有生效中的屏蔽":     "No active silences",
+		"渠道 ID（留空为全部）": "Channel ID (empty for all)",
+		"新增屏蔽":         "Add silence",
+		"告警规则":         "Alert rules",
+		"条件":           "Condition",
+		"持续":           "For",
+		"时段":           "Hours",
+		"（天请求数 ≥ %d）":  " (daily requests ≥ %d)",
+		"全天":           "All day",
+
+		// 容量规划
+		"建议": "Recommendation",
+		"当前 %d 个普号、%d 个付费号足以承载过去 %d 天的峰值需求（含 %d%% 余量）。":           "The current %d normal and %d paid channels can handle the peak demand of the last %d days (with %d%% headroom).",
+		"建议新增 %d 个普号（共需 %d 个），或新增 %d 个付费号（共需 %d 个）。":              "Add %d normal channels (%d needed in total), or %d paid channels (%d needed in total).",
+		"按过去 %d 天的峰值需求加 %d%% 余量计算；计算普号时保持付费号数量不变，计算付费号时保持普号数量不变。": "Based on the peak demand of the last %d days plus %d%% headroom; normal channels are computed with the paid count unchanged and vice versa.",
+		"需求与容量":   "Demand and capacity",
+		"每%s":     "Per %s",
+		"历史峰值":    "Peak",
+		"平均":      "Average",
+		"目标（含余量）": "Target (with headroom)",
+		"当前容量（%d 个普号 + %d 个付费号）": "Current capacity (%d normal + %d paid)",
+		"每天的需求":  "Daily demand",
+		"开始时间":   "Start",
+		"峰值":     "Peak",
+		"占当前天容量": "Of daily capacity",
+
+		// 消耗排行
+		"占全部请求":      "Share of requests",
+		"占天总限制":      "Share of daily limit",
+		"暂无请求":       "No requests yet",
+		"（共 %d 次请求）": " (%d requests)",
+		"按用户":        "By user",
+		"按令牌":        "By token",
+		"天总限制为所有渠道天限制之和（%d），统计于 %s，结果缓存 1 分钟。": "The daily limit is the sum of all channel limits (%d). Computed at %s, cached for 1 minute.",
+
+		// 日报
+		"时间范围":   "Period",
+		"自动禁用次数": "Disables",
+		"还没有日报，每次天窗口重置后自动生成": "No reports yet; one is generated each time the daily window resets",
+		"概览":            "Overview",
+		"错误数":           "Errors",
+		"（输入 %d，输出 %d）": " (prompt %d, completion %d)",
+		"有请求的渠道":        "Active channels",
+		"触发限制的渠道":       "Channels that hit limits",
+		"分钟超限次数":        "Minute limit hits",
+		"首次分钟超限":        "First minute limit",
+		"达到天限制":         "Daily limit reached",
+		"没有渠道触发限制":      "No channel hit a limit",
+		"错误率最高的渠道":      "Channels with the most errors",
+		"没有错误请求":        "No failed requests",
+		"用户排行":          "Top users",
+		"令牌排行":          "Top tokens",
+		"没有请求":          "No requests",
+	},
+}
+
+// Message 代码中生成、需要按页面语言显示的文本（如窗口描述、剩余时长）：
+// Key 为目录中的中文原文，Args 为填入的参数，参数中的 Message 按同一种语言翻译
+type Message struct {
+	Key  string
+	Args []interface{}
+}
+
+// newMessage 创建待翻译的文本
+func newMessage(key string, args ...interface{}) Message {
+	return Message{Key: key, Args: args}
+}
+
+// Translate 返回 lang 中的文本
+func (m Message) Translate(lang string) string {
+	return translate(lang, m.Key, m.Args...)
+}
+
+// String 返回中文文本，用于日志、终端和中文邮件
+func (m Message) String() string {
+	return m.Translate(LangZH)
+}
+
+// MarshalText 使 JSON 中仍然输出中文文本，接口格式不变
+func (m Message) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText 读回 MarshalText 输出的文本（如 tui --remote 读取的快照），读回的文本不再翻译
+func (m *Message) UnmarshalText(text []byte) error {
+	*m = Message{Key: string(text)}
+	return nil
+}
+
+// translate 把中文原文翻译为 lang，再按翻译后的格式填入参数，参数中的 Message 同样翻译。
+// 找不到翻译时使用原文，因此中文和用户数据（渠道名称等）原样输出
+func translate(lang, source string, args ...interface{}) string {
+	target, ok := catalog[lang][source]
+	if !ok {
+		target = source
+	}
+	if len(args) == 0 {
+		return target
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		if message, ok := arg.(Message); ok {
+			values[i] = message.Translate(lang)
+		} else {
+			values[i] = arg
+		}
+	}
+	return fmt.Sprintf(target, values...)
+}
+
+// translateValue 实现模板中的 t：source 可以是原文，也可以是代码生成的 Message
+func translateValue(lang string, source interface{}, args ...interface{}) string {
+	switch source := source.(type) {
+	case Message:
+		return source.Translate(lang)
+	case string:
+		return translate(lang, source, args...)
+	default:
+		return fmt.Sprint(source)
+	}
+}
+
+// langURL 返回切换到 lang 的链接，保留当前页面的其他查询参数 values
+func langURL(values url.Values, lang string) string {
+	result := url.Values{}
+	for key, value := range values {
+		result[key] = value
+	}
+	result.Set("lang", lang)
+	return "?" + result.Encode()
+}
+
+// requestLang 确定页面使用的语言：依次为查询参数 lang、cookie、Accept-Language 和 defaultLang
+func requestLang(r *http.Request, defaultLang string) string {
+	if lang := r.URL.Query().Get("lang"); supportedLang(lang) {
+		return lang
+	}
+	if cookie, err := r.Cookie(langCookie); err == nil && supportedLang(cookie.Value) {
+		return cookie.Value
+	}
+	if lang := acceptLanguage(r.Header.Get("Accept-Language")); lang != "" {
+		return lang
+	}
+	return defaultLang
+}
+
+// acceptLanguage 返回 Accept-Language 中权重最高的受支持语言，没有时为空
+func acceptLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if supportedLang(primary) && q > bestQ {
+			best, bestQ = primary, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io/fs"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		lang   string
+		source interface{}
+		args   []interface{}
+		want   string
+	}{
+		{LangZH, "可用", nil, "可用"},
+		{LangZH, "共 %d 个渠道", []interface{}{3}, "共 3 个渠道"},
+		{LangEN, "可用", nil, "Available"},
+		{LangEN, "共 %d 个渠道", []interface{}{3}, "3 channels"},
+		// 代码生成的文本按原文翻译后填入参数，参数中的 Message 同样翻译
+		{LangEN, Window{Kind: WindowRolling, Length: time.Minute}.Label(testNow), nil, "Last 1 min"},
+		{LangEN, Window{Kind: WindowFixed, Length: 24 * time.Hour, Offset: 8 * time.Hour}.Label(testNow), nil, "Today (resets daily at 08:00)"},
+		{LangEN, Window{Kind: WindowFixed, Length: 90 * time.Minute}.Label(testNow), nil, "This period (since 09:00, resets every 90 min)"},
+		{LangZH, Window{Kind: WindowRolling, Length: time.Minute}.Label(testNow), nil, "过去1分钟"},
+		{LangEN, formatRemaining(125), nil, "2m 5s"},
+		{LangEN, channelTypeName(99), nil, "Type 99"},
+		// 没有翻译的文本（如渠道名称）原样输出
+		{LangEN, "gcp-account-1", nil, "gcp-account-1"},
+		{LangEN, "搜索项目", nil, "搜索项目"},
+		{"fr", "可用", nil, "可用"},
+	}
+	for _, tt := range tests {
+		if got := translateValue(tt.lang, tt.source, tt.args...); got != tt.want {
+			t.Errorf("translate(%s, %v) = %q, want %q", tt.lang, tt.source, got, tt.want)
+		}
+	}
+}
+
+// TestCatalogCoversTemplates 确保模板中所有 {{t "..."}} 的原文都有英文翻译
+func TestCatalogCoversTemplates(t *testing.T) {
+	messages := regexp.MustCompile(`\{\{t "([^"]+)"`)
+	names, err := fs.Glob(webFS, "web/templates/*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		content, err := fs.ReadFile(webFS, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, match := range messages.FindAllStringSubmatch(string(content), -1) {
+			if _, ok := catalog[LangEN][match[1]]; !ok {
+				t.Errorf("%s: %q 没有英文翻译", name, match[1])
+			}
+		}
+	}
+	for _, name := range statusNames {
+		if _, ok := catalog[LangEN][name]; !ok {
+			t.Errorf("状态 %q 没有英文翻译", name)
+		}
+	}
+	for _, name := range tierNames {
+		if _, ok := catalog[LangEN][name]; !ok {
+			t.Errorf("类型 %q 没有英文翻译", name)
+		}
+	}
+	for _, names := range []map[string]string{anomalyKindNames, anomalyScopeNames} {
+		for _, name := range names {
+			if _, ok := catalog[LangEN][name]; !ok {
+				t.Errorf("异常 %q 没有英文翻译", name)
+			}
+		}
+	}
+	for _, name := range trendNames {
+		if _, ok := catalog[LangEN][name]; !ok {
+			t.Errorf("趋势 %q 没有英文翻译", name)
+		}
+	}
+}
+
+func TestRequestLang(t *testing.T) {
+	tests := []struct {
+		url            string
+		cookie         string
+		acceptLanguage string
+		want           string
+	}{
+		{"/", "", "", LangZH},
+		{"/", "", "en-US,en;q=0.9", LangEN},
+		{"/", "", "fr-FR,zh-CN;q=0.8,en;q=0.5", LangZH},
+		{"/", "", "zh;q=0.3,en;q=0.7", LangEN},
+		{"/", "", "fr", LangZH},
+		// cookie 优先于 Accept-Language，查询参数优先于 cookie
+		{"/", LangEN, "zh-CN", LangEN},
+		{"/?lang=zh", LangEN, "en", LangZH},
+		{"/?lang=fr", LangEN, "", LangEN},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.url, nil)
+		if tt.cookie != "" {
+			r.Header.Set("Cookie", langCookie+"="+tt.cookie)
+		}
+		if tt.acceptLanguage != "" {
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+		if got := requestLang(r, LangZH); got != tt.want {
+			t.Errorf("%s cookie=%q Accept-Language=%q: got %s, want %s", tt.url, tt.cookie, tt.acceptLanguage, got, tt.want)
+		}
+	}
+}
+
+func TestLangURL(t *testing.T) {
+	tests := []struct {
+		values url.Values
+		lang   string
+		want   string
+	}{
+		{nil, LangEN, "?lang=en"},
+		{url.Values{"sort": {"day"}, "page": {"2"}}, LangEN, "?lang=en&page=2&sort=day"},
+		// 只替换 lang，其他参数保留
+		{url.Values{"lang": {"en"}, "group_by": {"tier"}}, LangZH, "?group_by=tier&lang=zh"},
+	}
+	for _, tt := range tests {
+		if got := langURL(tt.values, tt.lang); got != tt.want {
+			t.Errorf("langURL(%v, %s) = %q, want %q", tt.values, tt.lang, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LatencyConfig 延迟统计的配置
+type LatencyConfig struct {
+	Windows    []time.Duration // 统计的滚动窗口，第一个用于卡片展示
+	SlowFactor float64         // p50 超过整体 p50 的多少倍视为偏慢
+	MinSamples int             // 样本数少于该值的窗口不参与偏慢判断
+	Interval   time.Duration
+}
+
+// parseDurations 解析逗号分隔的时长列表，例如 1h,24h
+func parseDurations(spec string) ([]time.Duration, error) {
+	var durations []time.Duration
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		d, err := time.ParseDuration(item)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("无效的时长 %q", item)
+		}
+		durations = append(durations, d)
+	}
+	if len(durations) == 0 {
+		return nil, fmt.Errorf("至少需要一个时长")
+	}
+	return durations, nil
+}
+
+// LatencyStats 一组请求耗时的百分位，单位为秒
+type LatencyStats struct {
+	Samples int `json:"samples"`
+	P50     int `json:"p50"`
+	P90     int `json:"p90"`
+	P99     int `json:"p99"`
+}
+
+// latencyHistogram 耗时（秒）到请求数的直方图
+type latencyHistogram map[int]int
+
+// stats 按最近秩法计算百分位
+func (h latencyHistogram) stats() LatencyStats {
+	var stats LatencyStats
+	values := make([]int, 0, len(h))
+	for value, count := range h {
+		values = append(values, value)
+		stats.Samples += count
+	}
+	if stats.Samples == 0 {
+		return stats
+	}
+	sort.Ints(values)
+	percentile := func(p int) int {
+		rank := (p*stats.Samples + 99) / 100
+		seen := 0
+		for _, value := range values {
+			seen += h[value]
+			if seen >= rank {
+				return value
+			}
+		}
+		return values[len(values)-1]
+	}
+	stats.P50, stats.P90, stats.P99 = percentile(50), percentile(90), percentile(99)
+	return stats
+}
+
+// ModelLatency 某个模型的延迟
+type ModelLatency struct {
+	Model string `json:"model"`
+	LatencyStats
+}
+
+// ChannelLatency 某个渠道在一个窗口内的延迟
+type ChannelLatency struct {
+	ChannelID int            `json:"channel_id"`
+	Overall   LatencyStats   `json:"overall"`
+	Models    []ModelLatency `json:"models"`
+	Slow      bool           `json:"slow"` // 样本足够且 p50 超过整体 p50 的 SlowFactor 倍
+}
+
+// LatencyWindow 一个窗口内整体和每个渠道的延迟
+type LatencyWindow struct {
+	Label    Message          `json:"label"`
+	Length   time.Duration    `json:"length"`
+	Pool     LatencyStats     `json:"pool"`
+	Models   []ModelLatency   `json:"models"`
+	Channels []ChannelLatency `json:"channels"` // 按渠道 ID 排序
+}
+
+// Channel 返回渠道在该窗口内的延迟
+func (w LatencyWindow) Channel(id int) (ChannelLatency, bool) {
+	i := sort.Search(len(w.Channels), func(i int) bool { return w.Channels[i].ChannelID >= id })
+	if i < len(w.Channels) && w.Channels[i].ChannelID == id {
+		return w.Channels[i], true
+	}
+	return ChannelLatency{}, false
+}
+
+// LatencyReport 所有窗口的延迟统计
+type LatencyReport struct {
+	Windows      []LatencyWindow `json:"windows"`
+	SlowChannels []int           `json:"slow_channels"` // 在所有样本足够的窗口中都偏慢的渠道
+	SlowFactor   float64         `json:"slow_factor"`
+	GeneratedAt  time.Time       `json:"generated_at"`
+}
+
+// IsSlow 判断渠道是否持续偏慢
+func (r *LatencyReport) IsSlow(id int) bool {
+	i := sort.SearchInts(r.SlowChannels, id)
+	return i < len(r.SlowChannels) && r.SlowChannels[i] == id
+}
+
+// modelLatencies 把按模型分组的直方图转换为按模型名排序的列表
+func modelLatencies(histograms map[string]latencyHistogram) []ModelLatency {
+	models := make([]ModelLatency, 0, len(histograms))
+	for model, histogram := range histograms {
+		models = append(models, ModelLatency{Model: model, LatencyStats: histogram.stats()})
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Model < models[j].Model })
+	return models
+}
+
+// buildLatencyWindow 根据一个窗口内的耗时分布计算整体、每个模型和每个渠道的百分位
+func buildLatencyWindow(length time.Duration, counts []LatencyCounts, config LatencyConfig) LatencyWindow {
+	pool := make(latencyHistogram)
+	poolModels := make(map[string]latencyHistogram)
+	channels := make(map[int]latencyHistogram)
+	channelModels := make(map[int]map[string]latencyHistogram)
+	for _, c := range counts {
+		pool[c.UseTime] += c.Count
+		if poolModels[c.Model] == nil {
+			poolModels[c.Model] = make(latencyHistogram)
+		}
+		poolModels[c.Model][c.UseTime] += c.Count
+		if channels[c.ChannelID] == nil {
+			channels[c.ChannelID] = make(latencyHistogram)
+			channelModels[c.ChannelID] = make(map[string]latencyHistogram)
+		}
+		channels[c.ChannelID][c.UseTime] += c.Count
+		if channelModels[c.ChannelID][c.Model] == nil {
+			channelModels[c.ChannelID][c.Model] = make(latencyHistogram)
+		}
+		channelModels[c.ChannelID][c.Model][c.UseTime] += c.Count
+	}
+
+	window := LatencyWindow{
+		Label:  newMessage("过去%s", formatWindowLength(length)),
+		Length: length,
+		Pool:   pool.stats(),
+		Models: modelLatencies(poolModels),
+	}
+	for id, histogram := range channels {
+		latency := ChannelLatency{ChannelID: id, Overall: histogram.stats(), Models: modelLatencies(channelModels[id])}
+		latency.Slow = latency.Overall.Samples >= config.MinSamples &&
+			float64(latency.Overall.P50) > float64(window.Pool.P50)*config.SlowFactor
+		window.Channels = append(window.Channels, latency)
+	}
+	sort.Slice(window.Channels, func(i, j int) bool { return window.Channels[i].ChannelID < window.Channels[j].ChannelID })
+	return window
+}
+
+// slowChannels 返回在所有样本足够的窗口中都偏慢的渠道，至少需要一个样本足够的窗口
+func slowChannels(windows []LatencyWindow, config LatencyConfig) []int {
+	eligible := make(map[int]int)
+	slow := make(map[int]int)
+	for _, window := range windows {
+		for _, channel := range window.Channels {
+			if channel.Overall.Samples < config.MinSamples {
+				continue
+			}
+			eligible[channel.ChannelID]++
+			if channel.Slow {
+				slow[channel.ChannelID]++
+			}
+		}
+	}
+	var ids []int
+	for id, n := range eligible {
+		if slow[id] == n {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// applyLatency 把第一个窗口的延迟填入面板数据
+func applyLatency(snapshot *Snapshot, report *LatencyReport) {
+	if len(report.Windows) == 0 {
+		return
+	}
+	window := report.Windows[0]
+	snapshot.LatencyWindowLabel = window.Label
+	for i := range snapshot.Channels {
+		view := &snapshot.Channels[i]
+		if latency, ok := window.Channel(view.ID); ok {
+			view.Latency = &latency.Overall
+		}
+		view.IsSlow = report.IsSlow(view.ID)
+	}
+}
+
+// LatencyTracker 在后台定期统计各窗口的延迟
+type LatencyTracker struct {
+	store    Store
+	location *time.Location
+	config   LatencyConfig
+
+	mu     sync.RWMutex
+	report *LatencyReport
+}
+
+// NewLatencyTracker 创建延迟统计器
+func NewLatencyTracker(store Store, location *time.Location, config LatencyConfig) *LatencyTracker {
+	return &LatencyTracker{store: store, location: location, config: config}
+}
+
+// Run 立即统计一次，然后按间隔循环统计，直到 ctx 被取消
+func (t *LatencyTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := t.Refresh(ctx); err != nil {
+			slog.ErrorContext(ctx, "统计延迟失败", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh 立即统计一次延迟
+func (t *LatencyTracker) Refresh(ctx context.Context) (*LatencyReport, error) {
+	now := time.Now().In(t.location)
+	report := &LatencyReport{SlowFactor: t.config.SlowFactor, GeneratedAt: now}
+	for _, length := range t.config.Windows {
+		counts, err := t.store.Latencies(ctx, now.Add(-length), now)
+		if err != nil {
+			return nil, fmt.Errorf("统计过去%s的延迟失败: %w", formatWindowLength(length), err)
+		}
+		report.Windows = append(report.Windows, buildLatencyWindow(length, counts, t.config))
+	}
+	report.SlowChannels = slowChannels(report.Windows, t.config)
+
+	t.mu.Lock()
+	t.report = report
+	t.mu.Unlock()
+	return report, nil
+}
+
+// Latest 返回最近一次的统计结果，尚未统计成功时返回 nil
+func (t *LatencyTracker) Latest() *LatencyReport {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.report
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogramStats(t *testing.T) {
+	tests := []struct {
+		name      string
+		histogram latencyHistogram
+		want      LatencyStats
+	}{
+		{"空", latencyHistogram{}, LatencyStats{}},
+		{"单个值", latencyHistogram{3: 1}, LatencyStats{Samples: 1, P50: 3, P90: 3, P99: 3}},
+		// 1..100 秒各一次
+		{"均匀分布", func() latencyHistogram {
+			h := latencyHistogram{}
+			for i := 1; i <= 100; i++ {
+				h[i] = 1
+			}
+			return h
+		}(), LatencyStats{Samples: 100, P50: 50, P90: 90, P99: 99}},
+		{"长尾", latencyHistogram{2: 90, 10: 9, 60: 1}, LatencyStats{Samples: 100, P50: 2, P90: 2, P99: 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.histogram.stats(); got != tt.want {
+				t.Errorf("stats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatencyTracker(t *testing.T) {
+	now := time.Now()
+	var logs []LogEntry
+	add := func(channelID, count, useTime int, ago time.Duration, model string) {
+		for i := 0; i < count; i++ {
+			logs = append(logs, LogEntry{ChannelID: channelID, CreatedAt: now.Add(-ago).Unix(), Type: logTypeConsume, UseTime: useTime, Model: model})
+		}
+	}
+	// 渠道 1、2 正常，渠道 3 在两个窗口中都偏慢，渠道 4 只在 24 小时窗口中偏慢
+	add(1, 30, 4, time.Minute, "gemini-2.5-pro")
+	add(2, 30, 4, time.Minute, "gemini-2.5-pro")
+	add(3, 20, 10, time.Minute, "gemini-2.5-pro")
+	add(3, 5, 3, time.Minute, "gemini-2.5-flash")
+	add(4, 20, 4, time.Minute, "gemini-2.5-pro")
+	add(4, 40, 20, 2*time.Hour, "gemini-2.5-pro")
+	// 样本不足的渠道不判断
+	add(5, 5, 60, time.Minute, "gemini-2.5-pro")
+	// 错误日志不参与统计
+	logs = append(logs, LogEntry{ChannelID: 1, CreatedAt: now.Unix(), Type: 5, UseTime: 100})
+
+	store := NewMemoryStore([]Channel{{ID: 1, Status: "1"}, {ID: 3, Status: "1"}}, logs)
+	config := LatencyConfig{Windows: []time.Duration{time.Hour, 24 * time.Hour}, SlowFactor: 1.5, MinSamples: 20, Interval: time.Minute}
+	report, err := NewLatencyTracker(store, time.Local, config).Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Windows) != 2 || report.Windows[0].Label.String() != "过去1小时" {
+		t.Fatalf("Windows = %+v", report.Windows)
+	}
+	hour := report.Windows[0]
+	if hour.Pool.Samples != 110 || hour.Pool.P50 != 4 {
+		t.Errorf("Pool = %+v", hour.Pool)
+	}
+	if channel, _ := hour.Channel(1); channel.Overall != (LatencyStats{Samples: 30, P50: 4, P90: 4, P99: 4}) {
+		t.Errorf("渠道 1 = %+v", channel.Overall)
+	}
+	if channel, _ := hour.Channel(3); !channel.Slow || len(channel.Models) != 2 || channel.Models[0].Model != "gemini-2.5-flash" {
+		t.Errorf("渠道 3 = %+v", channel)
+	}
+	if want := []int{3}; !reflect.DeepEqual(report.SlowChannels, want) {
+		t.Errorf("SlowChannels = %v, want %v", report.SlowChannels, want)
+	}
+
+	snapshot := buildSnapshot(store.channels, nil, testQuota, testNow)
+	applyLatency(snapshot, report)
+	detail, ok := buildChannelDetail(snapshot, report, 3)
+	if !ok || !detail.Channel.IsSlow || detail.Channel.Latency.P50 != 10 || len(detail.Latency) != 2 {
+		t.Fatalf("detail = %+v", detail)
+	}
+	if models := detail.Latency[0].Models; models[1].Model != "gemini-2.5-pro" || models[1].Pool.P50 != 4 {
+		t.Errorf("Models = %+v", models)
+	}
+	if _, ok := buildChannelDetail(snapshot, report, 99); ok {
+		t.Error("不存在的渠道应返回 false")
+	}
+
+	renderer, err := NewRenderer(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, "index.html", queried(t, snapshot, "")); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "p50 10s · p90 10s · p99 10s <span class=\"slow-badge\">偏慢</span>") {
+		t.Error("卡片中缺少偏慢的延迟")
+	}
+	buf.Reset()
+	if err := renderer.Render(&buf, "channel.html", detail); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<td>gemini-2.5-flash</td><td>5</td><td>3s</td>") {
+		t.Error("详情页中缺少按模型的延迟")
+	}
+}
+
+func TestChannelIDFromPath(t *testing.T) {
+	tests := []struct {
+		path string
+		id   int
+		ok   bool
+	}{
+		{"/channels/12", 12, true},
+		{"/channels/", 0, false},
+		{"/channels/abc", 0, false},
+		{"/channels/12/extra", 0, false},
+	}
+	for _, tt := range tests {
+		id, ok := channelIDFromPath(tt.path, "/channels/")
+		if id != tt.id || ok != tt.ok {
+			t.Errorf("channelIDFromPath(%q) = %d, %v, want %d, %v", tt.path, id, ok, tt.id, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ChannelShare 某个消费者在某个渠道上的请求数
+type ChannelShare struct {
+	ChannelID int `json:"channel_id"`
+	Requests  int `json:"requests"`
+}
+
+// Consumer 排行榜中的一个用户或令牌
+type Consumer struct {
+	UserID     int            `json:"user_id"`
+	Username   string         `json:"username"`
+	TokenName  string         `json:"token_name,omitempty"` // 用户排行中为空
+	Requests   int            `json:"requests"`
+	Share      float64        `json:"share"`       // 占该时间范围内全部请求的百分比
+	QuotaShare float64        `json:"quota_share"` // 占所有渠道天限制之和的百分比
+	Channels   []ChannelShare `json:"channels"`    // 按请求数从多到少排序
+}
+
+// Name 返回用于显示的名称：用户名（没有用户名时为用户 ID），令牌排行中再加上令牌名
+func (c Consumer) Name() string {
+	name := c.Username
+	if name == "" {
+		name = fmt.Sprintf("用户 %d", c.UserID)
+	}
+	if c.TokenName != "" {
+		name += " / " + c.TokenName
+	}
+	return name
+}
+
+// LeaderboardWindow 一个时间范围内的排行
+type LeaderboardWindow struct {
+	Label         Message    `json:"label"`
+	Start         time.Time  `json:"start"`
+	TotalRequests int        `json:"total_requests"`
+	Users         []Consumer `json:"users"`
+	Tokens        []Consumer `json:"tokens"`
+}
+
+// Leaderboard 当前天窗口和过去一小时的消耗排行
+type Leaderboard struct {
+	QuotaLimit  int               `json:"quota_limit"` // 所有渠道天限制之和
+	Day         LeaderboardWindow `json:"day"`
+	Hour        LeaderboardWindow `json:"hour"`
+	GeneratedAt time.Time         `json:"generated_at"`
+
+	size int // Truncate 保留的名次，用于生成页面链接
+}
+
+// Truncate 返回每个排行只保留前 n 名的副本
+func (l *Leaderboard) Truncate(n int) *Leaderboard {
+	top := func(consumers []Consumer) []Consumer {
+		if len(consumers) > n {
+			return consumers[:n]
+		}
+		return consumers
+	}
+	truncated := *l
+	truncated.size = n
+	truncated.Day.Users, truncated.Day.Tokens = top(l.Day.Users), top(l.Day.Tokens)
+	truncated.Hour.Users, truncated.Hour.Tokens = top(l.Hour.Users), top(l.Hour.Tokens)
+	return &truncated
+}
+
+// Values 返回页面的查询参数，省略默认值
+func (l *Leaderboard) Values() url.Values {
+	values := url.Values{}
+	if l.size != 0 && l.size != defaultLeaderboardSize {
+		values.Set("limit", strconv.Itoa(l.size))
+	}
+	return values
+}
+
+// quotaLimit 返回所有渠道天限制之和，与面板上的天总限制相同
+func quotaLimit(channels []Channel, quota QuotaConfig) int {
+	total := 0
+	for _, channel := range channels {
+		limits, _ := quota.LimitsFor(channel)
+		total += limits.Day
+	}
+	return total
+}
+
+// rankConsumers 按用户（withToken 时按用户和令牌）汇总请求数并从多到少排序
+func rankConsumers(counts []ConsumerCounts, total, quotaLimit int, withToken bool) []Consumer {
+	type key struct {
+		userID    int
+		tokenName string
+	}
+	consumers := make(map[key]*Consumer)
+	channels := make(map[key]map[int]int)
+	for _, c := range counts {
+		k := key{userID: c.UserID}
+		if withToken {
+			k.tokenName = c.TokenName
+		}
+		consumer, ok := consumers[k]
+		if !ok {
+			consumer = &Consumer{UserID: c.UserID, Username: c.Username, TokenName: k.tokenName}
+			consumers[k] = consumer
+			channels[k] = make(map[int]int)
+		}
+		consumer.Requests += c.Requests
+		channels[k][c.ChannelID] += c.Requests
+	}
+
+	ranked := make([]Consumer, 0, len(consumers))
+	for k, consumer := range consumers {
+		if total > 0 {
+			consumer.Share = float64(consumer.Requests) / float64(total) * 100
+		}
+		if quotaLimit > 0 {
+			consumer.QuotaShare = float64(consumer.Requests) / float64(quotaLimit) * 100
+		}
+		for id, requests := range channels[k] {
+			consumer.Channels = append(consumer.Channels, ChannelShare{ChannelID: id, Requests: requests})
+		}
+		sort.Slice(consumer.Channels, func(i, j int) bool {
+			if consumer.Channels[i].Requests != consumer.Channels[j].Requests {
+				return consumer.Channels[i].Requests > consumer.Channels[j].Requests
+			}
+			return consumer.Channels[i].ChannelID < consumer.Channels[j].ChannelID
+		})
+		ranked = append(ranked, *consumer)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Requests != ranked[j].Requests {
+			return ranked[i].Requests > ranked[j].Requests
+		}
+		if ranked[i].UserID != ranked[j].UserID {
+			return ranked[i].UserID < ranked[j].UserID
+		}
+		return ranked[i].TokenName < ranked[j].TokenName
+	})
+	return ranked
+}
+
+// buildLeaderboardWindow 构建一个时间范围内的用户和令牌排行
+func buildLeaderboardWindow(label Message, start time.Time, counts []ConsumerCounts, quotaLimit int) LeaderboardWindow {
+	window := LeaderboardWindow{Label: label, Start: start}
+	for _, c := range counts {
+		window.TotalRequests += c.Requests
+	}
+	window.Users = rankConsumers(counts, window.TotalRequests, quotaLimit, false)
+	window.Tokens = rankConsumers(counts, window.TotalRequests, quotaLimit, true)
+	return window
+}
+
+// 排行默认和最多显示的条数
+const (
+	defaultLeaderboardSize = 20
+	maxLeaderboardSize     = 200
+)
+
+// leaderboardSize 从查询参数 limit 读取要显示的条数
+func leaderboardSize(r *http.Request) int {
+	n, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || n <= 0 {
+		return defaultLeaderboardSize
+	}
+	return min(n, maxLeaderboardSize)
+}
+
+// leaderboardCacheTTL 排行榜的缓存时间
+const leaderboardCacheTTL = time.Minute
+
+// LeaderboardBuilder 按需统计消耗排行并缓存结果
+type LeaderboardBuilder struct {
+	store    Store
+	quota    QuotaConfig
+	location *time.Location
+
+	mu          sync.Mutex
+	leaderboard *Leaderboard
+}
+
+// NewLeaderboardBuilder 创建排行榜统计器
+func NewLeaderboardBuilder(store Store, quota QuotaConfig, location *time.Location) *LeaderboardBuilder {
+	return &LeaderboardBuilder{store: store, quota: quota, location: location}
+}
+
+// Leaderboard 返回消耗排行，缓存未过期时直接返回缓存的结果
+func (b *LeaderboardBuilder) Leaderboard(ctx context.Context) (*Leaderboard, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now().In(b.location)
+	if b.leaderboard != nil && now.Sub(b.leaderboard.GeneratedAt) < leaderboardCacheTTL {
+		return b.leaderboard, nil
+	}
+
+	channels, err := b.store.Channels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询渠道失败: %w", err)
+	}
+	dayStart, hourStart := b.quota.DayWindow.Start(now), now.Add(-time.Hour)
+	dayCounts, err := b.store.Consumers(ctx, dayStart, now)
+	if err != nil {
+		return nil, fmt.Errorf("统计日志失败: %w", err)
+	}
+	hourCounts, err := b.store.Consumers(ctx, hourStart, now)
+	if err != nil {
+		return nil, fmt.Errorf("统计日志失败: %w", err)
+	}
+
+	limit := quotaLimit(channels, b.quota)
+	b.leaderboard = &Leaderboard{
+		QuotaLimit:  limit,
+		Day:         buildLeaderboardWindow(b.quota.DayWindow.Label(now), dayStart, dayCounts, limit),
+		Hour:        buildLeaderboardWindow(newMessage("过去%s", formatWindowLength(time.Hour)), hourStart, hourCounts, limit),
+		GeneratedAt: now,
+	}
+	return b.leaderboard, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeaderboard(t *testing.T) {
+	now := time.Now()
+	logs := func(count int, ago time.Duration, entry LogEntry) []LogEntry {
+		var entries []LogEntry
+		for i := 0; i < count; i++ {
+			entry.CreatedAt = now.Add(-ago).Unix()
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+	var entries []LogEntry
+	entries = append(entries, logs(6, time.Minute, LogEntry{ChannelID: 1, UserID: 1, Username: "alice", TokenName: "batch"})...)
+	entries = append(entries, logs(2, time.Minute, LogEntry{ChannelID: 2, UserID: 1, Username: "alice", TokenName: "chat"})...)
+	entries = append(entries, logs(2, 2*time.Hour, LogEntry{ChannelID: 2, UserID: 2, Username: "bob", TokenName: "default"})...)
+	store := NewMemoryStore([]Channel{{ID: 1, Status: "1"}, {ID: 2, Status: "1", Tag: "gcp"}}, entries)
+
+	// 天窗口使用滚动的 24 小时，保证测试数据都落在窗口内
+	quota := testQuota
+	quota.DayWindow = Window{Kind: WindowRolling, Length: 24 * time.Hour}
+	builder := NewLeaderboardBuilder(store, quota, time.Local)
+	leaderboard, err := builder.Leaderboard(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if leaderboard.QuotaLimit != 125 {
+		t.Errorf("QuotaLimit = %d, want 125", leaderboard.QuotaLimit)
+	}
+	day := leaderboard.Day
+	if day.TotalRequests != 10 || len(day.Users) != 2 || len(day.Tokens) != 3 {
+		t.Fatalf("Day = %+v", day)
+	}
+	alice := day.Users[0]
+	if alice.Name() != "alice" || alice.Requests != 8 || alice.Share != 80 || alice.QuotaShare != 6.4 {
+		t.Errorf("alice = %+v", alice)
+	}
+	if want := []ChannelShare{{1, 6}, {2, 2}}; !reflect.DeepEqual(alice.Channels, want) {
+		t.Errorf("alice.Channels = %v, want %v", alice.Channels, want)
+	}
+	if day.Tokens[0].Name() != "alice / batch" || day.Tokens[0].Requests != 6 {
+		t.Errorf("Tokens[0] = %+v", day.Tokens[0])
+	}
+
+	// 过去一小时不包括 bob 两小时前的请求
+	if hour := leaderboard.Hour; hour.TotalRequests != 8 || len(hour.Users) != 1 || hour.Users[0].Share != 100 {
+		t.Errorf("Hour = %+v", hour)
+	}
+
+	// 缓存期内返回同一结果
+	if again, _ := builder.Leaderboard(context.Background()); again != leaderboard {
+		t.Error("缓存期内应返回缓存的结果")
+	}
+
+	truncated := leaderboard.Truncate(1)
+	if len(truncated.Day.Users) != 1 || len(truncated.Day.Tokens) != 1 || len(leaderboard.Day.Users) != 2 {
+		t.Errorf("Truncate(1) = %+v", truncated.Day)
+	}
+
+	renderer, err := NewRenderer(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, "leaderboard.html", leaderboard); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"alice / batch", "#1×6、#2×2", "共 10 次请求"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("页面中缺少 %q", want)
+		}
+	}
+}
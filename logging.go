@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 日志格式
+const (
+	LogFormatJSON = "json" // 每行一个 JSON 对象，便于日志系统检索
+	LogFormatText = "text" // key=value 形式，便于在终端中阅读
+)
+
+// LogConfig 日志的格式和级别
+type LogConfig struct {
+	Format string
+	Level  slog.Level
+}
+
+// parseLogLevel 解析 debug、info、warn、error，不区分大小写
+func parseLogLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("%q，可选 debug、info、warn 或 error", value)
+	}
+	return level, nil
+}
+
+// newLogger 创建写入 w 的 logger，每条日志会带上 ctx 中的请求 ID 和 trace ID
+func newLogger(w io.Writer, config LogConfig) *slog.Logger {
+	options := &slog.HandlerOptions{Level: config.Level}
+	var handler slog.Handler
+	if config.Format == LogFormatText {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// setupLogging 设置默认 logger，输出到标准错误；仍使用标准库 log 的代码会以 INFO 级别经过同一个 logger
+func setupLogging(config LogConfig) {
+	slog.SetDefault(newLogger(os.Stderr, config))
+}
+
+// contextHandler 从 ctx 中取出请求 ID 和当前 span，作为属性加到每条日志上，
+// 便于把页面请求的日志与数据库查询、链路追踪对应起来
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestIDFrom(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// requestIDHeader 请求 ID 的请求头和响应头，反向代理已经生成时沿用代理的 ID
+const requestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// withRequestID 返回带有请求 ID 的 ctx
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestIDFrom 返回 ctx 中的请求 ID，不是 HTTP 请求时返回空字符串
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID 生成 16 位十六进制的随机请求 ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID 判断请求头中的 ID 是否可以直接写入日志：不超过 64 个字符，只包含字母、数字和 -_.:
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	return strings.IndexFunc(id, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || strings.ContainsRune("-_.:", r))
+	}) < 0
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// captureLogs 把默认 logger 换成写入缓冲区的 JSON logger，测试结束后恢复
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(newLogger(&buf, LogConfig{Format: LogFormatJSON, Level: slog.LevelDebug}))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logLines 解析每行一个 JSON 对象的日志
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("日志不是 JSON: %q", line)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestInstrument(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/reports/", func(w http.ResponseWriter, r *http.Request) {
+		slog.ErrorContext(r.Context(), "读取日报失败")
+		http.Error(w, "读取日报失败", http.StatusInternalServerError)
+	})
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"生成请求 ID", "", false},
+		{"沿用代理的请求 ID", "edge-1234.5", true},
+		{"请求 ID 含非法字符", "bad id\nforged=1", false},
+	}
+	for _, tt := range tests {
+		buf := captureLogs(t)
+		r := httptest.NewRequest(http.MethodGet, "/reports/2026-10-17", nil)
+		if tt.header != "" {
+			r.Header.Set(requestIDHeader, tt.header)
+		}
+		rec := httptest.NewRecorder()
+		instrument(mux).ServeHTTP(rec, r)
+
+		id := rec.Header().Get(requestIDHeader)
+		if tt.keep && id != tt.header || !tt.keep && len(id) != 16 {
+			t.Errorf("%s: request id = %q", tt.name, id)
+		}
+		lines := logLines(t, buf)
+		if len(lines) != 2 {
+			t.Fatalf("%s: got %d log lines, want 2:\n%s", tt.name, len(lines), buf)
+		}
+		// 处理函数中的日志和访问日志带有同一个请求 ID
+		for _, line := range lines {
+			if line["request_id"] != id {
+				t.Errorf("%s: log %v missing request_id %q", tt.name, line, id)
+			}
+		}
+		access := lines[1]
+		if access["route"] != "/reports/" || access["status"] != float64(http.StatusInternalServerError) || access["level"] != "INFO" {
+			t.Errorf("%s: access log = %v", tt.name, access)
+		}
+	}
+}
+
+func TestContextHandlerAddsTrace(t *testing.T) {
+	buf := captureLogs(t)
+	span := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), span)
+	slog.InfoContext(ctx, "采集渠道数据")
+	slog.Info("没有 span")
+
+	lines := logLines(t, buf)
+	if lines[0]["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || lines[0]["span_id"] != "00f067aa0ba902b7" {
+		t.Errorf("log = %v, want trace_id and span_id", lines[0])
+	}
+	if _, ok := lines[1]["trace_id"]; ok {
+		t.Errorf("log = %v, want no trace_id", lines[1])
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		value string
+		want  slog.Level
+		ok    bool
+	}{
+		{"debug", slog.LevelDebug, true},
+		{"INFO", slog.LevelInfo, true},
+		{"warn", slog.LevelWarn, true},
+		{"error", slog.LevelError, true},
+		{"verbose", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseLogLevel(tt.value)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseLogLevel(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+}
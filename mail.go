@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// SMTP 连接的加密方式
+const (
+	SMTPStartTLS = "starttls" // 明文连接后升级，服务器不支持时报错
+	SMTPTLS      = "tls"      // 直接建立 TLS 连接，通常使用 465 端口
+	SMTPPlain    = "none"     // 不加密，只适合本机或内网的中继
+)
+
+// SMTPConfig 发送邮件使用的 SMTP 服务器
+type SMTPConfig struct {
+	Host     string // 为空时不发送邮件
+	Port     string
+	TLS      string // SMTPStartTLS、SMTPTLS 或 SMTPPlain
+	Username string // 为空时不进行认证
+	Password string
+	From     string
+}
+
+// Mailer 通过 SMTP 发送邮件
+type Mailer struct {
+	config    SMTPConfig
+	timeout   time.Duration
+	tlsConfig *tls.Config // 为 nil 时使用系统根证书，测试中替换为自签名证书
+}
+
+// NewMailer 创建邮件发送器
+func NewMailer(config SMTPConfig) *Mailer {
+	return &Mailer{config: config, timeout: 30 * time.Second}
+}
+
+// buildMessage 生成同时包含纯文本和 HTML 两个版本的邮件
+func buildMessage(from string, to []string, subject, text, html string, now time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := strings.NewReader(part.content).WriteTo(w); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// clientTLSConfig 返回连接 SMTP 服务器使用的 TLS 配置
+func (m *Mailer) clientTLSConfig() *tls.Config {
+	config := &tls.Config{}
+	if m.tlsConfig != nil {
+		config = m.tlsConfig.Clone()
+	}
+	config.ServerName = m.config.Host
+	return config
+}
+
+// Send 向 to 发送一封同时包含纯文本和 HTML 版本的邮件
+func (m *Mailer) Send(ctx context.Context, to []string, subject, text, html string) error {
+	if len(to) == 0 {
+		return errors.New("没有收件人")
+	}
+	msg, err := buildMessage(m.config.From, to, subject, text, html, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	addr := net.JoinHostPort(m.config.Host, m.config.Port)
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	if m.config.TLS == SMTPTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: m.clientTLSConfig()}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if m.config.TLS == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("SMTP 服务器不支持 STARTTLS")
+		}
+		if err := client.StartTLS(m.clientTLSConfig()); err != nil {
+			return fmt.Errorf("STARTTLS 失败: %w", err)
+		}
+	}
+	if m.config.Username != "" {
+		// PlainAuth 只允许在 TLS 连接或本机上发送密码
+		auth := smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP 认证失败: %w", err)
+		}
+	}
+	if err := client.Mail(m.config.From); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("收件人 %s 被拒绝: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// newMailer 根据配置创建邮件发送器，未配置 SMTP_HOST 时返回 nil
+func newMailer(cfg *Config) *Mailer {
+	if cfg.SMTP.Host == "" {
+		return nil
+	}
+	return NewMailer(cfg.SMTP)
+}
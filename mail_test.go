@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// smtpMessage 本地 SMTP 替身收到的一封邮件
+type smtpMessage struct {
+	Auth string // AUTH 命令的参数，未认证时为空
+	From string
+	To   []string
+	Data string
+}
+
+// newStandInMailer 在本地启动一个只支持最基本命令的 SMTP 替身，返回连接到它的 Mailer 和收到的邮件。
+// tlsMode 与 SMTPConfig.TLS 相同，替身使用 httptest 的自签名证书
+func newStandInMailer(t *testing.T, tlsMode string) (*Mailer, <-chan smtpMessage) {
+	t.Helper()
+	certServer := httptest.NewTLSServer(nil)
+	serverTLS := &tls.Config{Certificates: certServer.TLS.Certificates}
+	roots := x509.NewCertPool()
+	roots.AddCert(certServer.Certificate())
+	certServer.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsMode == SMTPTLS {
+		listener = tls.NewListener(listener, serverTLS)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan smtpMessage, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTPStandIn(conn, tlsMode == SMTPStartTLS, serverTLS, messages)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	mailer := NewMailer(SMTPConfig{Host: host, Port: port, TLS: tlsMode, From: "monitor@example.com"})
+	mailer.tlsConfig = &tls.Config{RootCAs: roots}
+	return mailer, messages
+}
+
+func serveSMTPStandIn(conn net.Conn, startTLS bool, serverTLS *tls.Config, messages chan<- smtpMessage) {
+	defer func() { conn.Close() }()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	var msg smtpMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			if startTLS {
+				reply("250-localhost")
+				reply("250 STARTTLS")
+			} else {
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			}
+		case command == "STARTTLS":
+			reply("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, serverTLS)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r, startTLS = tlsConn, bufio.NewReader(tlsConn), false
+		case strings.HasPrefix(command, "AUTH"):
+			msg.Auth = line[len("AUTH "):]
+			reply("235 Authentication successful")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			msg = smtpMessage{Auth: msg.Auth, From: strings.Trim(line[len("MAIL FROM:"):], "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			msg.To = append(msg.To, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			msg.Data = data.String()
+			messages <- msg
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestMailerSend(t *testing.T) {
+	mailer, messages := newStandInMailer(t, SMTPPlain)
+	err := mailer.Send(context.Background(), []string{"a@example.com", "b@example.com"}, "渠道日报", "# 纯文本", "<h1>HTML</h1>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := <-messages
+	if msg.From != "monitor@example.com" || len(msg.To) != 2 || msg.To[1] != "b@example.com" {
+		t.Errorf("信封 = %+v", msg)
+	}
+	for _, want := range []string{
+		"Subject: =?utf-8?q?",
+		"Content-Type: multipart/alternative",
+		"Content-Type: text/plain; charset=utf-8",
+		"# 纯文本",
+		"<h1>HTML</h1>",
+	} {
+		if !strings.Contains(msg.Data, want) {
+			t.Errorf("邮件中缺少 %q:\n%s", want, msg.Data)
+		}
+	}
+
+	if err := mailer.Send(context.Background(), nil, "主题", "", ""); err == nil {
+		t.Error("没有收件人时应返回错误")
+	}
+}
+
+func TestMailerTLS(t *testing.T) {
+	for _, mode := range []string{SMTPStartTLS, SMTPTLS} {
+		t.Run(mode, func(t *testing.T) {
+			mailer, messages := newStandInMailer(t, mode)
+			mailer.config.Username, mailer.config.Password = "monitor", "secret"
+			if err := mailer.Send(context.Background(), []string{"ops@example.com"}, "主题", "正文", "<p>正文</p>"); err != nil {
+				t.Fatal(err)
+			}
+			// 替身只在 TLS 连接上提供 AUTH，能认证说明连接已加密
+			if msg := <-messages; msg.Auth == "" || len(msg.To) != 1 {
+				t.Errorf("msg = %+v", msg)
+			}
+		})
+	}
+
+	// 要求 STARTTLS 但服务器不支持时不能以明文发送
+	mailer, _ := newStandInMailer(t, SMTPPlain)
+	mailer.config.TLS = SMTPStartTLS
+	if err := mailer.Send(context.Background(), []string{"ops@example.com"}, "主题", "", ""); err == nil {
+		t.Error("服务器不支持 STARTTLS 时应返回错误")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// procedureTemplate 内嵌的存储过程模板
+//
+//go:embed update_channels_procedure.sql
+var procedureTemplate string
+
+// procedureParams 渲染存储过程模板所需的参数
+type procedureParams struct {
+	Normal                TierLimits
+	Paid                  TierLimits
+	PaidTag               string
+	MinuteWindowSeconds   int64
+	MinuteCooldownSeconds int64
+	DayReset              string // 天窗口每天的重置时刻，格式 HH:MM:SS
+	UTCOffsetSeconds      int    // TIMEZONE 当前相对 UTC 的偏移，天窗口按该时区对齐
+}
+
+// newProcedureParams 根据配额配置生成模板参数。
+// 存储过程只能表达滚动的分钟窗口和每天重置一次的天窗口，其他窗口需要改用 enforce 子命令。
+// 时区偏移取 location 在 now 时刻的值，有夏令时的时区切换后需要重新安装
+func newProcedureParams(quota QuotaConfig, location *time.Location, now time.Time) (procedureParams, error) {
+	if quota.MinuteWindow.Kind != WindowRolling || quota.MinuteWindow.Length%time.Second != 0 {
+		return procedureParams{}, fmt.Errorf("存储过程只支持 rolling:<秒数> 形式的分钟窗口，当前为 %s，请改用 enforce 子命令", quota.MinuteWindow)
+	}
+	if quota.DayWindow.Kind != WindowFixed || quota.DayWindow.Length != 24*time.Hour {
+		return procedureParams{}, fmt.Errorf("存储过程只支持 fixed:24h@HH:MM 形式的天窗口，当前为 %s，请改用 enforce 子命令", quota.DayWindow)
+	}
+	offset := quota.DayWindow.Offset
+	_, utcOffset := now.In(location).Zone()
+	return procedureParams{
+		Normal:                quota.Normal,
+		Paid:                  quota.Paid,
+		PaidTag:               quota.PaidTag,
+		MinuteWindowSeconds:   int64(quota.MinuteWindow.Length / time.Second),
+		MinuteCooldownSeconds: int64(quota.MinuteCooldown / time.Second),
+		DayReset: fmt.Sprintf("%02d:%02d:%02d",
+			int(offset.Hours()), int(offset.Minutes())%60, int(offset.Seconds())%60),
+		UTCOffsetSeconds: utcOffset,
+	}, nil
+}
+
+// sqlString 将字符串转义为 SQL 字符串字面量
+func sqlString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// renderProcedure 按配额配置和时区渲染存储过程，结果可以直接用 mysql 客户端导入
+func renderProcedure(quota QuotaConfig, location *time.Location, now time.Time) (string, error) {
+	params, err := newProcedureParams(quota, location, now)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New("procedure").Funcs(template.FuncMap{
+		"sqlString": sqlString,
+	}).Parse(procedureTemplate)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// statementEnd 匹配行尾的自定义分隔符 //
+var statementEnd = regexp.MustCompile(`(?m)//[ \t]*$`)
+
+// procedureStatements 将面向 mysql 客户端的脚本拆分为可以逐条执行的语句，
+// 去掉 DELIMITER 指令和只包含注释的片段
+func procedureStatements(script string) []string {
+	var lines []string
+	for _, line := range strings.Split(script, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "DELIMITER") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	var statements []string
+	for _, chunk := range statementEnd.Split(strings.Join(lines, "\n"), -1) {
+		chunk = strings.TrimSpace(chunk)
+		if isCommentOnly(chunk) {
+			continue
+		}
+		statements = append(statements, strings.TrimSuffix(chunk, ";"))
+	}
+	return statements
+}
+
+// isCommentOnly 判断片段是否只包含空行和 -- 注释
+func isCommentOnly(chunk string) bool {
+	for _, line := range strings.Split(chunk, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
+
+// installProcedure 渲染并安装存储过程，已存在时会被替换
+func installProcedure(ctx context.Context, db *sql.DB, quota QuotaConfig, location *time.Location) error {
+	script, err := renderProcedure(quota, location, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, statement := range procedureStatements(script) {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("执行 SQL 失败: %w\n%s", err, statement)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderProcedure(t *testing.T) {
+	quota := testQuota
+	quota.PaidTag = "g'cp"
+	quota.DayWindow = Window{Kind: WindowFixed, Length: 24 * time.Hour, Offset: 16*time.Hour + 30*time.Minute}
+	script, err := renderProcedure(quota, testNow.Location(), testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"DECLARE minute_cooldown_seconds INT DEFAULT 300;",
+		"SET minute_ago = now_ts - 60;",
+		"TIMESTAMP(DATE(local_now), '16:30:00')",
+		"INTERVAL (now_ts + 28800) SECOND",
+		"channels.tag != 'g''cp' AND (logs_stats.minute_count >= 5 OR logs_stats.day_count >= 25)",
+		"channels.tag = 'g''cp' AND (logs_stats.minute_count >= 20 OR logs_stats.day_count >= 100)",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("rendered procedure missing %q", want)
+		}
+	}
+	if strings.Contains(script, "{{") {
+		t.Error("rendered procedure still contains template actions")
+	}
+	// 天窗口按 TIMEZONE 对齐，不依赖 MySQL 会话的时区
+	if strings.Contains(script, "NOW()") {
+		t.Error("rendered procedure should not depend on the session time zone")
+	}
+}
+
+// 天窗口按 TIMEZONE 的偏移对齐，与 MySQL 会话的时区无关
+func TestRenderProcedureTimeZone(t *testing.T) {
+	tests := []struct {
+		location *time.Location
+		want     string
+	}{
+		{time.UTC, "INTERVAL (now_ts + 0) SECOND"},
+		{time.FixedZone("CST", 8*3600), "INTERVAL (now_ts + 28800) SECOND"},
+		{time.FixedZone("EST", -5*3600), "INTERVAL (now_ts + -18000) SECOND"},
+	}
+	for _, tt := range tests {
+		script, err := renderProcedure(testQuota, tt.location, testNow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(script, tt.want) {
+			t.Errorf("%s: rendered procedure missing %q", tt.location, tt.want)
+		}
+	}
+}
+
+// 四个限制都是达到即超限，与 planEnforcement 和面板的使用率一致
+func TestRenderProcedureLimitSemantics(t *testing.T) {
+	script, err := renderProcedure(testQuota, testNow.Location(), testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"logs_stats.minute_count >= 5 OR logs_stats.day_count >= 25",
+		"logs_stats.minute_count >= 20 OR logs_stats.day_count >= 100",
+		"channels.tag != 'gcp' AND logs_stats.day_count >= 25",
+		"channels.tag = 'gcp' AND logs_stats.day_count >= 100",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("rendered procedure missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"day_count > ", "minute_count > "} {
+		if strings.Contains(script, unwanted) {
+			t.Errorf("rendered procedure should not compare with %q", unwanted)
+		}
+	}
+}
+
+func TestRenderProcedureRejectsUnsupportedWindows(t *testing.T) {
+	fixedMinute := testQuota
+	fixedMinute.MinuteWindow = Window{Kind: WindowFixed, Length: time.Minute}
+	rollingDay := testQuota
+	rollingDay.DayWindow = Window{Kind: WindowRolling, Length: 24 * time.Hour}
+
+	for name, quota := range map[string]QuotaConfig{"fixed minute": fixedMinute, "rolling day": rollingDay} {
+		if _, err := renderProcedure(quota, testNow.Location(), testNow); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestProcedureStatements(t *testing.T) {
+	script, err := renderProcedure(testQuota, testNow.Location(), testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements := procedureStatements(script)
+	prefixes := []string{"CREATE TABLE IF NOT EXISTS channel_cooldowns", "CREATE TABLE IF NOT EXISTS channel_stats_heartbeat", "DROP PROCEDURE IF EXISTS UpdateChannelStats", "CREATE PROCEDURE UpdateChannelStats()"}
+	if len(statements) != len(prefixes) {
+		t.Fatalf("got %d statements, want %d:\n%s", len(statements), len(prefixes), strings.Join(statements, "\n----\n"))
+	}
+	for i, prefix := range prefixes {
+		// 语句前可能带有说明性注释
+		if !strings.Contains(statements[i], prefix) {
+			t.Errorf("statement %d does not contain %q:\n%s", i, prefix, statements[i])
+		}
+		if strings.Contains(statements[i], "DELIMITER") {
+			t.Errorf("statement %d still contains DELIMITER", i)
+		}
+	}
+	if !strings.HasSuffix(statements[3], "END") {
+		t.Errorf("procedure body should end with END, got %q", statements[3][len(statements[3])-20:])
+	}
+}
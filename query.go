@@ -0,0 +1,356 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 渠道列表的状态和类型筛选
+const (
+	FilterAll         = "all"
+	FilterAvailable   = "available"
+	FilterUnavailable = "unavailable" // 包括冷却中的渠道
+	FilterCooling     = "cooling"
+	FilterPaid        = "paid"
+	FilterNormal      = "normal"
+)
+
+// channelSortKeys 可选的排序字段，default 为面板默认的排序：付费号在前，再按天、分钟使用率
+var channelSortKeys = map[string]func(a, b ChannelView) int{
+	"default":       nil,
+	"id":            func(a, b ChannelView) int { return a.ID - b.ID },
+	"name":          func(a, b ChannelView) int { return strings.Compare(a.Name, b.Name) },
+	"minute":        func(a, b ChannelView) int { return compareFloat(a.MinutePercentage, b.MinutePercentage) },
+	"day":           func(a, b ChannelView) int { return compareFloat(a.DayPercentage, b.DayPercentage) },
+	"error_rate":    func(a, b ChannelView) int { return compareFloat(a.ErrorRate, b.ErrorRate) },
+	"cost":          func(a, b ChannelView) int { return compareFloat(a.TodayCost, b.TodayCost) },
+	"latency":       func(a, b ChannelView) int { return latencyP50(a) - latencyP50(b) },
+	"priority":      func(a, b ChannelView) int { return compareFloat(float64(a.Priority), float64(b.Priority)) },
+	"response_time": func(a, b ChannelView) int { return a.ResponseTime - b.ResponseTime },
+	"weight":        func(a, b ChannelView) int { return a.Weight - b.Weight },
+	"month_cost":    func(a, b ChannelView) int { return compareFloat(a.MonthCost, b.MonthCost) },
+	"created":       func(a, b ChannelView) int { return compareTime(a.CreatedAt, b.CreatedAt) },
+	"tested":        func(a, b ChannelView) int { return compareTime(a.TestedAt, b.TestedAt) },
+}
+
+// compareTime 比较可能为 nil 的时间，nil 排在最前
+func compareTime(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return a.Compare(*b)
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// latencyP50 返回渠道的延迟中位数，没有延迟数据时为 -1
+func latencyP50(view ChannelView) int {
+	if view.Latency == nil {
+		return -1
+	}
+	return view.Latency.P50
+}
+
+// 渠道列表的显示方式
+const (
+	ViewCards = "cards"
+	ViewTable = "table" // 紧凑的表格，便于浏览大量渠道和批量操作
+)
+
+// viewCookie 保存用户上次选择的显示方式
+const viewCookie = "channel_view"
+
+// maxPageSize 每页最多显示的渠道数
+const maxPageSize = 1000
+
+// ChannelQuery 渠道列表的筛选、排序和分页条件，来自 URL 的查询参数，便于分享当前视图
+type ChannelQuery struct {
+	Status     string `json:"status"`
+	Tier       string `json:"tier"`
+	Group      string `json:"group,omitempty"`  // newapi 的分组
+	Search     string `json:"search,omitempty"` // 匹配 ID、名称、模型等
+	Sort       string `json:"sort"`
+	Descending bool   `json:"descending"`
+	Page       int    `json:"page"`      // 从 1 开始
+	PageSize   int    `json:"page_size"` // 为 0 时不分页
+	GroupBy    string `json:"group_by,omitempty"`
+	View       string `json:"view"`
+	Kiosk      bool   `json:"kiosk,omitempty"` // 大屏模式：隐藏控件，放大总览并自动轮播分组或分页
+
+	// 未指定 page_size、group_by 时的取值，生成链接时省略
+	defaultPageSize int
+	defaultGroupBy  string
+}
+
+// parseChannelQuery 从查询参数中读取条件，未指定 page_size 时使用 defaultPageSize，groupBy 为默认的分组方式
+func parseChannelQuery(values url.Values, defaultPageSize int, groupBy string) (ChannelQuery, error) {
+	q := ChannelQuery{
+		Status:   values.Get("status"),
+		Tier:     values.Get("tier"),
+		Group:    strings.TrimSpace(values.Get("group")),
+		Search:   strings.TrimSpace(values.Get("q")),
+		Sort:     values.Get("sort"),
+		View:     values.Get("view"),
+		Page:     1,
+		PageSize: defaultPageSize,
+		GroupBy:  groupBy,
+
+		defaultPageSize: defaultPageSize,
+		defaultGroupBy:  groupBy,
+	}
+	if _, ok := values["group_by"]; ok {
+		q.GroupBy = values.Get("group_by")
+	}
+	switch q.Status {
+	case "":
+		q.Status = FilterAll
+	case FilterAll, FilterAvailable, FilterUnavailable, FilterCooling:
+	default:
+		return ChannelQuery{}, fmt.Errorf("不支持的状态筛选 %q", q.Status)
+	}
+	switch q.Tier {
+	case "":
+		q.Tier = FilterAll
+	case FilterAll, FilterPaid, FilterNormal:
+	default:
+		return ChannelQuery{}, fmt.Errorf("不支持的类型筛选 %q", q.Tier)
+	}
+	switch q.View {
+	case "":
+		q.View = ViewCards
+	case ViewCards, ViewTable:
+	default:
+		return ChannelQuery{}, fmt.Errorf("不支持的显示方式 %q，可选 cards 或 table", q.View)
+	}
+	switch values.Get("kiosk") {
+	case "", "0", "false":
+	case "1", "true":
+		q.Kiosk = true
+	default:
+		return ChannelQuery{}, fmt.Errorf("kiosk 只能是 1 或 0")
+	}
+	if q.Sort == "" {
+		q.Sort = "default"
+	}
+	if _, ok := channelSortKeys[q.Sort]; !ok {
+		return ChannelQuery{}, fmt.Errorf("不支持的排序字段 %q", q.Sort)
+	}
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Descending = true
+	default:
+		return ChannelQuery{}, fmt.Errorf("排序方向只能是 asc 或 desc")
+	}
+	if value := values.Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			return ChannelQuery{}, fmt.Errorf("页码无效: %q", value)
+		}
+		q.Page = page
+	}
+	if value := values.Get("page_size"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 || size > maxPageSize {
+			return ChannelQuery{}, fmt.Errorf("每页数量无效: %q，应在 0 到 %d 之间", value, maxPageSize)
+		}
+		q.PageSize = size
+	}
+	return q, nil
+}
+
+// Matches 判断渠道是否满足筛选条件
+func (q ChannelQuery) Matches(view ChannelView) bool {
+	switch q.Status {
+	case FilterAvailable:
+		if view.Status != StatusAvailable {
+			return false
+		}
+	case FilterUnavailable:
+		if view.Status == StatusAvailable {
+			return false
+		}
+	case FilterCooling:
+		if view.Status != StatusCooling {
+			return false
+		}
+	}
+	if q.Tier != FilterAll && q.Tier != view.Tier {
+		return false
+	}
+	if q.Group != "" && !slices.Contains(view.Groups, q.Group) {
+		return false
+	}
+	return view.MatchesSearch(q.Search)
+}
+
+// Apply 返回满足筛选条件的渠道，按排序条件排好序，不分页
+func (q ChannelQuery) Apply(channels []ChannelView) []ChannelView {
+	var result []ChannelView
+	for _, view := range channels {
+		if q.Matches(view) {
+			result = append(result, view)
+		}
+	}
+	// 采集结果已按默认规则排好序，默认排序只需要处理倒序
+	if compare := channelSortKeys[q.Sort]; compare != nil {
+		sort.SliceStable(result, func(i, j int) bool { return compare(result[i], result[j]) < 0 })
+	}
+	if q.Descending {
+		slices.Reverse(result)
+	}
+	return result
+}
+
+// Values 返回对应的查询参数，省略默认值
+func (q ChannelQuery) Values() url.Values {
+	values := url.Values{}
+	set := func(key, value, defaultValue string) {
+		if value != defaultValue {
+			values.Set(key, value)
+		}
+	}
+	set("status", q.Status, FilterAll)
+	set("tier", q.Tier, FilterAll)
+	set("group", q.Group, "")
+	set("q", q.Search, "")
+	set("sort", q.Sort, "default")
+	set("view", q.View, ViewCards)
+	if q.Descending {
+		values.Set("order", "desc")
+	}
+	if q.Kiosk {
+		values.Set("kiosk", "1")
+	}
+	if q.Page > 1 {
+		values.Set("page", strconv.Itoa(q.Page))
+	}
+	if q.PageSize != q.defaultPageSize {
+		values.Set("page_size", strconv.Itoa(q.PageSize))
+	}
+	if q.GroupBy != q.defaultGroupBy {
+		values.Set("group_by", q.GroupBy)
+	}
+	return values
+}
+
+// HiddenFields 返回搜索表单中需要保留的其他条件
+func (q ChannelQuery) HiddenFields() map[string]string {
+	fields := make(map[string]string)
+	for key, values := range q.Values() {
+		if key != "q" && key != "page" {
+			fields[key] = values[0]
+		}
+	}
+	return fields
+}
+
+// With 返回修改了部分条件后的页面地址，参数为成对的键和值，值为空时删除该参数。
+// 修改筛选或排序时回到第一页，便于在模板中生成链接
+func (q ChannelQuery) With(pairs ...string) string {
+	values := q.Values()
+	values.Del("page")
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			values.Del(pairs[i])
+		} else {
+			values.Set(pairs[i], pairs[i+1])
+		}
+	}
+	if values.Get("page") == "1" {
+		values.Del("page")
+	}
+	if len(values) == 0 {
+		return "?"
+	}
+	return "?" + values.Encode()
+}
+
+// PageURL 返回第 page 页的地址
+func (q ChannelQuery) PageURL(page int) string {
+	return q.With("page", strconv.Itoa(page))
+}
+
+// SortURL 返回按 key 排序的地址，已经按 key 升序时切换为降序
+func (q ChannelQuery) SortURL(key string) string {
+	if q.Sort == key && !q.Descending {
+		return q.With("sort", key, "order", "desc")
+	}
+	return q.With("sort", key, "order", "")
+}
+
+// SortIndicator 返回表头上表示当前排序的箭头，不是按 key 排序时为空
+func (q ChannelQuery) SortIndicator(key string) string {
+	switch {
+	case q.Sort != key:
+		return ""
+	case q.Descending:
+		return "↓"
+	}
+	return "↑"
+}
+
+// PageInfo 分页信息
+type PageInfo struct {
+	Page     int `json:"page"`
+	PageSize int `json:"page_size"`
+	Total    int `json:"total"` // 满足筛选条件的渠道数
+	Pages    int `json:"pages"`
+}
+
+// HasPrev 判断是否有上一页
+func (p PageInfo) HasPrev() bool { return p.Page > 1 }
+
+// HasNext 判断是否有下一页
+func (p PageInfo) HasNext() bool { return p.Page < p.Pages }
+
+// Queried 返回按 q 筛选、排序、分页并分组后的面板数据副本，不修改原数据。
+// 总使用情况仍然是全部渠道的汇总，分组的小计只包含当前页的渠道
+func (s *Snapshot) Queried(q ChannelQuery) (*Snapshot, error) {
+	channels := q.Apply(s.Channels)
+	page := PageInfo{Page: 1, PageSize: q.PageSize, Total: len(channels), Pages: 1}
+	if q.PageSize > 0 {
+		page.Pages = max(1, (len(channels)+q.PageSize-1)/q.PageSize)
+		page.Page = min(q.Page, page.Pages)
+		start := (page.Page - 1) * q.PageSize
+		channels = channels[start:min(start+q.PageSize, len(channels))]
+	}
+	q.Page = page.Page
+
+	filtered := *s
+	filtered.Channels = channels
+	result, err := filtered.GroupedBy(q.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	result.Query = &q
+	result.Page = &page
+	result.AvailableGroups = nil
+	for _, view := range s.Channels {
+		for _, group := range view.Groups {
+			if !slices.Contains(result.AvailableGroups, group) {
+				result.AvailableGroups = append(result.AvailableGroups, group)
+			}
+		}
+	}
+	sort.Strings(result.AvailableGroups)
+	return result, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseChannelQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    ChannelQuery
+		wantErr bool
+	}{
+		{"", ChannelQuery{Status: FilterAll, Tier: FilterAll, Sort: "default", Page: 1, PageSize: 50, View: ViewCards}, false},
+		{"status=cooling&tier=paid&group=vip&q=+acct+&sort=day&order=desc&page=3&page_size=10&group_by=&view=table&kiosk=1",
+			ChannelQuery{Status: FilterCooling, Tier: FilterPaid, Group: "vip", Search: "acct", Sort: "day", Descending: true, Page: 3, PageSize: 10, View: ViewTable, Kiosk: true}, false},
+		{"status=broken", ChannelQuery{}, true},
+		{"tier=vip", ChannelQuery{}, true},
+		{"sort=key", ChannelQuery{}, true},
+		{"order=up", ChannelQuery{}, true},
+		{"view=list", ChannelQuery{}, true},
+		{"kiosk=yes", ChannelQuery{}, true},
+		{"page=0", ChannelQuery{}, true},
+		{"page_size=5000", ChannelQuery{}, true},
+	}
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		got, err := parseChannelQuery(values, 50, GroupByTier)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error = %v", tt.query, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		// 没有 group_by 参数时使用默认的分组方式
+		if _, ok := values["group_by"]; !ok {
+			tt.want.GroupBy = GroupByTier
+		}
+		tt.want.defaultPageSize, tt.want.defaultGroupBy = 50, GroupByTier
+		if got != tt.want {
+			t.Errorf("%q:\n got %+v\nwant %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSnapshotQueried(t *testing.T) {
+	snapshot := goldenSnapshot() // 默认顺序：1（付费号）、2、4、3
+	tests := []struct {
+		query string
+		ids   []int
+		page  PageInfo
+	}{
+		{"", []int{1, 2, 4, 3}, PageInfo{Page: 1, PageSize: 100, Total: 4, Pages: 1}},
+		{"order=desc", []int{3, 4, 2, 1}, PageInfo{Page: 1, PageSize: 100, Total: 4, Pages: 1}},
+		{"status=unavailable", []int{4, 3}, PageInfo{Page: 1, PageSize: 100, Total: 2, Pages: 1}},
+		{"status=cooling&tier=normal", []int{4}, PageInfo{Page: 1, PageSize: 100, Total: 1, Pages: 1}},
+		{"tier=paid", []int{1}, PageInfo{Page: 1, PageSize: 100, Total: 1, Pages: 1}},
+		{"group=vip", []int{1}, PageInfo{Page: 1, PageSize: 100, Total: 1, Pages: 1}},
+		{"q=ACCOUNT", []int{1}, PageInfo{Page: 1, PageSize: 100, Total: 1, Pages: 1}},
+		{"sort=id", []int{1, 2, 3, 4}, PageInfo{Page: 1, PageSize: 100, Total: 4, Pages: 1}},
+		{"sort=minute&order=desc", []int{4, 1, 2, 3}, PageInfo{Page: 1, PageSize: 100, Total: 4, Pages: 1}},
+		{"sort=id&page_size=3&page=2", []int{4}, PageInfo{Page: 2, PageSize: 3, Total: 4, Pages: 2}},
+		// 页码超出范围时显示最后一页
+		{"sort=id&page_size=3&page=9", []int{4}, PageInfo{Page: 2, PageSize: 3, Total: 4, Pages: 2}},
+		{"page_size=0", []int{1, 2, 4, 3}, PageInfo{Page: 1, PageSize: 0, Total: 4, Pages: 1}},
+		{"status=available&tier=normal&q=nothing", nil, PageInfo{Page: 1, PageSize: 100, Total: 0, Pages: 1}},
+	}
+	for _, tt := range tests {
+		result := queried(t, snapshot, tt.query)
+		var ids []int
+		for _, view := range result.Channels {
+			ids = append(ids, view.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.ids) || *result.Page != tt.page {
+			t.Errorf("%q: ids = %v, page = %+v, want %v, %+v", tt.query, ids, *result.Page, tt.ids, tt.page)
+		}
+		if result.Summary != snapshot.Summary {
+			t.Errorf("%q: 总使用情况应包含全部渠道", tt.query)
+		}
+	}
+	if len(snapshot.Channels) != 4 {
+		t.Error("Queried 不应修改原数据")
+	}
+}
+
+func TestChannelQueryURLs(t *testing.T) {
+	values, _ := url.ParseQuery("status=available&q=gcp&sort=day&order=desc&page=2&page_size=20")
+	q, err := parseChannelQuery(values, 100, GroupByNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 修改条件时回到第一页，保留其他条件
+	if got, want := q.With("tier", "paid"), "?order=desc&page_size=20&q=gcp&sort=day&status=available&tier=paid"; got != want {
+		t.Errorf("With() = %q, want %q", got, want)
+	}
+	if got, want := q.With("status", "", "q", ""), "?order=desc&page_size=20&sort=day"; got != want {
+		t.Errorf("With() = %q, want %q", got, want)
+	}
+	if got, want := q.PageURL(3), "?order=desc&page=3&page_size=20&q=gcp&sort=day&status=available"; got != want {
+		t.Errorf("PageURL(3) = %q, want %q", got, want)
+	}
+	if got := q.PageURL(1); got != "?order=desc&page_size=20&q=gcp&sort=day&status=available" {
+		t.Errorf("PageURL(1) = %q", got)
+	}
+	// 再次点击当前的排序字段时切换方向
+	if got, want := q.SortURL("day"), "?page_size=20&q=gcp&sort=day&status=available"; got != want {
+		t.Errorf("SortURL(day) = %q, want %q", got, want)
+	}
+	if got, want := q.SortURL("id"), "?page_size=20&q=gcp&sort=id&status=available"; got != want {
+		t.Errorf("SortURL(id) = %q, want %q", got, want)
+	}
+	if q.SortIndicator("day") != "↓" || q.SortIndicator("id") != "" {
+		t.Errorf("SortIndicator() = %q, %q", q.SortIndicator("day"), q.SortIndicator("id"))
+	}
+	fields := q.HiddenFields()
+	if _, ok := fields["q"]; ok || fields["sort"] != "day" || fields["page_size"] != "20" {
+		t.Errorf("HiddenFields() = %v", fields)
+	}
+}
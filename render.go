@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+)
+
+// webFS 内嵌的页面模板和静态资源，目录结构为 templates/*.html 和 static/*
+//
+//go:embed web
+var webFS embed.FS
+
+// overlayFS 优先从 upper 读取文件，不存在时回退到 lower，用于让用户目录覆盖内嵌资源
+type overlayFS struct {
+	upper fs.FS
+	lower fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.upper.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.lower.Open(name)
+}
+
+// Renderer 负责解析和渲染页面模板，以及提供静态资源
+type Renderer struct {
+	fsys   fs.FS   // 合并后的资源
+	layers []fs.FS // 参与合并的各层资源，用于列出全部模板文件
+	dev    bool
+	funcs  template.FuncMap
+	tmpl   map[string]*template.Template // 各语言的模板
+	ui     UIConfig
+}
+
+// NewRenderer 创建渲染器。
+// dev 为 true 时从磁盘上的 web 目录读取资源并在每次请求时重新解析模板，便于修改后直接刷新；
+// overrideDir 不为空时，该目录下 templates/、static/ 中的同名文件会覆盖默认资源。
+func NewRenderer(dev bool, overrideDir string) (*Renderer, error) {
+	var base fs.FS
+	if dev {
+		base = os.DirFS("web")
+	} else {
+		sub, err := fs.Sub(webFS, "web")
+		if err != nil {
+			return nil, err
+		}
+		base = sub
+	}
+	r := &Renderer{
+		fsys:   base,
+		layers: []fs.FS{base},
+		dev:    dev,
+		ui:     defaultUIConfig(),
+	}
+	r.funcs = template.FuncMap{
+		"formatRemaining": formatRemaining,
+		"langURL":         langURL,
+		"inc":             func(i int) int { return i + 1 },
+		"dec":             func(i int) int { return i - 1 },
+		// 以下函数在 localize 中按语言替换
+		"t":    func(source interface{}, args ...interface{}) string { return translateValue(LangZH, source, args...) },
+		"lang": func() string { return LangZH },
+		// 外观配置在渲染时读取，SetUI 之后立即生效
+		"ui":    func() UIConfig { return r.ui },
+		"level": func(percentage float64) string { return r.ui.Thresholds.Level(percentage) },
+	}
+	if overrideDir != "" {
+		upper := os.DirFS(overrideDir)
+		r.fsys = overlayFS{upper: upper, lower: base}
+		r.layers = append(r.layers, upper)
+	}
+
+	tmpl, err := r.parse()
+	if err != nil {
+		return nil, err
+	}
+	if r.tmpl, err = localize(tmpl); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// localize 为每种语言复制一份模板，t、lang 使用对应的语言
+func localize(tmpl *template.Template) (map[string]*template.Template, error) {
+	result := make(map[string]*template.Template)
+	for _, lang := range []string{LangZH, LangEN} {
+		clone, err := tmpl.Clone()
+		if err != nil {
+			return nil, err
+		}
+		lang := lang
+		result[lang] = clone.Funcs(template.FuncMap{
+			"t":    func(source interface{}, args ...interface{}) string { return translateValue(lang, source, args...) },
+			"lang": func() string { return lang },
+		})
+	}
+	return result, nil
+}
+
+// SetUI 设置主题、使用率颜色阈值等外观配置，需在开始处理请求前调用
+func (r *Renderer) SetUI(ui UIConfig) {
+	r.ui = ui
+}
+
+// parse 解析 templates 目录下的所有模板。
+// 覆盖目录中可能新增默认资源里没有的模板，因此需要合并各层的文件名
+func (r *Renderer) parse() (*template.Template, error) {
+	var names []string
+	for _, layer := range r.layers {
+		matches, err := fs.Glob(layer, "templates/*.html")
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, matches...)
+	}
+
+	tmpl := template.New("").Funcs(r.funcs)
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		content, err := fs.ReadFile(r.fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tmpl.New(path.Base(name)).Parse(string(content)); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
+
+// Render 使用名为 name 的模板（如 index.html）以中文渲染 data，用于邮件等没有请求的场景
+func (r *Renderer) Render(w io.Writer, name string, data interface{}) error {
+	return r.RenderLang(w, LangZH, name, data)
+}
+
+// RenderLang 以 lang 语言渲染 data。
+// 先渲染到缓冲区，避免模板执行出错时向客户端输出半个页面
+func (r *Renderer) RenderLang(w io.Writer, lang, name string, data interface{}) error {
+	templates := r.tmpl
+	if r.dev {
+		tmpl, err := r.parse()
+		if err != nil {
+			return err
+		}
+		if templates, err = localize(tmpl); err != nil {
+			return err
+		}
+	}
+	tmpl, ok := templates[lang]
+	if !ok {
+		tmpl = templates[LangZH]
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// StaticHandler 返回提供 static 目录下静态资源的处理器，需挂载在 /static/ 下
+func (r *Renderer) StaticHandler() http.Handler {
+	static, err := fs.Sub(r.fsys, "static")
+	if err != nil {
+		// fs.Sub 只会因路径不合法而失败，"static" 是固定的合法路径
+		panic(err)
+	}
+	return http.StripPrefix("/static/", http.FileServer(http.FS(static)))
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "更新 testdata 下的 golden 文件")
+
+// checkGolden 将 got 与 testdata/name 比较，使用 -update 时改为写入
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取 golden 文件失败（可使用 go test -update 生成）: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s 与渲染结果不一致，确认改动符合预期后使用 go test -update 更新", path)
+	}
+}
+
+// goldenSnapshot 覆盖可用、禁用、冷却中、付费/普号以及请求趋势、请求量异常等展示分支
+func goldenSnapshot() *Snapshot {
+	minuteReason, minuteUntil := cooldown("minute", testNow.Add(125*time.Second))
+	channels := []Channel{
+		{ID: 1, Status: "1", Tag: "gcp", Name: "gcp-account-1", Group: "vip", Type: 41, Models: "gemini-2.5-pro,gemini-2.5-flash",
+			Priority: 10, Weight: 5, CreatedTime: testNow.Add(-30 * 24 * time.Hour).Unix(), TestTime: testNow.Add(-time.Hour).Unix(), ResponseTime: 1200},
+		{ID: 2, Status: "1"},
+		{ID: 3, Status: "2"},
+		{ID: 4, Status: "2", CooldownReason: minuteReason, DisabledUntil: minuteUntil},
+	}
+	usage := map[int]UsageCounts{
+		1: {Minute: 12, Day: 90},
+		2: {Minute: 1, Day: 5},
+		3: {Minute: 0, Day: 25},
+		4: {Minute: 5, Day: 8},
+	}
+	snapshot := buildSnapshot(channels, usage, testQuota, testNow)
+	// 渠道 1 最近几分钟请求增多，渠道 3 在半小时前有请求
+	since := testNow.Add(-trendSpan)
+	minute := int64(trendBucket / time.Second)
+	activity := []ActivityCounts{
+		{ChannelID: 1, Bucket: since.Unix() + 50*minute, Requests: 2},
+		{ChannelID: 1, Bucket: since.Unix() + 57*minute, Requests: 4},
+		{ChannelID: 1, Bucket: since.Unix() + 59*minute, Requests: 6},
+		{ChannelID: 3, Bucket: since.Unix() + 30*minute, Requests: 5},
+	}
+	applyTrends(snapshot, activity, since)
+	applyAnomalies(snapshot, &AnomalyReport{Anomalies: []Anomaly{
+		{ChannelID: 0, Kind: AnomalyDrop, Scope: AnomalyScopeHour, Current: 12, Expected: 240},
+		{ChannelID: 1, Kind: AnomalySpike, Scope: AnomalyScopeMinute, Current: 12, Expected: 2.5},
+	}})
+	return snapshot
+}
+
+// queried 与页面处理函数相同，按查询参数 rawQuery 筛选、排序和分页
+func queried(t *testing.T, snapshot *Snapshot, rawQuery string) *Snapshot {
+	t.Helper()
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, err := parseChannelQuery(values, 100, GroupByNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := snapshot.Queried(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestRenderIndexGolden(t *testing.T) {
+	renderer, err := NewRenderer(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		lang   string
+		query  string
+		golden string
+	}{
+		{LangZH, "", "index.golden.html"},
+		{LangZH, "view=table&sort=day&order=desc", "index_table.golden.html"},
+		{LangEN, "group_by=tier", "index_en.golden.html"},
+		{LangZH, "kiosk=1&group_by=tier", "index_kiosk.golden.html"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := renderer.RenderLang(&buf, tt.lang, "index.html", queried(t, goldenSnapshot(), tt.query)); err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, tt.golden, buf.Bytes())
+	}
+}
+
+// TestRenderThresholds 检查进度条颜色使用配置的阈值：渠道 1 今日使用率 90%
+func TestRenderThresholds(t *testing.T) {
+	renderer, err := NewRenderer(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		thresholds UsageThresholds
+		want       string
+	}{
+		{UsageThresholds{Warn: 50, Critical: 80}, `progress-bar level-critical" style="width: 90.0%;"`},
+		{UsageThresholds{Warn: 80, Critical: 95}, `progress-bar level-warn" style="width: 90.0%;"`},
+		{UsageThresholds{Warn: 95, Critical: 99}, `progress-bar level-ok" style="width: 90.0%;"`},
+	}
+	for _, tt := range tests {
+		ui := defaultUIConfig()
+		ui.Thresholds = tt.thresholds
+		renderer.SetUI(ui)
+		var buf bytes.Buffer
+		if err := renderer.RenderLang(&buf, LangZH, "index.html", queried(t, goldenSnapshot(), "")); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte(tt.want)) {
+			t.Errorf("阈值 %+v: 页面中没有 %s", tt.thresholds, tt.want)
+		}
+	}
+}
+
+func TestRenderCounterBanner(t *testing.T) {
+	renderer, err := NewRenderer(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := testNow.Add(-12 * time.Minute)
+	tests := []struct {
+		name     string
+		lang     string
+		counters *CounterStatus
+		want     string
+	}{
+		{"不检查", LangZH, nil, ""},
+		{"正常", LangZH, &CounterStatus{CheckedAt: testNow, UpdatedAt: &updated}, ""},
+		{"停止更新", LangZH, &CounterStatus{CheckedAt: testNow, UpdatedAt: &updated, Stale: true}, "最近一次更新于 12分0秒 前"},
+		{"停止更新（英文）", LangEN, &CounterStatus{CheckedAt: testNow, UpdatedAt: &updated, Stale: true}, "last updated 12m 0s ago"},
+		{"更新时间未知", LangZH, &CounterStatus{CheckedAt: testNow, Stale: true}, "最近一次更新时间未知"},
+		{"更新时间未知（英文）", LangEN, &CounterStatus{CheckedAt: testNow, Stale: true}, "last update time unknown"},
+		{"不一致", LangZH, &CounterStatus{CheckedAt: testNow, UpdatedAt: &updated, Drift: []CounterDrift{{ChannelID: 1}, {ChannelID: 2}}}, "2 个渠道的计数与日志统计不一致"},
+	}
+	for _, tt := range tests {
+		snapshot := goldenSnapshot()
+		snapshot.Counters = tt.counters
+		var buf bytes.Buffer
+		if err := renderer.RenderLang(&buf, tt.lang, "index.html", queried(t, snapshot, "")); err != nil {
+			t.Fatal(err)
+		}
+		page := buf.String()
+		if shown := strings.Contains(page, `class="counter-banner`); shown != (tt.want != "") {
+			t.Errorf("%s: shown = %v", tt.name, shown)
+		}
+		if tt.want != "" && !strings.Contains(page, tt.want) {
+			t.Errorf("%s: 页面中没有 %s", tt.name, tt.want)
+		}
+	}
+}
+
+func TestRendererOverrideDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	custom := `<h1>{{len .Channels}} channels</h1>`
+	if err := os.WriteFile(filepath.Join(dir, "templates", "index.html"), []byte(custom), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	renderer, err := NewRenderer(false, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, "index.html", goldenSnapshot()); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "<h1>4 channels</h1>" {
+		t.Errorf("override template rendered %q", got)
+	}
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ReportConfig 日报的存储和发送配置
+type ReportConfig struct {
+	Dir        string   // 保存日报的目录
+	EmailTo    []string // 为空时不发送邮件
+	WebhookURL string   // 为空时不推送 webhook
+}
+
+// ChannelDayReport 一个渠道在日报窗口内的情况
+type ChannelDayReport struct {
+	ChannelID          int        `json:"channel_id"`
+	IsPaid             bool       `json:"is_paid"`
+	Requests           int        `json:"requests"`
+	Errors             int        `json:"errors"`
+	ErrorRate          float64    `json:"error_rate"` // 百分比
+	PeakMinute         int        `json:"peak_minute"`
+	MinuteLimitHits    int        `json:"minute_limit_hits"` // 触发分钟冷却的次数
+	FirstMinuteLimitAt *time.Time `json:"first_minute_limit_at,omitempty"`
+	DayLimitAt         *time.Time `json:"day_limit_at,omitempty"` // 达到天限制的时间，未达到时为 nil
+}
+
+// DailyReport 一个已结束的天窗口的日报
+type DailyReport struct {
+	ID                string    `json:"id"`
+	Start             time.Time `json:"start"`
+	End               time.Time `json:"end"`
+	MinuteWindowLabel Message   `json:"minute_window_label"`
+	// MinuteWindowLength 读取日报时据此重建按语言翻译的 MinuteWindowLabel，旧日报没有该字段时显示保存的文本
+	MinuteWindowLength time.Duration `json:"minute_window_length,omitempty"`
+
+	TotalRequests    int       `json:"total_requests"`
+	TotalErrors      int       `json:"total_errors"`
+	ErrorRate        float64   `json:"error_rate"` // 百分比
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	PeakMinute       int       `json:"peak_minute"`
+	PeakMinuteAt     time.Time `json:"peak_minute_at"`
+
+	TotalChannels  int `json:"total_channels"`
+	ActiveChannels int `json:"active_channels"` // 有请求的渠道数
+	DisableCount   int `json:"disable_count"`   // 推算的自动禁用次数：每次分钟冷却和达到天限制各计一次
+
+	LimitChannels []ChannelDayReport `json:"limit_channels"` // 触发过限制的渠道
+	ErrorChannels []ChannelDayReport `json:"error_channels"` // 错误率最高的渠道
+	TopUsers      []Consumer         `json:"top_users"`
+	TopTokens     []Consumer         `json:"top_tokens"`
+
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// TotalTokens 返回输入和输出 token 之和
+func (r *DailyReport) TotalTokens() int64 {
+	return r.PromptTokens + r.CompletionTokens
+}
+
+// Title 返回日报标题，用作邮件主题
+func (r *DailyReport) Title() string {
+	return fmt.Sprintf("Gemini 渠道日报 %s 至 %s", r.Start.Format("2006-01-02 15:04"), r.End.Format("2006-01-02 15:04"))
+}
+
+// 日报中错误渠道和消耗排行保留的条数
+const reportTopN = 10
+
+// reportID 返回日报的 ID：天窗口为整天时使用开始日期，否则再加上开始时刻
+func reportID(start time.Time, length time.Duration) string {
+	if length%(24*time.Hour) == 0 {
+		return start.Format("2006-01-02")
+	}
+	return start.Format("2006-01-02-1504")
+}
+
+var reportIDPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(-\d{4})?$`)
+
+// reportWindow 返回 now 之前最近一个已结束的天窗口的起点，以及天窗口
+func reportWindow(quota QuotaConfig, now time.Time) (time.Time, Window) {
+	window := demandDayWindow(quota.DayWindow)
+	return window.Start(now).Add(-window.Length), window
+}
+
+// buildDailyReport 根据 [start, end) 内按分钟窗口分桶的请求数生成日报。
+// 分钟限制按固定的分钟窗口判断，滚动窗口下会略微低估超限次数；
+// 超限后 MinuteCooldown 内的请求不再重复计数，达到天限制后不再统计分钟超限
+func buildDailyReport(start, end time.Time, activity []ActivityCounts, tokens []TokenCounts, consumers []ConsumerCounts,
+	channels []Channel, quota QuotaConfig, now time.Time) *DailyReport {
+	report := &DailyReport{
+		ID:                 reportID(start, end.Sub(start)),
+		Start:              start,
+		End:                end,
+		MinuteWindowLabel:  formatWindowLength(quota.MinuteWindow.Length),
+		MinuteWindowLength: quota.MinuteWindow.Length,
+		TotalChannels:      len(channels),
+		GeneratedAt:        now,
+	}
+	for _, t := range tokens {
+		report.PromptTokens += t.PromptTokens
+		report.CompletionTokens += t.CompletionTokens
+	}
+
+	known := make(map[int]Channel, len(channels))
+	for _, channel := range channels {
+		known[channel.ID] = channel
+	}
+	sort.Slice(activity, func(i, j int) bool {
+		if activity[i].ChannelID != activity[j].ChannelID {
+			return activity[i].ChannelID < activity[j].ChannelID
+		}
+		return activity[i].Bucket < activity[j].Bucket
+	})
+
+	pool := make(map[int64]int)
+	var reports []ChannelDayReport
+	for i := 0; i < len(activity); {
+		id := activity[i].ChannelID
+		channel, ok := known[id]
+		limits, paid := quota.LimitsFor(channel)
+		r := ChannelDayReport{ChannelID: id, IsPaid: paid}
+		var cooldownUntil time.Time
+		for ; i < len(activity) && activity[i].ChannelID == id; i++ {
+			c := activity[i]
+			at := time.Unix(c.Bucket, 0).In(start.Location())
+			pool[c.Bucket] += c.Requests
+			r.Requests += c.Requests
+			r.Errors += c.Errors
+			r.PeakMinute = max(r.PeakMinute, c.Requests)
+			// 已删除的渠道不知道属于哪一类，不判断限制
+			if !ok || r.DayLimitAt != nil {
+				continue
+			}
+			if c.Requests >= limits.Minute && !at.Before(cooldownUntil) {
+				r.MinuteLimitHits++
+				if r.FirstMinuteLimitAt == nil {
+					r.FirstMinuteLimitAt = &at
+				}
+				cooldownUntil = at.Add(quota.MinuteCooldown)
+			}
+			if r.Requests >= limits.Day {
+				r.DayLimitAt = &at
+			}
+		}
+		if r.Requests > 0 {
+			r.ErrorRate = float64(r.Errors) / float64(r.Requests) * 100
+		}
+		report.TotalRequests += r.Requests
+		report.TotalErrors += r.Errors
+		report.ActiveChannels++
+		report.DisableCount += r.MinuteLimitHits
+		if r.DayLimitAt != nil {
+			report.DisableCount++
+		}
+		reports = append(reports, r)
+	}
+	if report.TotalRequests > 0 {
+		report.ErrorRate = float64(report.TotalErrors) / float64(report.TotalRequests) * 100
+	}
+	for bucket, count := range pool {
+		if count > report.PeakMinute || count == report.PeakMinute && bucket < report.PeakMinuteAt.Unix() {
+			report.PeakMinute = count
+			report.PeakMinuteAt = time.Unix(bucket, 0).In(start.Location())
+		}
+	}
+
+	// 触发限制的渠道按第一次触发限制的时间排序
+	for _, r := range reports {
+		if r.DayLimitAt != nil || r.MinuteLimitHits > 0 {
+			report.LimitChannels = append(report.LimitChannels, r)
+		}
+	}
+	firstLimit := func(r ChannelDayReport) time.Time {
+		if r.FirstMinuteLimitAt != nil && (r.DayLimitAt == nil || r.FirstMinuteLimitAt.Before(*r.DayLimitAt)) {
+			return *r.FirstMinuteLimitAt
+		}
+		return *r.DayLimitAt
+	}
+	sort.SliceStable(report.LimitChannels, func(i, j int) bool {
+		return firstLimit(report.LimitChannels[i]).Before(firstLimit(report.LimitChannels[j]))
+	})
+
+	for _, r := range reports {
+		if r.Errors > 0 {
+			report.ErrorChannels = append(report.ErrorChannels, r)
+		}
+	}
+	sort.SliceStable(report.ErrorChannels, func(i, j int) bool {
+		a, b := report.ErrorChannels[i], report.ErrorChannels[j]
+		if a.ErrorRate != b.ErrorRate {
+			return a.ErrorRate > b.ErrorRate
+		}
+		return a.Errors > b.Errors
+	})
+	if len(report.ErrorChannels) > reportTopN {
+		report.ErrorChannels = report.ErrorChannels[:reportTopN]
+	}
+
+	limit := quotaLimit(channels, quota)
+	top := func(consumers []Consumer) []Consumer {
+		return consumers[:min(len(consumers), reportTopN)]
+	}
+	report.TopUsers = top(rankConsumers(consumers, report.TotalRequests, limit, false))
+	report.TopTokens = top(rankConsumers(consumers, report.TotalRequests, limit, true))
+	return report
+}
+
+// markdownTemplate 日报的 Markdown 模板
+//
+//go:embed daily_report.md.tmpl
+var markdownTemplate string
+
+var reportMarkdown = template.Must(template.New("daily_report.md").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(markdownTemplate))
+
+// renderMarkdown 把日报渲染为 Markdown
+func renderMarkdown(report *DailyReport) (string, error) {
+	var buf bytes.Buffer
+	if err := reportMarkdown.Execute(&buf, report); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ReportArchive 以 JSON 文件的形式把日报保存在目录中
+type ReportArchive struct {
+	dir string
+}
+
+// NewReportArchive 创建日报存档，目录在第一次保存时创建
+func NewReportArchive(dir string) *ReportArchive {
+	return &ReportArchive{dir: dir}
+}
+
+func (a *ReportArchive) path(id string) string {
+	return filepath.Join(a.dir, id+".json")
+}
+
+// Save 保存日报，已存在时覆盖
+func (a *ReportArchive) Save(report *DailyReport) error {
+	if err := os.MkdirAll(a.dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免读到写了一半的日报
+	tmp := a.path(report.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.path(report.ID))
+}
+
+// Load 读取日报，不存在或 ID 不合法时返回 fs.ErrNotExist
+func (a *ReportArchive) Load(id string) (*DailyReport, error) {
+	if !reportIDPattern.MatchString(id) {
+		return nil, fs.ErrNotExist
+	}
+	data, err := os.ReadFile(a.path(id))
+	if err != nil {
+		return nil, err
+	}
+	var report DailyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("日报 %s 已损坏: %w", id, err)
+	}
+	if report.MinuteWindowLength > 0 {
+		report.MinuteWindowLabel = formatWindowLength(report.MinuteWindowLength)
+	}
+	return &report, nil
+}
+
+// List 返回所有日报，最新的在前
+func (a *ReportArchive) List() ([]*DailyReport, error) {
+	entries, err := os.ReadDir(a.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var reports []*DailyReport
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !reportIDPattern.MatchString(id) {
+			continue
+		}
+		report, err := a.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Start.After(reports[j].Start) })
+	return reports, nil
+}
+
+// 天窗口重置后等待一会儿再生成日报，让最后几条日志写入；生成失败后的重试间隔
+const (
+	reportDelay         = time.Minute
+	reportRetryInterval = 5 * time.Minute
+)
+
+// Reporter 在每次天窗口重置后生成上一个窗口的日报，保存并发送
+type Reporter struct {
+	store    Store
+	quota    QuotaConfig
+	location *time.Location
+	config   ReportConfig
+	archive  *ReportArchive
+	renderer *Renderer
+	mailer   *Mailer // 为 nil 时不发送邮件
+	client   *http.Client
+}
+
+// NewReporter 创建日报生成器，mailer 为 nil 时不发送邮件
+func NewReporter(store Store, quota QuotaConfig, location *time.Location, config ReportConfig,
+	archive *ReportArchive, renderer *Renderer, mailer *Mailer) *Reporter {
+	return &Reporter{
+		store:    store,
+		quota:    quota,
+		location: location,
+		config:   config,
+		archive:  archive,
+		renderer: renderer,
+		mailer:   mailer,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Generate 生成从 start 开始的天窗口的日报
+func (r *Reporter) Generate(ctx context.Context, start time.Time) (*DailyReport, error) {
+	window := demandDayWindow(r.quota.DayWindow)
+	end := start.Add(window.Length)
+	channels, err := r.store.Channels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询渠道失败: %w", err)
+	}
+	activity, err := r.store.Activity(ctx, start, end, demandBucket(r.quota.MinuteWindow))
+	if err != nil {
+		return nil, fmt.Errorf("统计请求失败: %w", err)
+	}
+	tokens, err := r.store.Tokens(ctx, start, end, window.Length)
+	if err != nil {
+		return nil, fmt.Errorf("统计 token 失败: %w", err)
+	}
+	// Consumers 的结束时间是闭区间
+	consumers, err := r.store.Consumers(ctx, start, end.Add(-time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("统计消耗排行失败: %w", err)
+	}
+	return buildDailyReport(start, end, activity, tokens, consumers, channels, r.quota, time.Now().In(r.location)), nil
+}
+
+// RunOnce 生成、保存并发送从 start 开始的天窗口的日报，发送失败只记录日志
+func (r *Reporter) RunOnce(ctx context.Context, start time.Time) (*DailyReport, error) {
+	report, err := r.Generate(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.archive.Save(report); err != nil {
+		return nil, fmt.Errorf("保存日报失败: %w", err)
+	}
+	slog.InfoContext(ctx, "日报已生成", "report", report.ID, "requests", report.TotalRequests, "disabled", report.DisableCount)
+	r.Deliver(ctx, report)
+	return report, nil
+}
+
+// reportWebhookPayload 推送到 webhook 的日报
+type reportWebhookPayload struct {
+	ID       string       `json:"id"`
+	Title    string       `json:"title"`
+	Markdown string       `json:"markdown"`
+	Report   *DailyReport `json:"report"`
+}
+
+// Deliver 按配置通过邮件和 webhook 发送日报
+func (r *Reporter) Deliver(ctx context.Context, report *DailyReport) {
+	markdown, err := renderMarkdown(report)
+	if err != nil {
+		slog.ErrorContext(ctx, "渲染日报失败", "report", report.ID, "err", err)
+		return
+	}
+	if r.mailer != nil && len(r.config.EmailTo) > 0 {
+		var html bytes.Buffer
+		if err := r.renderer.Render(&html, "report_email.html", report); err != nil {
+			slog.ErrorContext(ctx, "渲染日报失败", "report", report.ID, "err", err)
+		} else if err := r.mailer.Send(ctx, r.config.EmailTo, report.Title(), markdown, html.String()); err != nil {
+			slog.ErrorContext(ctx, "发送日报邮件失败", "report", report.ID, "err", err)
+		}
+	}
+	if r.config.WebhookURL != "" {
+		payload := reportWebhookPayload{ID: report.ID, Title: report.Title(), Markdown: markdown, Report: report}
+		if err := r.postWebhook(ctx, payload); err != nil {
+			slog.ErrorContext(ctx, "推送日报失败", "report", report.ID, "err", err)
+		}
+	}
+}
+
+func (r *Reporter) postWebhook(ctx context.Context, payload reportWebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook 返回 %s", resp.Status)
+	}
+	return nil
+}
+
+// Run 在每次天窗口重置后生成上一个窗口的日报，直到 ctx 被取消。
+// 启动时如果上一个窗口的日报还不存在，会立即补生成
+func (r *Reporter) Run(ctx context.Context) {
+	for {
+		// 重置后的 reportDelay 内仍视为上一个窗口未结束
+		now := time.Now().In(r.location)
+		start, window := reportWindow(r.quota, now.Add(-reportDelay))
+		wait := start.Add(2*window.Length + reportDelay).Sub(now)
+		if _, err := r.archive.Load(reportID(start, window.Length)); errors.Is(err, fs.ErrNotExist) {
+			if _, err := r.RunOnce(ctx, start); err != nil {
+				slog.ErrorContext(ctx, "生成日报失败", "err", err)
+				wait = min(wait, reportRetryInterval)
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
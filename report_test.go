@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		window Window
+		now    time.Time
+		start  time.Time
+		id     string
+	}{
+		{"重置之后", testQuota.DayWindow, time.Date(2024, 1, 2, 8, 0, 30, 0, time.Local),
+			time.Date(2024, 1, 1, 8, 0, 0, 0, time.Local), "2024-01-01"},
+		{"重置之前", testQuota.DayWindow, time.Date(2024, 1, 2, 7, 59, 0, 0, time.Local),
+			time.Date(2023, 12, 31, 8, 0, 0, 0, time.Local), "2023-12-31"},
+		{"12 小时窗口", Window{Kind: WindowFixed, Length: 12 * time.Hour}, time.Date(2024, 1, 2, 9, 0, 0, 0, time.Local),
+			time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local), "2024-01-01-1200"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quota := testQuota
+			quota.DayWindow = tt.window
+			start, window := reportWindow(quota, tt.now)
+			if !start.Equal(tt.start) {
+				t.Errorf("start = %v, want %v", start, tt.start)
+			}
+			if id := reportID(start, window.Length); id != tt.id {
+				t.Errorf("reportID = %q, want %q", id, tt.id)
+			}
+		})
+	}
+}
+
+// reportTestStore 构造 2024-01-01 08:00 开始的天窗口内的日志
+func reportTestStore(start time.Time) *MemoryStore {
+	var logs []LogEntry
+	add := func(count int, at time.Duration, entry LogEntry) {
+		for i := 0; i < count; i++ {
+			entry.CreatedAt = start.Add(at).Unix()
+			logs = append(logs, entry)
+		}
+	}
+	alice := LogEntry{ChannelID: 1, UserID: 1, Username: "alice", TokenName: "batch", Type: logTypeConsume}
+	// 渠道 1 在 09:00 和 10:00 分钟超限，09:02 仍在冷却中，11:00 同时分钟超限并达到天限制
+	add(5, time.Hour, alice)
+	add(5, time.Hour+2*time.Minute, alice)
+	add(5, 2*time.Hour, alice)
+	add(10, 3*time.Hour, alice)
+	// 渠道 2 是付费号，有一个错误请求
+	bob := LogEntry{ChannelID: 2, UserID: 2, Username: "bob", TokenName: "default", Type: logTypeConsume, Model: "gemini-2.5-pro", PromptTokens: 100, CompletionTokens: 10}
+	add(2, 4*time.Hour, bob)
+	bob.Type = logTypeError
+	add(1, 4*time.Hour, bob)
+	// 已删除的渠道只统计请求数
+	add(2, 5*time.Hour, LogEntry{ChannelID: 9, UserID: 2, Username: "bob", TokenName: "default", Type: logTypeConsume})
+	// 窗口之外的请求不统计
+	add(3, -time.Minute, alice)
+	add(3, 24*time.Hour, alice)
+
+	return NewMemoryStore([]Channel{{ID: 1, Status: "1"}, {ID: 2, Status: "1", Tag: "gcp"}, {ID: 3, Status: "1"}}, logs)
+}
+
+func TestReporter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.Local)
+	store := reportTestStore(start)
+
+	var payload reportWebhookPayload
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer webhook.Close()
+	mailer, messages := newStandInMailer(t, SMTPPlain)
+
+	renderer, err := NewRenderer(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := NewReportArchive(t.TempDir())
+	config := ReportConfig{EmailTo: []string{"ops@example.com"}, WebhookURL: webhook.URL}
+	reporter := NewReporter(store, testQuota, time.Local, config, archive, renderer, mailer)
+
+	report, err := reporter.RunOnce(context.Background(), start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ID != "2024-01-01" || !report.End.Equal(start.Add(24*time.Hour)) {
+		t.Errorf("ID = %q, End = %v", report.ID, report.End)
+	}
+	if report.TotalRequests != 30 || report.TotalErrors != 1 || report.TotalTokens() != 330 {
+		t.Errorf("总量 = %d 次请求，%d 个错误，%d token", report.TotalRequests, report.TotalErrors, report.TotalTokens())
+	}
+	if report.PeakMinute != 10 || !report.PeakMinuteAt.Equal(start.Add(3*time.Hour)) {
+		t.Errorf("峰值 = %d @ %v", report.PeakMinute, report.PeakMinuteAt)
+	}
+	if report.ActiveChannels != 3 || report.TotalChannels != 3 || report.DisableCount != 4 {
+		t.Errorf("渠道 = %d/%d，禁用 %d 次", report.ActiveChannels, report.TotalChannels, report.DisableCount)
+	}
+	if len(report.LimitChannels) != 1 {
+		t.Fatalf("LimitChannels = %+v", report.LimitChannels)
+	}
+	limited := report.LimitChannels[0]
+	if limited.ChannelID != 1 || limited.MinuteLimitHits != 3 ||
+		!limited.FirstMinuteLimitAt.Equal(start.Add(time.Hour)) || !limited.DayLimitAt.Equal(start.Add(3*time.Hour)) {
+		t.Errorf("渠道 1 = %+v", limited)
+	}
+	if len(report.ErrorChannels) != 1 || report.ErrorChannels[0].ChannelID != 2 || !report.ErrorChannels[0].IsPaid {
+		t.Errorf("ErrorChannels = %+v", report.ErrorChannels)
+	}
+	if len(report.TopUsers) != 2 || report.TopUsers[0].Name() != "alice" || report.TopUsers[0].Requests != 25 {
+		t.Errorf("TopUsers = %+v", report.TopUsers)
+	}
+
+	// 已保存到存档
+	saved, err := archive.Load("2024-01-01")
+	if err != nil || saved.TotalRequests != 30 || !saved.LimitChannels[0].DayLimitAt.Equal(start.Add(3*time.Hour)) {
+		t.Errorf("Load = %+v, %v", saved, err)
+	}
+	// 读回的窗口描述仍可按语言翻译
+	if saved != nil && saved.MinuteWindowLabel.Translate(LangEN) != "1 min" {
+		t.Errorf("MinuteWindowLabel = %q", saved.MinuteWindowLabel.Translate(LangEN))
+	}
+	if reports, err := archive.List(); err != nil || len(reports) != 1 {
+		t.Errorf("List = %v, %v", reports, err)
+	}
+	if _, err := archive.Load("../secret"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("不合法的 ID 应返回 fs.ErrNotExist，得到 %v", err)
+	}
+
+	// 已通过 webhook 和邮件发送
+	if payload.ID != "2024-01-01" || !strings.Contains(payload.Markdown, "| #1 | 普号 | 25 | 3 | 01-01 09:00 | 01-01 11:00 |") {
+		t.Errorf("webhook = %+v", payload)
+	}
+	msg := <-messages
+	if len(msg.To) != 1 || msg.To[0] != "ops@example.com" || !strings.Contains(msg.Data, "<td>#2</td><td>3</td><td>1</td><td>33.3%</td>") {
+		t.Errorf("邮件 = %+v", msg)
+	}
+
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, "reports.html", []*DailyReport{report}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<a href="/reports/2024-01-01">2024-01-01 08:00 至 01-02 08:00</a>`) {
+		t.Error("日报列表中缺少链接")
+	}
+	buf.Reset()
+	if err := renderer.Render(&buf, "report.html", report); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<td>1</td><td>alice / batch</td><td>25</td>") {
+		t.Error("日报页面中缺少令牌排行")
+	}
+}
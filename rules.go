@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChannelSelector 选择规则适用的渠道，为空的字段不限制
+type ChannelSelector struct {
+	Tier string   `json:"tier,omitempty"` // paid 或 normal
+	Tags []string `json:"tags,omitempty"`
+	IDs  []int    `json:"ids,omitempty"`
+}
+
+// Matches 判断渠道是否满足所有非空的条件
+func (s ChannelSelector) Matches(view ChannelView) bool {
+	switch s.Tier {
+	case "paid":
+		if !view.IsPaid {
+			return false
+		}
+	case "normal":
+		if view.IsPaid {
+			return false
+		}
+	}
+	if len(s.Tags) > 0 && !slices.Contains(s.Tags, view.Tag) {
+		return false
+	}
+	return len(s.IDs) == 0 || slices.Contains(s.IDs, view.ID)
+}
+
+// String 返回便于阅读的选择条件
+func (s ChannelSelector) String() string {
+	var parts []string
+	switch s.Tier {
+	case "paid":
+		parts = append(parts, "付费号")
+	case "normal":
+		parts = append(parts, "普号")
+	}
+	if len(s.Tags) > 0 {
+		parts = append(parts, "tag "+strings.Join(s.Tags, "/"))
+	}
+	if len(s.IDs) > 0 {
+		ids := make([]string, len(s.IDs))
+		for i, id := range s.IDs {
+			ids[i] = "#" + strconv.Itoa(id)
+		}
+		parts = append(parts, strings.Join(ids, "、"))
+	}
+	if len(parts) == 0 {
+		return "全部渠道"
+	}
+	return strings.Join(parts, "，")
+}
+
+// 条件中可以使用的布尔指标和数值指标，数值指标在没有数据时返回 false
+var (
+	boolMetrics = map[string]func(ChannelView) bool{
+		"unavailable":  func(v ChannelView) bool { return !v.IsAvailable },
+		"cooling_down": func(v ChannelView) bool { return v.IsCoolingDown },
+		"slow":         func(v ChannelView) bool { return v.IsSlow },
+	}
+	numericMetrics = map[string]func(ChannelView) (float64, bool){
+		"minute_usage":      func(v ChannelView) (float64, bool) { return float64(v.CountMinuteUsage), true },
+		"day_usage":         func(v ChannelView) (float64, bool) { return float64(v.CountDayUsage), true },
+		"minute_percentage": func(v ChannelView) (float64, bool) { return v.MinutePercentage, true },
+		"day_percentage":    func(v ChannelView) (float64, bool) { return v.DayPercentage, true },
+		"error_rate":        func(v ChannelView) (float64, bool) { return v.ErrorRate, true },
+		"today_cost":        func(v ChannelView) (float64, bool) { return v.TodayCost, true },
+		"latency_p50": func(v ChannelView) (float64, bool) {
+			if v.Latency == nil {
+				return 0, false
+			}
+			return float64(v.Latency.P50), true
+		},
+	}
+	compareOps = map[string]func(a, b float64) bool{
+		">":  func(a, b float64) bool { return a > b },
+		">=": func(a, b float64) bool { return a >= b },
+		"<":  func(a, b float64) bool { return a < b },
+		"<=": func(a, b float64) bool { return a <= b },
+		"==": func(a, b float64) bool { return a == b },
+		"!=": func(a, b float64) bool { return a != b },
+	}
+)
+
+// ruleCondition 解析后的条件：布尔指标，或 数值指标 比较符 阈值
+type ruleCondition struct {
+	metric string
+	op     string
+	value  float64
+}
+
+// parseCondition 解析 unavailable、day_percentage > 90 这样的条件
+func parseCondition(spec string) (ruleCondition, error) {
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 1:
+		if _, ok := boolMetrics[fields[0]]; !ok {
+			return ruleCondition{}, fmt.Errorf("未知的布尔指标 %q", fields[0])
+		}
+		return ruleCondition{metric: fields[0]}, nil
+	case 3:
+		if _, ok := numericMetrics[fields[0]]; !ok {
+			return ruleCondition{}, fmt.Errorf("未知的数值指标 %q", fields[0])
+		}
+		if _, ok := compareOps[fields[1]]; !ok {
+			return ruleCondition{}, fmt.Errorf("未知的比较符 %q", fields[1])
+		}
+		value, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return ruleCondition{}, fmt.Errorf("无效的阈值 %q", fields[2])
+		}
+		return ruleCondition{metric: fields[0], op: fields[1], value: value}, nil
+	}
+	return ruleCondition{}, fmt.Errorf("条件 %q 的格式应为 指标 或 指标 比较符 阈值", spec)
+}
+
+// eval 判断渠道是否满足条件，数值条件同时返回当前值
+func (c ruleCondition) eval(view ChannelView) (bool, float64) {
+	if c.op == "" {
+		return boolMetrics[c.metric](view), 0
+	}
+	value, ok := numericMetrics[c.metric](view)
+	return ok && compareOps[c.op](value, c.value), value
+}
+
+// AlertRule 针对每个渠道判断的告警规则
+type AlertRule struct {
+	Name        string          `json:"name"`
+	Severity    Severity        `json:"severity"`
+	Condition   string          `json:"condition"`
+	Selector    ChannelSelector `json:"selector"`
+	For         string          `json:"for,omitempty"`           // 条件持续成立多久后才告警，例如 30m
+	Before      string          `json:"before,omitempty"`        // 只在每天该时刻之前判断，HH:MM
+	After       string          `json:"after,omitempty"`         // 只在每天该时刻之后判断，HH:MM
+	MinDayUsage int             `json:"min_day_usage,omitempty"` // 天窗口请求数少于该值时不判断，避免样本太少误报
+
+	condition   ruleCondition
+	forDuration time.Duration
+	before      time.Duration // 为 0 时不限制
+	after       time.Duration
+}
+
+// compile 校验规则并解析其中的条件、时长和时刻
+func (r *AlertRule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("规则缺少 name")
+	}
+	switch r.Severity {
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("规则 %q 的 severity 无效: %q", r.Name, r.Severity)
+	}
+	switch r.Selector.Tier {
+	case "", "paid", "normal":
+	default:
+		return fmt.Errorf("规则 %q 的 tier 无效: %q，可选 paid 或 normal", r.Name, r.Selector.Tier)
+	}
+	var err error
+	if r.condition, err = parseCondition(r.Condition); err != nil {
+		return fmt.Errorf("规则 %q: %w", r.Name, err)
+	}
+	if r.For != "" {
+		if r.forDuration, err = time.ParseDuration(r.For); err != nil || r.forDuration < 0 {
+			return fmt.Errorf("规则 %q 的 for 无效: %q", r.Name, r.For)
+		}
+	}
+	if r.Before != "" {
+		if r.before, err = parseClock(r.Before); err != nil {
+			return fmt.Errorf("规则 %q 的 before 无效: %w", r.Name, err)
+		}
+	}
+	if r.After != "" {
+		if r.after, err = parseClock(r.After); err != nil {
+			return fmt.Errorf("规则 %q 的 after 无效: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// inHours 判断 now 是否在规则的 before/after 时段内
+func (r *AlertRule) inHours(now time.Time) bool {
+	y, m, d := now.Date()
+	clock := now.Sub(time.Date(y, m, d, 0, 0, 0, 0, now.Location()))
+	return (r.Before == "" || clock < r.before) && (r.After == "" || clock >= r.after)
+}
+
+// defaultAlertRules 未配置 ALERT_RULES_FILE 时使用的规则
+func defaultAlertRules() []AlertRule {
+	return []AlertRule{
+		{Name: "付费号不可用", Severity: SeverityCritical, Condition: "unavailable", Selector: ChannelSelector{Tier: "paid"}},
+		{Name: "上午接近天限制", Severity: SeverityWarning, Condition: "day_percentage > 90", Before: "12:00"},
+		{Name: "渠道长时间不可用", Severity: SeverityWarning, Condition: "unavailable", For: "30m"},
+		{Name: "错误率过高", Severity: SeverityWarning, Condition: "error_rate > 20", MinDayUsage: 20},
+	}
+}
+
+// loadAlertRules 从 JSON 文件读取规则，path 为空时使用默认规则
+func loadAlertRules(path string) ([]AlertRule, error) {
+	rules := defaultAlertRules()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rules = nil
+		if err := json.Unmarshal(data, &rules); err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+		}
+	}
+	seen := make(map[string]bool)
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, err
+		}
+		if seen[rules[i].Name] {
+			return nil, fmt.Errorf("规则名 %q 重复", rules[i].Name)
+		}
+		seen[rules[i].Name] = true
+	}
+	return rules, nil
+}
+
+// ruleAlertKey 返回规则在某个渠道上的告警 key
+func ruleAlertKey(rule string, channelID int) string {
+	return fmt.Sprintf("rule:%s:%d", rule, channelID)
+}
+
+// RuleEvaluator 在每次采集后对每个渠道判断告警规则
+type RuleEvaluator struct {
+	rules    []AlertRule
+	alerter  *Alerter
+	snapshot func() *Snapshot
+	interval time.Duration
+
+	mu            sync.Mutex
+	since         map[string]time.Time // 条件开始连续成立的时间
+	lastCollected time.Time
+}
+
+// NewRuleEvaluator 创建规则判断器，snapshot 返回最近一次的采集结果
+func NewRuleEvaluator(rules []AlertRule, alerter *Alerter, snapshot func() *Snapshot, interval time.Duration) *RuleEvaluator {
+	return &RuleEvaluator{
+		rules:    rules,
+		alerter:  alerter,
+		snapshot: snapshot,
+		interval: interval,
+		since:    make(map[string]time.Time),
+	}
+}
+
+// Rules 返回所有规则
+func (e *RuleEvaluator) Rules() []AlertRule {
+	return e.rules
+}
+
+// Run 按间隔检查是否有新的采集结果，有则判断规则，直到 ctx 被取消
+func (e *RuleEvaluator) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		if snapshot := e.snapshot(); snapshot != nil && !snapshot.CollectedAt.Equal(e.lastCollected) {
+			e.lastCollected = snapshot.CollectedAt
+			e.Evaluate(ctx, snapshot)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Evaluate 对面板数据中的每个渠道判断所有规则。
+// 条件持续成立 For 之后才告警；条件不再成立、不在生效时段或渠道消失时恢复
+func (e *RuleEvaluator) Evaluate(ctx context.Context, snapshot *Snapshot) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := snapshot.CollectedAt
+	matched := make(map[string]bool)
+	for i := range e.rules {
+		rule := &e.rules[i]
+		if !rule.inHours(now) {
+			continue
+		}
+		for _, view := range snapshot.Channels {
+			if !rule.Selector.Matches(view) || view.CountDayUsage < rule.MinDayUsage {
+				continue
+			}
+			ok, value := rule.condition.eval(view)
+			if !ok {
+				continue
+			}
+			key := ruleAlertKey(rule.Name, view.ID)
+			matched[key] = true
+			since, pending := e.since[key]
+			if !pending {
+				since = now
+				e.since[key] = now
+			}
+
+			message := fmt.Sprintf("渠道 #%d（%s）满足 %s", view.ID, view.TagDisplay, rule.Condition)
+			if rule.condition.op != "" {
+				message += fmt.Sprintf("，当前值 %.1f", value)
+			}
+			if rule.forDuration > 0 {
+				message += "，已持续 " + formatWindowLength(now.Sub(since).Truncate(time.Minute)).String()
+			}
+			e.alerter.Update(ctx, now.Sub(since) >= rule.forDuration, Alert{
+				Key:      key,
+				Severity: rule.Severity,
+				Title:    rule.Name,
+				Message:  message,
+				Rule:     rule.Name,
+				Channels: []int{view.ID},
+				StartsAt: since,
+			})
+		}
+	}
+
+	// 按 key 排序后恢复，使通知的顺序固定
+	var resolved []string
+	for key := range e.since {
+		if !matched[key] {
+			resolved = append(resolved, key)
+		}
+	}
+	sort.Strings(resolved)
+	for _, key := range resolved {
+		delete(e.since, key)
+		e.alerter.Update(ctx, false, Alert{Key: key})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseCondition(t *testing.T) {
+	paid := ChannelView{ID: 1, IsPaid: true, IsAvailable: false, DayPercentage: 95, ErrorRate: 10}
+	tests := []struct {
+		spec    string
+		want    bool
+		wantErr bool
+	}{
+		{"unavailable", true, false},
+		{"cooling_down", false, false},
+		{"day_percentage > 90", true, false},
+		{"day_percentage >= 95", true, false},
+		{"error_rate > 20", false, false},
+		{"latency_p50 > 0", false, false}, // 没有延迟数据时不成立
+		{"day_percentage", false, true},
+		{"unknown > 1", false, true},
+		{"error_rate => 1", false, true},
+		{"error_rate > abc", false, true},
+	}
+	for _, tt := range tests {
+		condition, err := parseCondition(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCondition(%q) error = %v", tt.spec, err)
+			continue
+		}
+		if err == nil {
+			if got, _ := condition.eval(paid); got != tt.want {
+				t.Errorf("%q 的结果 = %v, want %v", tt.spec, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestLoadAlertRules(t *testing.T) {
+	rules, err := loadAlertRules("")
+	if err != nil || len(rules) != len(defaultAlertRules()) {
+		t.Fatalf("默认规则: %v, %v", rules, err)
+	}
+
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"有效", `[{"name":"a","severity":"info","condition":"slow","selector":{"tags":["gcp"]},"for":"5m","after":"08:00"}]`, false},
+		{"级别无效", `[{"name":"a","severity":"fatal","condition":"slow"}]`, true},
+		{"tier 无效", `[{"name":"a","severity":"info","condition":"slow","selector":{"tier":"vip"}}]`, true},
+		{"for 无效", `[{"name":"a","severity":"info","condition":"slow","for":"soon"}]`, true},
+		{"时刻无效", `[{"name":"a","severity":"info","condition":"slow","before":"25:00"}]`, true},
+		{"重名", `[{"name":"a","severity":"info","condition":"slow"},{"name":"a","severity":"info","condition":"unavailable"}]`, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, "rules.json")
+		if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadAlertRules(path); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+	}
+}
+
+func TestRuleEvaluator(t *testing.T) {
+	rules := []AlertRule{
+		{Name: "付费号不可用", Severity: SeverityCritical, Condition: "unavailable", Selector: ChannelSelector{Tier: "paid"}},
+		{Name: "长时间不可用", Severity: SeverityWarning, Condition: "unavailable", For: "30m"},
+		{Name: "上午接近天限制", Severity: SeverityWarning, Condition: "day_percentage > 90", Before: "12:00"},
+		{Name: "错误率过高", Severity: SeverityWarning, Condition: "error_rate > 20", Selector: ChannelSelector{IDs: []int{3}}, MinDayUsage: 20},
+	}
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	notifier := &recordingNotifier{}
+	evaluator := NewRuleEvaluator(rules, NewAlerter(notifier), nil, time.Minute)
+	ctx := context.Background()
+	evaluate := func(now time.Time, channels ...ChannelView) []string {
+		notifier.alerts = nil
+		evaluator.Evaluate(ctx, &Snapshot{CollectedAt: now, Channels: channels})
+		var keys []string
+		for _, alert := range notifier.alerts {
+			key := alert.Key
+			if alert.Resolved {
+				key += " 恢复"
+			}
+			keys = append(keys, key)
+		}
+		return keys
+	}
+	expect := func(step string, got []string, want ...string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Errorf("%s: 通知 = %v, want %v", step, got, want)
+			return
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: 通知 = %v, want %v", step, got, want)
+				return
+			}
+		}
+	}
+
+	paid := ChannelView{ID: 1, IsPaid: true, DayPercentage: 50}
+	normal := ChannelView{ID: 2, IsAvailable: true, DayPercentage: 95}
+	flaky := ChannelView{ID: 3, IsAvailable: true, CountDayUsage: 10, ErrorRate: 50}
+
+	// 付费号不可用立即告警，持续条件尚未满足；样本不足时不判断错误率
+	expect("09:30", evaluate(testNow, paid, normal, flaky), ruleAlertKey("付费号不可用", 1), ruleAlertKey("上午接近天限制", 2))
+
+	// 持续 30 分钟后才告警，且开始时间为条件开始成立的时间
+	flaky.CountDayUsage = 20
+	expect("10:00", evaluate(testNow.Add(30*time.Minute), paid, normal, flaky), ruleAlertKey("长时间不可用", 1), ruleAlertKey("错误率过高", 3))
+	for _, alert := range evaluator.alerter.Firing() {
+		if alert.Key == ruleAlertKey("长时间不可用", 1) && !alert.StartsAt.Equal(testNow) {
+			t.Errorf("StartsAt = %v, want %v", alert.StartsAt, testNow)
+		}
+	}
+
+	// 过了 12:00 不再判断，付费号恢复可用；恢复通知按 key 排序
+	paid.IsAvailable = true
+	expect("12:30", evaluate(testNow.Add(3*time.Hour), paid, normal, flaky),
+		ruleAlertKey("上午接近天限制", 2)+" 恢复", ruleAlertKey("付费号不可用", 1)+" 恢复", ruleAlertKey("长时间不可用", 1)+" 恢复")
+
+	// 渠道从面板中消失时恢复
+	expect("渠道消失", evaluate(testNow.Add(4*time.Hour), paid), ruleAlertKey("错误率过高", 3)+" 恢复")
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// listen 根据监听地址创建 listener，地址以 unix: 开头时监听 Unix socket，
+// 否则按 TCP 地址处理，例如 ":8080"、"127.0.0.1:8080"、"unix:/run/gemini-monitor.sock"
+func listen(addr string) (net.Listener, error) {
+	path, isUnix := strings.CutPrefix(addr, "unix:")
+	if !isUnix {
+		return net.Listen("tcp", addr)
+	}
+
+	// 上次异常退出可能遗留 socket 文件，只删除 socket 类型的文件，避免误删
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s 已存在且不是 socket 文件", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("删除遗留的 socket 文件失败: %w", err)
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// 允许同组的反向代理访问 socket
+	if err := os.Chmod(path, 0o660); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("设置 socket 权限失败: %w", err)
+	}
+	return listener, nil
+}
+
+// localClient 返回访问本机 listenAddr 上服务器的 client 和 URL 前缀，与 listen 使用相同的地址格式。
+// 监听所有网卡（如 ":8080"、"0.0.0.0:8080"）时通过 127.0.0.1 访问
+func localClient(listenAddr string, timeout time.Duration) (*http.Client, string, error) {
+	if path, isUnix := strings.CutPrefix(listenAddr, "unix:"); isUnix {
+		dialer := &net.Dialer{Timeout: timeout}
+		transport := &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", path)
+		}}
+		return &http.Client{Transport: transport, Timeout: timeout}, "http://localhost", nil
+	}
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return nil, "", fmt.Errorf("监听地址 %q 无效: %w", listenAddr, err)
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return &http.Client{Timeout: timeout}, "http://" + net.JoinHostPort(host, port), nil
+}
+
+// serve 在 listener 上运行 server，直到 ctx 被取消后在 shutdownTimeout 内优雅关闭，
+// 关闭期间不再接受新连接，但会等待进行中的请求完成
+func serve(ctx context.Context, server *http.Server, listener net.Listener, shutdownTimeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		// Serve 提前返回说明服务器无法继续运行
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("收到退出信号，正在关闭服务器")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("关闭服务器超时: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// instrument 为每个请求分配请求 ID、创建 span 并记录访问日志。
+// span 以 mux 中匹配的路由命名，避免 /reports/ 等带参数的路径产生大量不同的名称
+func instrument(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		_, route := mux.Handler(r)
+		ctx := otel.GetTextMapPropagator().Extract(withRequestID(r.Context(), id), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method+" "+route, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("request_id", id),
+		))
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+		// 健康检查和静态文件请求频繁，只在 DEBUG 级别记录
+		level := slog.LevelInfo
+		if route == "/healthz" || route == "/readyz" || route == "/static/" {
+			level = slog.LevelDebug
+		}
+		slog.Log(ctx, level, "HTTP 请求", "method", r.Method, "path", r.URL.Path, "route", route,
+			"status", recorder.status, "duration_ms", time.Since(start).Milliseconds(), "remote", r.RemoteAddr)
+	})
+}
+
+// statusRecorder 记录处理函数写入的状态码
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap 供 http.ResponseController 访问原始的 ResponseWriter
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
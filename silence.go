@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Silence 在到期前屏蔽匹配的告警通知，告警仍会显示在告警页面上
+type Silence struct {
+	ID        int       `json:"id"`
+	Rule      string    `json:"rule,omitempty"`       // 为空时匹配所有规则
+	ChannelID int       `json:"channel_id,omitempty"` // 为 0 时匹配所有渠道
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Matches 判断告警是否被屏蔽，只屏蔽由规则产生的告警
+func (s Silence) Matches(alert Alert) bool {
+	if alert.Rule == "" || (s.Rule != "" && s.Rule != alert.Rule) {
+		return false
+	}
+	if s.ChannelID == 0 {
+		return true
+	}
+	for _, id := range alert.Channels {
+		if id == s.ChannelID {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSilenceForm 从告警页面提交的表单中读取屏蔽规则，duration 为 Go 时长格式。
+// 表格视图批量屏蔽时会提交多个 channel_id，每个渠道一条屏蔽规则
+func parseSilenceForm(r *http.Request, now time.Time) ([]Silence, error) {
+	silence := Silence{
+		Rule:      strings.TrimSpace(r.PostFormValue("rule")),
+		Comment:   strings.TrimSpace(r.PostFormValue("comment")),
+		CreatedAt: now,
+	}
+	duration, err := time.ParseDuration(r.PostFormValue("duration"))
+	if err != nil || duration <= 0 {
+		return nil, fmt.Errorf("屏蔽时长无效: %q", r.PostFormValue("duration"))
+	}
+	silence.ExpiresAt = now.Add(duration)
+
+	var result []Silence
+	for _, value := range r.PostForm["channel_id"] {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("渠道 ID 无效: %q", value)
+		}
+		silence.ChannelID = id
+		result = append(result, silence)
+	}
+	if len(result) == 0 {
+		result = append(result, silence)
+	}
+	return result, nil
+}
+
+// localRedirect 返回表单中的 return 地址，只接受本站的路径，否则为 fallback
+func localRedirect(r *http.Request, fallback string) string {
+	target := r.PostFormValue("return")
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return fallback
+	}
+	return target
+}
+
+// sameOrigin 判断修改状态的表单请求是否来自本站页面，防止其他网站通过跨站表单创建或结束屏蔽。
+// 浏览器会发送 Sec-Fetch-Site，旧浏览器按 Origin、Referer 与 Host 比较；
+// 都没有时不是浏览器发起的跨站请求（如 curl），允许
+func sameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin" || site == "none"
+	}
+	for _, header := range []string{"Origin", "Referer"} {
+		value := r.Header.Get(header)
+		if value == "" {
+			continue
+		}
+		u, err := url.Parse(value)
+		return err == nil && u.Host != "" && u.Host == r.Host
+	}
+	return true
+}
+
+// SilenceStore 保存屏蔽规则，每次修改后写入 JSON 文件，重启后仍然有效
+type SilenceStore struct {
+	path string
+
+	mu       sync.Mutex
+	silences []Silence
+	nextID   int
+}
+
+// NewSilenceStore 从 path 读取已有的屏蔽规则，文件不存在时为空
+func NewSilenceStore(path string) (*SilenceStore, error) {
+	s := &SilenceStore{path: path, nextID: 1}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.silences); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+	}
+	for _, silence := range s.silences {
+		s.nextID = max(s.nextID, silence.ID+1)
+	}
+	return s, nil
+}
+
+// Add 添加一条屏蔽规则并保存
+func (s *SilenceStore) Add(silence Silence) (Silence, error) {
+	if !silence.ExpiresAt.After(silence.CreatedAt) {
+		return Silence{}, fmt.Errorf("到期时间必须晚于创建时间")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	silence.ID = s.nextID
+	s.nextID++
+	s.prune(silence.CreatedAt)
+	s.silences = append(s.silences, silence)
+	return silence, s.save()
+}
+
+// Expire 立即结束一条屏蔽规则，不存在时返回 fs.ErrNotExist
+func (s *SilenceStore) Expire(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, silence := range s.silences {
+		if silence.ID == id {
+			s.silences = append(s.silences[:i], s.silences[i+1:]...)
+			return s.save()
+		}
+	}
+	return fs.ErrNotExist
+}
+
+// Active 返回 now 时仍有效的屏蔽规则，按到期时间排序
+func (s *SilenceStore) Active(now time.Time) []Silence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var active []Silence
+	for _, silence := range s.silences {
+		if silence.ExpiresAt.After(now) {
+			active = append(active, silence)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].ExpiresAt.Before(active[j].ExpiresAt) })
+	return active
+}
+
+// Silenced 判断告警在 now 时是否被屏蔽
+func (s *SilenceStore) Silenced(alert Alert, now time.Time) bool {
+	for _, silence := range s.Active(now) {
+		if silence.Matches(alert) {
+			return true
+		}
+	}
+	return false
+}
+
+// prune 删除已到期的屏蔽规则
+func (s *SilenceStore) prune(now time.Time) {
+	kept := s.silences[:0]
+	for _, silence := range s.silences {
+		if silence.ExpiresAt.After(now) {
+			kept = append(kept, silence)
+		}
+	}
+	s.silences = kept
+}
+
+func (s *SilenceStore) save() error {
+	if dir := filepath.Dir(s.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(s.silences, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免重启时读到写了一半的文件
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// FiringAlert 告警页面上的一条触发中的告警
+type FiringAlert struct {
+	Alert
+	Silenced bool
+}
+
+// AlertsPage 告警页面的数据
+type AlertsPage struct {
+	Firing   []FiringAlert
+	Rules    []AlertRule
+	Silences []Silence
+}
+
+// newAlertsPage 汇总触发中的告警、告警规则和仍有效的屏蔽规则
+func newAlertsPage(alerter *Alerter, rules []AlertRule, silences *SilenceStore, now time.Time) AlertsPage {
+	page := AlertsPage{Rules: rules, Silences: silences.Active(now)}
+	for _, alert := range alerter.Firing() {
+		page.Firing = append(page.Firing, FiringAlert{Alert: alert, Silenced: silences.Silenced(alert, now)})
+	}
+	return page
+}
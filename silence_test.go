@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSilenceMatches(t *testing.T) {
+	alert := Alert{Key: ruleAlertKey("付费号不可用", 3), Rule: "付费号不可用", Channels: []int{3}}
+	tests := []struct {
+		name    string
+		silence Silence
+		alert   Alert
+		want    bool
+	}{
+		{"全部规则全部渠道", Silence{}, alert, true},
+		{"规则匹配", Silence{Rule: "付费号不可用"}, alert, true},
+		{"规则不匹配", Silence{Rule: "错误率过高"}, alert, false},
+		{"渠道匹配", Silence{Rule: "付费号不可用", ChannelID: 3}, alert, true},
+		{"渠道不匹配", Silence{ChannelID: 4}, alert, false},
+		{"不屏蔽非规则告警", Silence{}, Alert{Key: "budget:daily"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.silence.Matches(tt.alert); got != tt.want {
+			t.Errorf("%s: Matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSilenceStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "silences.json")
+	store, err := NewSilenceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := store.Add(Silence{Rule: "a", CreatedAt: testNow, ExpiresAt: testNow.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Add(Silence{ChannelID: 2, CreatedAt: testNow, ExpiresAt: testNow.Add(24 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Add(Silence{CreatedAt: testNow, ExpiresAt: testNow}); err == nil {
+		t.Error("到期时间不晚于创建时间时应返回错误")
+	}
+
+	// 重新读取文件后屏蔽仍然有效，ID 继续递增
+	store, err = NewSilenceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active := store.Active(testNow.Add(2 * time.Hour)); len(active) != 1 || active[0].ChannelID != 2 {
+		t.Errorf("Active() = %+v", active)
+	}
+	if third, _ := store.Add(Silence{CreatedAt: testNow, ExpiresAt: testNow.Add(time.Hour)}); third.ID != 3 {
+		t.Errorf("新屏蔽的 ID = %d, want 3", third.ID)
+	}
+	if err := store.Expire(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Expire(first.ID); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("重复结束屏蔽 error = %v", err)
+	}
+	if active := store.Active(testNow); len(active) != 2 {
+		t.Errorf("Active() = %+v", active)
+	}
+}
+
+func TestParseSilenceForm(t *testing.T) {
+	tests := []struct {
+		form     url.Values
+		channels []int
+		wantErr  bool
+	}{
+		{url.Values{"rule": {"a"}, "channel_id": {"3"}, "duration": {"4h"}}, []int{3}, false},
+		{url.Values{"duration": {"1h"}}, []int{0}, false},
+		{url.Values{"channel_id": {""}, "duration": {"1h"}}, []int{0}, false},
+		// 表格视图批量屏蔽
+		{url.Values{"channel_id": {"3", "5"}, "duration": {"1h"}}, []int{3, 5}, false},
+		{url.Values{"channel_id": {"x"}, "duration": {"1h"}}, nil, true},
+		{url.Values{"duration": {"-1h"}}, nil, true},
+		{url.Values{}, nil, true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/alerts/silences", strings.NewReader(tt.form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		silences, err := parseSilenceForm(r, testNow)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: error = %v", tt.form, err)
+			continue
+		}
+		var channels []int
+		for _, silence := range silences {
+			channels = append(channels, silence.ChannelID)
+			if !silence.ExpiresAt.Equal(testNow.Add(mustDuration(tt.form.Get("duration")))) {
+				t.Errorf("%v: silence = %+v", tt.form, silence)
+			}
+		}
+		if !reflect.DeepEqual(channels, tt.channels) {
+			t.Errorf("%v: channels = %v, want %v", tt.form, channels, tt.channels)
+		}
+	}
+}
+
+func mustDuration(s string) time.Duration {
+	d, _ := time.ParseDuration(s)
+	return d
+}
+
+func TestLocalRedirect(t *testing.T) {
+	tests := map[string]string{
+		"":                     "/alerts",
+		"/?view=table&page=2":  "/?view=table&page=2",
+		"//evil.example":       "/alerts",
+		"/\\evil.example":      "/alerts",
+		"https://evil.example": "/alerts",
+	}
+	for target, want := range tests {
+		form := url.Values{"return": {target}}
+		r := httptest.NewRequest("POST", "/alerts/silences", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if got := localRedirect(r, "/alerts"); got != want {
+			t.Errorf("localRedirect(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{"本站页面", map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://monitor.local"}, true},
+		{"跨站表单", map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://evil.example"}, false},
+		{"同站的其他子域名", map[string]string{"Sec-Fetch-Site": "same-site"}, false},
+		// 反向代理改写了 Host 时仍以浏览器的判断为准
+		{"代理改写 Host", map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "https://monitor.example.com"}, true},
+		{"旧浏览器 Origin", map[string]string{"Origin": "http://monitor.local"}, true},
+		{"旧浏览器跨站 Origin", map[string]string{"Origin": "https://evil.example"}, false},
+		{"Origin 为 null", map[string]string{"Origin": "null"}, false},
+		{"只有 Referer", map[string]string{"Referer": "http://monitor.local/alerts"}, true},
+		{"跨站 Referer", map[string]string{"Referer": "https://evil.example/form"}, false},
+		{"非浏览器", nil, true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "http://monitor.local/alerts/silences", nil)
+		for key, value := range tt.headers {
+			r.Header.Set(key, value)
+		}
+		if got := sameOrigin(r); got != tt.want {
+			t.Errorf("%s: sameOrigin = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAlerterSilences(t *testing.T) {
+	notifier := &recordingNotifier{}
+	alerter := NewAlerter(notifier)
+	silences, err := NewSilenceStore(filepath.Join(t.TempDir(), "silences.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerter.SetSilences(silences)
+	ctx := context.Background()
+	now := time.Now()
+	silence, err := silences.Add(Silence{Rule: "r", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 被屏蔽的告警不通知，但仍在触发中
+	alert := Alert{Key: "rule:r:1", Rule: "r", Channels: []int{1}, Severity: SeverityWarning}
+	alerter.Update(ctx, true, alert)
+	if len(notifier.alerts) != 0 || len(alerter.Firing()) != 1 {
+		t.Fatalf("alerts = %+v, firing = %+v", notifier.alerts, alerter.Firing())
+	}
+	// 从未通知过的告警恢复时不发送恢复通知
+	alerter.Update(ctx, false, alert)
+	if len(notifier.alerts) != 0 {
+		t.Fatalf("不应发送恢复通知: %+v", notifier.alerts)
+	}
+
+	// 屏蔽结束后告警仍在触发则补发
+	alerter.Update(ctx, true, alert)
+	if err := silences.Expire(silence.ID); err != nil {
+		t.Fatal(err)
+	}
+	alerter.Update(ctx, true, alert)
+	alerter.Update(ctx, false, alert)
+	if len(notifier.alerts) != 2 || notifier.alerts[0].Resolved || !notifier.alerts[1].Resolved {
+		t.Errorf("alerts = %+v", notifier.alerts)
+	}
+}
+
+func TestRenderAlertsPage(t *testing.T) {
+	renderer, err := NewRenderer(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	silences, err := NewSilenceStore(filepath.Join(t.TempDir(), "silences.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if _, err := silences.Add(Silence{Rule: "错误率过高", ChannelID: 2, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	alerter := NewAlerter(&recordingNotifier{})
+	alerter.SetSilences(silences)
+	alerter.Update(context.Background(), true, Alert{Key: ruleAlertKey("错误率过高", 2), Rule: "错误率过高", Channels: []int{2}, Severity: SeverityWarning, Title: "错误率过高"})
+	alerter.Update(context.Background(), true, Alert{Key: ruleAlertKey("付费号不可用", 1), Rule: "付费号不可用", Channels: []int{1}, Severity: SeverityCritical, Title: "付费号不可用"})
+
+	var out strings.Builder
+	if err := renderer.Render(&out, "alerts.html", newAlertsPage(alerter, defaultAlertRules(), silences, now)); err != nil {
+		t.Fatal(err)
+	}
+	html := out.String()
+	// 已屏蔽的告警不再显示屏蔽按钮，未屏蔽的告警可以一键屏蔽
+	if strings.Count(html, `name="channel_id" value="1"`) != 1 || strings.Contains(html, `name="channel_id" value="2"`) {
+		t.Errorf("屏蔽表单不正确:\n%s", html)
+	}
+	if !strings.Contains(html, "已屏蔽") || !strings.Contains(html, `<a href="/channels/2">#2</a>`) {
+		t.Errorf("告警页面缺少屏蔽信息:\n%s", html)
+	}
+}
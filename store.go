@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// UsageCounts 表示某个渠道在分钟/天两个配额窗口内的请求数
+type UsageCounts struct {
+	Minute    int
+	Day       int
+	DayErrors int // 天窗口内的错误请求数
+}
+
+// TokenCounts 表示某个渠道、某个模型在一个时间桶内消耗的 token 数
+type TokenCounts struct {
+	ChannelID        int
+	Model            string
+	Bucket           int64 // 桶起点的 Unix 时间戳
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+// ConsumerCounts 表示某个用户的某个令牌在某个渠道上的请求数
+type ConsumerCounts struct {
+	UserID    int
+	Username  string
+	TokenName string
+	ChannelID int
+	Requests  int
+}
+
+// LatencyCounts 表示某个渠道、某个模型耗时为 UseTime 秒的请求数
+type LatencyCounts struct {
+	ChannelID int
+	Model     string
+	UseTime   int
+	Count     int
+}
+
+// ActivityCounts 表示某个渠道在一个时间桶内的请求数和其中的错误数
+type ActivityCounts struct {
+	ChannelID int
+	Bucket    int64 // 桶起点的 Unix 时间戳
+	Requests  int
+	Errors    int
+}
+
+// newapi 日志类型：成功消费和错误，错误日志的耗时不参与延迟统计
+const (
+	logTypeConsume = 2
+	logTypeError   = 5
+)
+
+// Store 抽象监控所需的数据访问，生产环境使用 MySQL 实现，测试使用内存实现
+type Store interface {
+	// Ping 检查数据源是否可用
+	Ping(ctx context.Context) error
+	// Channels 返回所有渠道及其冷却记录
+	Channels(ctx context.Context) ([]Channel, error)
+	// Usage 统计每个渠道自 minuteStart、dayStart 起到 now 为止的请求数，没有请求的渠道不出现在结果中
+	Usage(ctx context.Context, minuteStart, dayStart, now time.Time) (map[int]UsageCounts, error)
+	// Demand 按 bucket 长度分桶统计 [since, until) 内所有渠道的请求数，key 为桶起点的 Unix 时间戳，没有请求的桶不出现在结果中
+	Demand(ctx context.Context, since, until time.Time, bucket time.Duration) (map[int64]int, error)
+	// Tokens 从 since 起按 bucket 长度分桶，统计 [since, until) 内每个渠道、每个模型的 token 数
+	Tokens(ctx context.Context, since, until time.Time, bucket time.Duration) ([]TokenCounts, error)
+	// Consumers 按用户、令牌和渠道统计 [since, until] 内的请求数
+	Consumers(ctx context.Context, since, until time.Time) ([]ConsumerCounts, error)
+	// Latencies 统计 [since, until] 内成功请求的耗时分布
+	Latencies(ctx context.Context, since, until time.Time) ([]LatencyCounts, error)
+	// Activity 从 since 起按 bucket 长度分桶，统计 [since, until) 内每个渠道的请求数和错误数
+	Activity(ctx context.Context, since, until time.Time, bucket time.Duration) ([]ActivityCounts, error)
+	// DisableChannels 禁用指定渠道并写入冷却记录，已有更晚的解禁时间时保留原记录
+	DisableChannels(ctx context.Context, ids []int, cooldown Cooldown) error
+	// UpdateChannels 写回执行配额的结果，包括渠道状态、计数、权重和冷却记录，并更新心跳时间
+	UpdateChannels(ctx context.Context, updates []ChannelUpdate) error
+	// CountersUpdatedAt 返回存储过程或 serve --enforce 最近一次写回计数的时间，没有记录时返回零值
+	CountersUpdatedAt(ctx context.Context) (time.Time, error)
+}
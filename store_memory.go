@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// LogEntry 表示 logs 表中的一条请求记录，仅包含监控用到的字段
+type LogEntry struct {
+	ChannelID        int
+	CreatedAt        int64 // Unix 时间戳
+	Model            string
+	PromptTokens     int64
+	CompletionTokens int64
+	UserID           int
+	Username         string
+	TokenName        string
+	Type             int
+	UseTime          int // 秒
+}
+
+// MemoryStore 是 Store 的内存实现，用于测试和本地演示
+type MemoryStore struct {
+	mu                sync.RWMutex
+	channels          []Channel
+	logs              []LogEntry
+	countersUpdatedAt time.Time
+}
+
+// NewMemoryStore 使用给定的渠道和日志创建内存 Store
+func NewMemoryStore(channels []Channel, logs []LogEntry) *MemoryStore {
+	return &MemoryStore{channels: channels, logs: logs}
+}
+
+// SetCountersUpdatedAt 模拟存储过程在 t 时刻写回计数
+func (s *MemoryStore) SetCountersUpdatedAt(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.countersUpdatedAt = t
+}
+
+// SetChannels 替换全部渠道
+func (s *MemoryStore) SetChannels(channels []Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels = channels
+}
+
+// AddLogs 追加日志记录
+func (s *MemoryStore) AddLogs(logs ...LogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = append(s.logs, logs...)
+}
+
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *MemoryStore) Channels(ctx context.Context) ([]Channel, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	channels := make([]Channel, len(s.channels))
+	copy(channels, s.channels)
+	return channels, nil
+}
+
+func (s *MemoryStore) Usage(ctx context.Context, minuteStart, dayStart, now time.Time) (map[int]UsageCounts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	usage := make(map[int]UsageCounts)
+	for _, entry := range s.logs {
+		if entry.CreatedAt > now.Unix() {
+			continue
+		}
+		inMinute := entry.CreatedAt >= minuteStart.Unix()
+		inDay := entry.CreatedAt >= dayStart.Unix()
+		if !inMinute && !inDay {
+			continue
+		}
+		counts := usage[entry.ChannelID]
+		if inMinute {
+			counts.Minute++
+		}
+		if inDay {
+			counts.Day++
+			if entry.Type == logTypeError {
+				counts.DayErrors++
+			}
+		}
+		usage[entry.ChannelID] = counts
+	}
+	return usage, nil
+}
+
+func (s *MemoryStore) Demand(ctx context.Context, since, until time.Time, bucket time.Duration) (map[int64]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	size := int64(bucket / time.Second)
+	demand := make(map[int64]int)
+	for _, entry := range s.logs {
+		if entry.CreatedAt < since.Unix() || entry.CreatedAt >= until.Unix() {
+			continue
+		}
+		demand[entry.CreatedAt/size*size]++
+	}
+	return demand, nil
+}
+
+func (s *MemoryStore) Tokens(ctx context.Context, since, until time.Time, bucket time.Duration) ([]TokenCounts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	type key struct {
+		channelID int
+		model     string
+		bucket    int64
+	}
+	size := int64(bucket / time.Second)
+	sums := make(map[key]*TokenCounts)
+	var tokens []TokenCounts
+	var order []key
+	for _, entry := range s.logs {
+		if entry.CreatedAt < since.Unix() || entry.CreatedAt >= until.Unix() {
+			continue
+		}
+		k := key{entry.ChannelID, entry.Model, since.Unix() + (entry.CreatedAt-since.Unix())/size*size}
+		counts, ok := sums[k]
+		if !ok {
+			counts = &TokenCounts{ChannelID: k.channelID, Model: k.model, Bucket: k.bucket}
+			sums[k] = counts
+			order = append(order, k)
+		}
+		counts.PromptTokens += entry.PromptTokens
+		counts.CompletionTokens += entry.CompletionTokens
+	}
+	for _, k := range order {
+		tokens = append(tokens, *sums[k])
+	}
+	return tokens, nil
+}
+
+func (s *MemoryStore) Consumers(ctx context.Context, since, until time.Time) ([]ConsumerCounts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	type key struct {
+		userID    int
+		username  string
+		tokenName string
+		channelID int
+	}
+	counts := make(map[key]int)
+	var order []key
+	for _, entry := range s.logs {
+		if entry.CreatedAt < since.Unix() || entry.CreatedAt > until.Unix() {
+			continue
+		}
+		k := key{entry.UserID, entry.Username, entry.TokenName, entry.ChannelID}
+		if _, ok := counts[k]; !ok {
+			order = append(order, k)
+		}
+		counts[k]++
+	}
+	consumers := make([]ConsumerCounts, 0, len(order))
+	for _, k := range order {
+		consumers = append(consumers, ConsumerCounts{UserID: k.userID, Username: k.username, TokenName: k.tokenName, ChannelID: k.channelID, Requests: counts[k]})
+	}
+	return consumers, nil
+}
+
+func (s *MemoryStore) Latencies(ctx context.Context, since, until time.Time) ([]LatencyCounts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	type key struct {
+		channelID int
+		model     string
+		useTime   int
+	}
+	counts := make(map[key]int)
+	var order []key
+	for _, entry := range s.logs {
+		if entry.CreatedAt < since.Unix() || entry.CreatedAt > until.Unix() || entry.Type != logTypeConsume {
+			continue
+		}
+		k := key{entry.ChannelID, entry.Model, entry.UseTime}
+		if _, ok := counts[k]; !ok {
+			order = append(order, k)
+		}
+		counts[k]++
+	}
+	latencies := make([]LatencyCounts, 0, len(order))
+	for _, k := range order {
+		latencies = append(latencies, LatencyCounts{ChannelID: k.channelID, Model: k.model, UseTime: k.useTime, Count: counts[k]})
+	}
+	return latencies, nil
+}
+
+func (s *MemoryStore) Activity(ctx context.Context, since, until time.Time, bucket time.Duration) ([]ActivityCounts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	type key struct {
+		channelID int
+		bucket    int64
+	}
+	size := int64(bucket / time.Second)
+	counts := make(map[key]*ActivityCounts)
+	var order []key
+	for _, entry := range s.logs {
+		if entry.CreatedAt < since.Unix() || entry.CreatedAt >= until.Unix() {
+			continue
+		}
+		k := key{entry.ChannelID, since.Unix() + (entry.CreatedAt-since.Unix())/size*size}
+		c, ok := counts[k]
+		if !ok {
+			c = &ActivityCounts{ChannelID: k.channelID, Bucket: k.bucket}
+			counts[k] = c
+			order = append(order, k)
+		}
+		c.Requests++
+		if entry.Type == logTypeError {
+			c.Errors++
+		}
+	}
+	activity := make([]ActivityCounts, 0, len(order))
+	for _, k := range order {
+		activity = append(activity, *counts[k])
+	}
+	return activity, nil
+}
+
+func (s *MemoryStore) DisableChannels(ctx context.Context, ids []int, cooldown Cooldown) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	disable := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		disable[id] = true
+	}
+	for i := range s.channels {
+		channel := &s.channels[i]
+		if !disable[channel.ID] {
+			continue
+		}
+		channel.Status = "2"
+		if !channel.DisabledUntil.Valid || channel.DisabledUntil.Int64 < cooldown.Until {
+			channel.CooldownReason = sql.NullString{String: cooldown.Reason, Valid: true}
+			channel.DisabledUntil = sql.NullInt64{Int64: cooldown.Until, Valid: true}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateChannels(ctx context.Context, updates []ChannelUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byID := make(map[int]ChannelUpdate, len(updates))
+	for _, update := range updates {
+		byID[update.ChannelID] = update
+	}
+	now := time.Now().Unix()
+	for i := range s.channels {
+		update, ok := byID[s.channels[i].ID]
+		if !ok {
+			continue
+		}
+		channel := &s.channels[i]
+		channel.Status = update.Status
+		channel.CountMinuteUsage = update.CountMinuteUsage
+		channel.CountDayUsage = update.CountDayUsage
+		// 与 MySQLStore 一致：冷却只延长不缩短，只删除已到期的冷却
+		switch {
+		case update.Cooldown != nil:
+			if !channel.DisabledUntil.Valid || channel.DisabledUntil.Int64 < update.Cooldown.Until {
+				channel.CooldownReason = sql.NullString{String: update.Cooldown.Reason, Valid: true}
+				channel.DisabledUntil = sql.NullInt64{Int64: update.Cooldown.Until, Valid: true}
+			}
+		case channel.DisabledUntil.Int64 <= now:
+			channel.CooldownReason = sql.NullString{}
+			channel.DisabledUntil = sql.NullInt64{}
+		}
+	}
+	s.countersUpdatedAt = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) CountersUpdatedAt(ctx context.Context) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.countersUpdatedAt, nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQLStore 基于 newapi 的 MySQL 数据库实现 Store
+type MySQLStore struct {
+	db *sql.DB
+}
+
+// NewMySQLStore 使用已打开的数据库连接创建 Store
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (s *MySQLStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// channelColumns 面板需要的渠道列，key 等敏感信息不会被读取
+const channelColumns = `c.id, c.status, c.count_minute_usage, c.count_day_usage, c.tag,
+	IFNULL(c.name, ''), IFNULL(c.` + "`group`" + `, ''), c.type, IFNULL(c.models, ''),
+	IFNULL(c.priority, 0), IFNULL(c.weight, 0), IFNULL(c.created_time, 0), IFNULL(c.test_time, 0), IFNULL(c.response_time, 0)`
+
+// Channels 查询渠道及其冷却记录
+func (s *MySQLStore) Channels(ctx context.Context) ([]Channel, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+channelColumns+`, cd.reason, cd.disabled_until
+		FROM channels c LEFT JOIN channel_cooldowns cd ON cd.channel_id = c.id`)
+	if isMissingTable(err) {
+		// 冷却表由 install-procedure 创建，尚未安装时所有渠道都没有冷却记录
+		rows, err = s.db.QueryContext(ctx, `SELECT `+channelColumns+`, NULL, NULL FROM channels c`)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []Channel
+	for rows.Next() {
+		var channel Channel
+		if err := rows.Scan(&channel.ID, &channel.Status, &channel.CountMinuteUsage, &channel.CountDayUsage, &channel.Tag,
+			&channel.Name, &channel.Group, &channel.Type, &channel.Models,
+			&channel.Priority, &channel.Weight, &channel.CreatedTime, &channel.TestTime, &channel.ResponseTime,
+			&channel.CooldownReason, &channel.DisabledUntil); err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, rows.Err()
+}
+
+// Usage 直接从 logs 表统计，与存储过程写入的 count_minute_usage/count_day_usage 相互独立
+func (s *MySQLStore) Usage(ctx context.Context, minuteStart, dayStart, now time.Time) (map[int]UsageCounts, error) {
+	since := minuteStart
+	if dayStart.Before(since) {
+		since = dayStart
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT channel_id,
+			IFNULL(SUM(created_at >= ?), 0) AS minute_count,
+			IFNULL(SUM(created_at >= ?), 0) AS day_count,
+			IFNULL(SUM(created_at >= ? AND type = ?), 0) AS day_errors
+		FROM logs
+		WHERE created_at >= ? AND created_at <= ?
+		GROUP BY channel_id`, minuteStart.Unix(), dayStart.Unix(), dayStart.Unix(), logTypeError, since.Unix(), now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make(map[int]UsageCounts)
+	for rows.Next() {
+		var channelID int
+		var counts UsageCounts
+		if err := rows.Scan(&channelID, &counts.Minute, &counts.Day, &counts.DayErrors); err != nil {
+			return nil, err
+		}
+		usage[channelID] = counts
+	}
+	return usage, rows.Err()
+}
+
+// Demand 在数据库中分桶聚合，避免把历史日志逐条取回
+func (s *MySQLStore) Demand(ctx context.Context, since, until time.Time, bucket time.Duration) (map[int64]int, error) {
+	size := int64(bucket / time.Second)
+	rows, err := s.db.QueryContext(ctx, `SELECT FLOOR(created_at / ?) * ? AS bucket, COUNT(*)
+		FROM logs
+		WHERE created_at >= ? AND created_at < ?
+		GROUP BY bucket`, size, size, since.Unix(), until.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	demand := make(map[int64]int)
+	for rows.Next() {
+		var start int64
+		var count int
+		if err := rows.Scan(&start, &count); err != nil {
+			return nil, err
+		}
+		demand[start] = count
+	}
+	return demand, rows.Err()
+}
+
+func (s *MySQLStore) Tokens(ctx context.Context, since, until time.Time, bucket time.Duration) ([]TokenCounts, error) {
+	size := int64(bucket / time.Second)
+	rows, err := s.db.QueryContext(ctx, `SELECT channel_id, model_name,
+			? + FLOOR((created_at - ?) / ?) * ? AS bucket,
+			IFNULL(SUM(prompt_tokens), 0), IFNULL(SUM(completion_tokens), 0)
+		FROM logs
+		WHERE created_at >= ? AND created_at < ?
+		GROUP BY channel_id, model_name, bucket`,
+		since.Unix(), since.Unix(), size, size, since.Unix(), until.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []TokenCounts
+	for rows.Next() {
+		var counts TokenCounts
+		if err := rows.Scan(&counts.ChannelID, &counts.Model, &counts.Bucket, &counts.PromptTokens, &counts.CompletionTokens); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, counts)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *MySQLStore) Consumers(ctx context.Context, since, until time.Time) ([]ConsumerCounts, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT user_id, username, token_name, channel_id, COUNT(*)
+		FROM logs
+		WHERE created_at >= ? AND created_at <= ?
+		GROUP BY user_id, username, token_name, channel_id`, since.Unix(), until.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var consumers []ConsumerCounts
+	for rows.Next() {
+		var counts ConsumerCounts
+		if err := rows.Scan(&counts.UserID, &counts.Username, &counts.TokenName, &counts.ChannelID, &counts.Requests); err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, counts)
+	}
+	return consumers, rows.Err()
+}
+
+// Latencies 返回按秒聚合的直方图，百分位在 Go 中计算
+func (s *MySQLStore) Latencies(ctx context.Context, since, until time.Time) ([]LatencyCounts, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT channel_id, model_name, use_time, COUNT(*)
+		FROM logs
+		WHERE created_at >= ? AND created_at <= ? AND type = ?
+		GROUP BY channel_id, model_name, use_time`, since.Unix(), until.Unix(), logTypeConsume)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var latencies []LatencyCounts
+	for rows.Next() {
+		var counts LatencyCounts
+		if err := rows.Scan(&counts.ChannelID, &counts.Model, &counts.UseTime, &counts.Count); err != nil {
+			return nil, err
+		}
+		latencies = append(latencies, counts)
+	}
+	return latencies, rows.Err()
+}
+
+func (s *MySQLStore) Activity(ctx context.Context, since, until time.Time, bucket time.Duration) ([]ActivityCounts, error) {
+	size := int64(bucket / time.Second)
+	rows, err := s.db.QueryContext(ctx, `SELECT channel_id,
+			? + FLOOR((created_at - ?) / ?) * ? AS bucket,
+			COUNT(*), IFNULL(SUM(type = ?), 0)
+		FROM logs
+		WHERE created_at >= ? AND created_at < ?
+		GROUP BY channel_id, bucket`,
+		since.Unix(), since.Unix(), size, size, logTypeError, since.Unix(), until.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activity []ActivityCounts
+	for rows.Next() {
+		var counts ActivityCounts
+		if err := rows.Scan(&counts.ChannelID, &counts.Bucket, &counts.Requests, &counts.Errors); err != nil {
+			return nil, err
+		}
+		activity = append(activity, counts)
+	}
+	return activity, rows.Err()
+}
+
+// DisableChannels 与 UpdateChannels 一样在事务中写入，冷却记录只延长不缩短
+func (s *MySQLStore) DisableChannels(ctx context.Context, ids []int, cooldown Cooldown) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, `UPDATE channels SET status = 2 WHERE id = ?`, id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO channel_cooldowns (channel_id, reason, disabled_until, updated_at)
+			VALUES (?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+				reason = IF(VALUES(disabled_until) > disabled_until, VALUES(reason), reason),
+				disabled_until = GREATEST(disabled_until, VALUES(disabled_until)),
+				updated_at = VALUES(updated_at)`,
+			id, cooldown.Reason, cooldown.Until, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UpdateChannels 在一个事务中写回所有渠道，避免页面读到一半新一半旧的状态
+func (s *MySQLStore) UpdateChannels(ctx context.Context, updates []ChannelUpdate) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	for _, update := range updates {
+		if _, err := tx.ExecContext(ctx, `UPDATE channels
+			SET status = ?, count_minute_usage = ?, count_day_usage = ?, weight = ?
+			WHERE id = ?`,
+			update.Status, update.CountMinuteUsage, update.CountDayUsage, update.Weight, update.ChannelID); err != nil {
+			return err
+		}
+		// 读取渠道之后 DisableChannels 或存储过程可能写入了更长的冷却：
+		// 与存储过程一致只延长不缩短，删除时也只删除已到期的记录
+		if update.Cooldown == nil {
+			_, err = tx.ExecContext(ctx, `DELETE FROM channel_cooldowns WHERE channel_id = ? AND disabled_until <= ?`, update.ChannelID, now)
+		} else {
+			_, err = tx.ExecContext(ctx, `INSERT INTO channel_cooldowns (channel_id, reason, disabled_until, updated_at)
+				VALUES (?, ?, ?, ?)
+				ON DUPLICATE KEY UPDATE
+					reason = IF(VALUES(disabled_until) > disabled_until, VALUES(reason), reason),
+					disabled_until = GREATEST(disabled_until, VALUES(disabled_until)),
+					updated_at = VALUES(updated_at)`,
+				update.ChannelID, update.Cooldown.Reason, update.Cooldown.Until, now)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// 心跳表由 install-procedure 创建，旧版本安装的数据库中没有时跳过
+	if _, err := tx.ExecContext(ctx, `INSERT INTO channel_stats_heartbeat (id, updated_at) VALUES (1, ?)
+		ON DUPLICATE KEY UPDATE updated_at = VALUES(updated_at)`, now); err != nil && !isMissingTable(err) {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *MySQLStore) CountersUpdatedAt(ctx context.Context) (time.Time, error) {
+	var updatedAt int64
+	err := s.db.QueryRowContext(ctx, `SELECT updated_at FROM channel_stats_heartbeat WHERE id = 1`).Scan(&updatedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) || isMissingTable(err):
+		// 尚未运行过，或存储过程是没有心跳表的旧版本
+		return time.Time{}, nil
+	case err != nil:
+		return time.Time{}, err
+	}
+	return time.Unix(updatedAt, 0), nil
+}
+
+// isMissingTable 判断是否为表不存在的错误（ER_NO_SUCH_TABLE）
+func isMissingTable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1146
+}
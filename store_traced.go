@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedStore 为每次查询创建 span 并在 DEBUG 级别记录耗时，
+// 日志带有发起查询的请求 ID，便于找到拖慢页面的查询
+type tracedStore struct {
+	store Store
+}
+
+// traceStore 包装 store，记录每次查询的 span 和耗时
+func traceStore(store Store) Store {
+	return &tracedStore{store: store}
+}
+
+// traced 在名为 db.<name> 的 span 中执行 query
+func traced[T any](ctx context.Context, name string, query func(ctx context.Context) (T, error)) (T, error) {
+	ctx, span := tracer.Start(ctx, "db."+name, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "mysql"), attribute.String("db.operation", name)))
+	start := time.Now()
+	result, err := query(ctx)
+	endSpan(span, err)
+	attrs := []any{"query", name, "duration_ms", time.Since(start).Milliseconds()}
+	if err != nil {
+		attrs = append(attrs, "err", err)
+	}
+	slog.DebugContext(ctx, "数据库查询", attrs...)
+	return result, err
+}
+
+// tracedExec 与 traced 相同，用于只返回错误的操作
+func tracedExec(ctx context.Context, name string, exec func(ctx context.Context) error) error {
+	_, err := traced(ctx, name, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, exec(ctx)
+	})
+	return err
+}
+
+func (s *tracedStore) Ping(ctx context.Context) error {
+	return tracedExec(ctx, "Ping", s.store.Ping)
+}
+
+func (s *tracedStore) Channels(ctx context.Context) ([]Channel, error) {
+	return traced(ctx, "Channels", s.store.Channels)
+}
+
+func (s *tracedStore) Usage(ctx context.Context, minuteStart, dayStart, now time.Time) (map[int]UsageCounts, error) {
+	return traced(ctx, "Usage", func(ctx context.Context) (map[int]UsageCounts, error) {
+		return s.store.Usage(ctx, minuteStart, dayStart, now)
+	})
+}
+
+func (s *tracedStore) Demand(ctx context.Context, since, until time.Time, bucket time.Duration) (map[int64]int, error) {
+	return traced(ctx, "Demand", func(ctx context.Context) (map[int64]int, error) {
+		return s.store.Demand(ctx, since, until, bucket)
+	})
+}
+
+func (s *tracedStore) Tokens(ctx context.Context, since, until time.Time, bucket time.Duration) ([]TokenCounts, error) {
+	return traced(ctx, "Tokens", func(ctx context.Context) ([]TokenCounts, error) {
+		return s.store.Tokens(ctx, since, until, bucket)
+	})
+}
+
+func (s *tracedStore) Consumers(ctx context.Context, since, until time.Time) ([]ConsumerCounts, error) {
+	return traced(ctx, "Consumers", func(ctx context.Context) ([]ConsumerCounts, error) {
+		return s.store.Consumers(ctx, since, until)
+	})
+}
+
+func (s *tracedStore) Latencies(ctx context.Context, since, until time.Time) ([]LatencyCounts, error) {
+	return traced(ctx, "Latencies", func(ctx context.Context) ([]LatencyCounts, error) {
+		return s.store.Latencies(ctx, since, until)
+	})
+}
+
+func (s *tracedStore) Activity(ctx context.Context, since, until time.Time, bucket time.Duration) ([]ActivityCounts, error) {
+	return traced(ctx, "Activity", func(ctx context.Context) ([]ActivityCounts, error) {
+		return s.store.Activity(ctx, since, until, bucket)
+	})
+}
+
+func (s *tracedStore) DisableChannels(ctx context.Context, ids []int, cooldown Cooldown) error {
+	return tracedExec(ctx, "DisableChannels", func(ctx context.Context) error {
+		return s.store.DisableChannels(ctx, ids, cooldown)
+	})
+}
+
+func (s *tracedStore) UpdateChannels(ctx context.Context, updates []ChannelUpdate) error {
+	return tracedExec(ctx, "UpdateChannels", func(ctx context.Context) error {
+		return s.store.UpdateChannels(ctx, updates)
+	})
+}
+
+func (s *tracedStore) CountersUpdatedAt(ctx context.Context) (time.Time, error) {
+	return traced(ctx, "CountersUpdatedAt", s.store.CountersUpdatedAt)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer 用于本程序创建的所有 span，未启用链路追踪时为 no-op 实现
+var tracer = otel.Tracer("gemini-monitor")
+
+// TracingConfig 链路追踪的配置
+type TracingConfig struct {
+	Endpoint string // OTLP/HTTP 地址，为空时不启用
+}
+
+// setupTracing 配置了 OTLP 地址时把 span 批量导出到该地址，返回的函数在退出前导出剩余的 span。
+// 导出器的其他参数（请求头、超时、采样等）由 OTEL_* 标准环境变量设置
+func setupTracing(ctx context.Context, config TracingConfig) (func(context.Context) error, error) {
+	if config.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("创建 OTLP 导出器失败: %w", err)
+	}
+	commit, _ := buildInfo()
+	// OTEL_SERVICE_NAME、OTEL_RESOURCE_ATTRIBUTES 可以覆盖默认的服务名
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attribute.String("service.name", "gemini-monitor"), attribute.String("service.version", commit)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("读取链路追踪的资源属性失败: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Warn("导出链路追踪失败", "err", err)
+	}))
+	return provider.Shutdown, nil
+}
+
+// endSpan 记录错误并结束 span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
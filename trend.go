@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 趋势按分钟统计最近一小时的请求数，用于速率、趋势箭头和迷你折线图
+const (
+	trendSpan   = time.Hour
+	trendBucket = time.Minute
+)
+
+// 请求速率的变化方向
+const (
+	TrendUp   = "up"
+	TrendDown = "down"
+	TrendFlat = "flat"
+)
+
+// trendNames 变化方向的中文显示名，同时是翻译的原文
+var trendNames = map[string]string{TrendUp: "上升", TrendDown: "下降", TrendFlat: "平稳"}
+
+// 最近 5 分钟的速率比最近 15 分钟高出（或低出）超过 trendTolerance 且至少相差 trendMinDelta 次/分钟时，
+// 才认为在上升（或下降），避免请求很少时箭头来回跳动
+const (
+	trendTolerance = 0.2
+	trendMinDelta  = 0.5
+)
+
+// UsageTrend 最近一小时的请求趋势
+type UsageTrend struct {
+	Minutes   []int   `json:"minutes"`   // 最近一小时每分钟的请求数，最后一个为最近一分钟
+	Rate5m    float64 `json:"rate_5m"`   // 最近 5 分钟平均每分钟的请求数
+	Rate15m   float64 `json:"rate_15m"`  // 最近 15 分钟平均每分钟的请求数
+	Direction string  `json:"direction"` // 最近 5 分钟相对最近 15 分钟的变化方向
+}
+
+// newUsageTrend 根据每分钟的请求数计算速率和变化方向
+func newUsageTrend(minutes []int) *UsageTrend {
+	trend := &UsageTrend{
+		Minutes: minutes,
+		Rate5m:  averageRate(minutes, 5),
+		Rate15m: averageRate(minutes, 15),
+	}
+	trend.Direction = trendDirection(trend.Rate5m, trend.Rate15m)
+	return trend
+}
+
+// averageRate 返回最后 n 分钟平均每分钟的请求数
+func averageRate(minutes []int, n int) float64 {
+	n = min(n, len(minutes))
+	if n == 0 {
+		return 0
+	}
+	total := 0
+	for _, count := range minutes[len(minutes)-n:] {
+		total += count
+	}
+	return float64(total) / float64(n)
+}
+
+// trendDirection 比较近期速率 recent 和基准速率 baseline
+func trendDirection(recent, baseline float64) string {
+	switch {
+	case recent-baseline >= trendMinDelta && recent > baseline*(1+trendTolerance):
+		return TrendUp
+	case baseline-recent >= trendMinDelta && recent < baseline*(1-trendTolerance):
+		return TrendDown
+	default:
+		return TrendFlat
+	}
+}
+
+// Arrow 返回表示变化方向的箭头
+func (t *UsageTrend) Arrow() string {
+	switch t.Direction {
+	case TrendUp:
+		return "↑"
+	case TrendDown:
+		return "↓"
+	default:
+		return "→"
+	}
+}
+
+// DirectionDisplay 返回变化方向的中文显示名
+func (t *UsageTrend) DirectionDisplay() string {
+	return trendNames[t.Direction]
+}
+
+// 迷你折线图的坐标范围，页面中的 svg 使用相同的 viewBox
+const (
+	sparklineWidth  = 100
+	sparklineHeight = 20
+)
+
+// SparklinePoints 返回迷你折线图 polyline 的 points 属性，纵轴按最大值缩放，上下各留 1 个单位
+func (t *UsageTrend) SparklinePoints() string {
+	if len(t.Minutes) < 2 {
+		return ""
+	}
+	peak := 0
+	for _, count := range t.Minutes {
+		peak = max(peak, count)
+	}
+	points := make([]string, len(t.Minutes))
+	for i, count := range t.Minutes {
+		x := float64(i) * sparklineWidth / float64(len(t.Minutes)-1)
+		y := float64(sparklineHeight - 1)
+		if peak > 0 {
+			y -= float64(count) / float64(peak) * (sparklineHeight - 2)
+		}
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	return strings.Join(points, " ")
+}
+
+// sumTrends 把一组渠道的趋势按分钟相加，用于总使用情况和分组小计，没有渠道带趋势时返回 nil
+func sumTrends(channels []ChannelView) *UsageTrend {
+	var minutes []int
+	for _, view := range channels {
+		if view.Trend == nil {
+			continue
+		}
+		if minutes == nil {
+			minutes = make([]int, len(view.Trend.Minutes))
+		}
+		for i, count := range view.Trend.Minutes {
+			minutes[i] += count
+		}
+	}
+	if minutes == nil {
+		return nil
+	}
+	return newUsageTrend(minutes)
+}
+
+// minuteBuckets 把 since 起按分钟分桶的请求数整理为每个渠道最近一小时每分钟的请求数，key 0 为所有渠道之和
+func minuteBuckets(activity []ActivityCounts, since time.Time) map[int][]int {
+	buckets := int(trendSpan / trendBucket)
+	byChannel := map[int][]int{0: make([]int, buckets)}
+	for _, counts := range activity {
+		i := int((counts.Bucket - since.Unix()) / int64(trendBucket/time.Second))
+		if i < 0 || i >= buckets {
+			continue
+		}
+		byChannel[0][i] += counts.Requests
+		if counts.ChannelID == 0 {
+			continue // newapi 未选中渠道的请求只计入总数
+		}
+		if byChannel[counts.ChannelID] == nil {
+			byChannel[counts.ChannelID] = make([]int, buckets)
+		}
+		byChannel[counts.ChannelID][i] += counts.Requests
+	}
+	return byChannel
+}
+
+// applyTrends 把 since 起按分钟分桶的请求数填入面板数据，没有请求的渠道趋势为全 0
+func applyTrends(snapshot *Snapshot, activity []ActivityCounts, since time.Time) {
+	byChannel := minuteBuckets(activity, since)
+	for i := range snapshot.Channels {
+		view := &snapshot.Channels[i]
+		minutes := byChannel[view.ID]
+		if minutes == nil {
+			minutes = make([]int, len(byChannel[0]))
+		}
+		view.Trend = newUsageTrend(minutes)
+	}
+	snapshot.Summary.Trend = sumTrends(snapshot.Channels)
+}
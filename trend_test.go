@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewUsageTrend(t *testing.T) {
+	tests := []struct {
+		name    string
+		minutes []int
+		rate5m  float64
+		rate15m float64
+		want    string
+	}{
+		{"没有请求", make([]int, 60), 0, 0, TrendFlat},
+		{"请求增多", append(make([]int, 55), 3, 3, 3, 3, 3), 3, 1, TrendUp},
+		{"请求减少", append(repeat(2, 50), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0), 0, 2.0 / 3, TrendDown},
+		{"平稳", repeat(4, 60), 4, 4, TrendFlat},
+		// 请求很少时，相对变化再大也不认为在上升
+		{"少量请求", append(make([]int, 59), 1), 0.2, 1.0 / 15, TrendFlat},
+		{"不足 15 分钟", []int{1, 2, 3}, 2, 2, TrendFlat},
+	}
+	for _, tt := range tests {
+		trend := newUsageTrend(tt.minutes)
+		if !closeTo(trend.Rate5m, tt.rate5m) || !closeTo(trend.Rate15m, tt.rate15m) {
+			t.Errorf("%s: rates = %.3f / %.3f, want %.3f / %.3f", tt.name, trend.Rate5m, trend.Rate15m, tt.rate5m, tt.rate15m)
+		}
+		if trend.Direction != tt.want {
+			t.Errorf("%s: direction = %s, want %s", tt.name, trend.Direction, tt.want)
+		}
+	}
+}
+
+func TestSparklinePoints(t *testing.T) {
+	tests := []struct {
+		minutes []int
+		want    string
+	}{
+		{[]int{0, 5, 10}, "0.0,19.0 50.0,10.0 100.0,1.0"},
+		{[]int{0, 0}, "0.0,19.0 100.0,19.0"},
+		{[]int{3}, ""},
+	}
+	for _, tt := range tests {
+		if got := newUsageTrend(tt.minutes).SparklinePoints(); got != tt.want {
+			t.Errorf("SparklinePoints(%v) = %q, want %q", tt.minutes, got, tt.want)
+		}
+	}
+}
+
+func TestApplyTrends(t *testing.T) {
+	snapshot := buildSnapshot([]Channel{{ID: 1, Status: "1"}, {ID: 2, Status: "1"}}, nil, testQuota, testNow)
+	since := testNow.Add(-trendSpan)
+	minute := int64(time.Minute / time.Second)
+	applyTrends(snapshot, []ActivityCounts{
+		{ChannelID: 1, Bucket: since.Unix(), Requests: 1},
+		{ChannelID: 1, Bucket: since.Unix() + 59*minute, Requests: 5},
+		{ChannelID: 2, Bucket: since.Unix() + 59*minute, Requests: 2},
+		{ChannelID: 2, Bucket: since.Unix() + 60*minute, Requests: 9}, // 超出范围，忽略
+		{ChannelID: 99, Bucket: since.Unix(), Requests: 7},            // 不存在的渠道，忽略
+	}, since)
+
+	byID := make(map[int]*UsageTrend)
+	for _, view := range snapshot.Channels {
+		if len(view.Trend.Minutes) != 60 {
+			t.Fatalf("channel %d: %d 个分钟桶, want 60", view.ID, len(view.Trend.Minutes))
+		}
+		byID[view.ID] = view.Trend
+	}
+	if got := byID[1].Minutes; got[0] != 1 || got[59] != 5 {
+		t.Errorf("channel 1 minutes = %v", got)
+	}
+	if got := byID[2].Rate5m; got != 0.4 {
+		t.Errorf("channel 2 Rate5m = %v, want 0.4", got)
+	}
+	summary := snapshot.Summary.Trend
+	if summary == nil || summary.Minutes[0] != 1 || summary.Minutes[59] != 7 {
+		t.Errorf("summary trend = %+v, want minutes[0] = 1, minutes[59] = 7", summary)
+	}
+}
+
+func repeat(count, n int) []int {
+	minutes := make([]int, n)
+	for i := range minutes {
+		minutes[i] = count
+	}
+	return minutes
+}
+
+func closeTo(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// 终端界面的筛选和排序选项
+const (
+	statusAll = iota
+	statusAvailable
+	statusDisabled // 包括冷却中，与网页上的“自动禁用”筛选一致
+	statusCooling
+)
+
+const (
+	tierAll = iota
+	tierPaid
+	tierNormal
+)
+
+const (
+	sortDefault = iota // 与网页相同：付费号在前，再按天、分钟使用率
+	sortID
+	sortMinute
+	sortDay
+)
+
+var (
+	statusFilterNames = []string{"全部", "可用", "自动禁用", "冷却中"}
+	tierFilterNames   = []string{"全部", "付费号", "普号"}
+	sortKeyNames      = []string{"默认", "ID", "分钟使用率", "天使用率"}
+)
+
+// tuiState 保存终端界面的交互状态
+type tuiState struct {
+	statusFilter int
+	tierFilter   int
+	sortKey      int
+	descending   bool
+	search       string // 已确认的搜索词，匹配 ID、名称、模型等
+	searching    bool   // 正在输入搜索词
+	input        string // 输入中的搜索词
+	offset       int    // 滚动偏移（行）
+}
+
+// apply 按当前的筛选、搜索和排序条件返回要显示的渠道
+func (s tuiState) apply(channels []ChannelView) []ChannelView {
+	search := s.search
+	if s.searching {
+		search = s.input
+	}
+
+	var result []ChannelView
+	for _, view := range channels {
+		switch s.statusFilter {
+		case statusAvailable:
+			if !view.IsAvailable {
+				continue
+			}
+		case statusDisabled:
+			if view.IsAvailable {
+				continue
+			}
+		case statusCooling:
+			if !view.IsCoolingDown {
+				continue
+			}
+		}
+		if (s.tierFilter == tierPaid && !view.IsPaid) || (s.tierFilter == tierNormal && view.IsPaid) {
+			continue
+		}
+		if !view.MatchesSearch(search) {
+			continue
+		}
+		result = append(result, view)
+	}
+
+	// 采集结果已按默认规则排好序，默认排序只需要处理倒序
+	if s.sortKey != sortDefault {
+		sort.SliceStable(result, func(i, j int) bool {
+			switch s.sortKey {
+			case sortMinute:
+				return result[i].MinutePercentage < result[j].MinutePercentage
+			case sortDay:
+				return result[i].DayPercentage < result[j].DayPercentage
+			default:
+				return result[i].ID < result[j].ID
+			}
+		})
+	}
+	if s.descending {
+		for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+			result[i], result[j] = result[j], result[i]
+		}
+	}
+	return result
+}
+
+// 特殊按键
+const (
+	keyUp       = "up"
+	keyDown     = "down"
+	keyPageUp   = "pgup"
+	keyPageDown = "pgdn"
+	keyEnter    = "enter"
+	keyEscape   = "esc"
+	keyBack     = "backspace"
+	keyCtrlC    = "ctrl-c"
+)
+
+// handleKey 根据按键更新状态，pageSize 为一页可显示的行数，返回是否退出和是否需要立即刷新数据
+func (s tuiState) handleKey(key string, pageSize int) (next tuiState, quit, refresh bool) {
+	if key == keyCtrlC {
+		return s, true, false
+	}
+	if s.searching {
+		switch key {
+		case keyEnter:
+			s.search, s.searching = s.input, false
+		case keyEscape:
+			s.searching = false
+		case keyBack:
+			if s.input != "" {
+				s.input = s.input[:len(s.input)-1]
+			}
+		default:
+			if len(key) == 1 && key[0] >= '0' && key[0] <= '9' {
+				s.input += key
+			}
+		}
+		s.offset = 0
+		return s, false, false
+	}
+
+	switch key {
+	case "q":
+		return s, true, false
+	case "f":
+		s.statusFilter = (s.statusFilter + 1) % len(statusFilterNames)
+		s.offset = 0
+	case "t":
+		s.tierFilter = (s.tierFilter + 1) % len(tierFilterNames)
+		s.offset = 0
+	case "s":
+		s.sortKey = (s.sortKey + 1) % len(sortKeyNames)
+	case "o":
+		s.descending = !s.descending
+	case "/":
+		s.searching, s.input = true, s.search
+	case keyEscape:
+		s.search = ""
+		s.offset = 0
+	case "r":
+		return s, false, true
+	case keyUp, "k":
+		s.offset--
+	case keyDown, "j":
+		s.offset++
+	case keyPageUp:
+		s.offset -= pageSize
+	case keyPageDown, " ":
+		s.offset += pageSize
+	}
+	if s.offset < 0 {
+		s.offset = 0
+	}
+	return s, false, false
+}
+
+// ANSI 颜色
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+)
+
+// levelColors 使用率级别对应的终端颜色，阈值与网页进度条相同
+var levelColors = map[string]string{
+	LevelOK:       ansiGreen,
+	LevelWarn:     ansiYellow,
+	LevelCritical: ansiRed,
+}
+
+// bar 绘制宽度为 width 的进度条，颜色由 thresholds 决定
+func bar(percentage float64, width int, thresholds UsageThresholds) string {
+	filled := int(percentage/100*float64(width) + 0.5)
+	if filled > width {
+		filled = width
+	}
+	return levelColors[thresholds.Level(percentage)] + strings.Repeat("█", filled) + ansiDim + strings.Repeat("░", width-filled) + ansiReset
+}
+
+// displayWidth 计算字符串在终端中的显示宽度，中文等全角字符占两列，忽略 ANSI 转义序列
+func displayWidth(s string) int {
+	width := 0
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case inEscape:
+			if r == 'm' {
+				inEscape = false
+			}
+		case r == '\x1b':
+			inEscape = true
+		case r >= 0x1100 && r <= 0x115F, r >= 0x2E80 && r <= 0xA4CF, r >= 0xAC00 && r <= 0xD7A3,
+			r >= 0xF900 && r <= 0xFAFF, r >= 0xFE30 && r <= 0xFE4F, r >= 0xFF00 && r <= 0xFF60, r >= 0xFFE0 && r <= 0xFFE6:
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+// padRight 按显示宽度在右侧补齐空格
+func padRight(s string, width int) string {
+	if w := displayWidth(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
+
+// 每个渠道单元格的显示宽度
+const tuiCellWidth = 66
+
+// renderChannelCell 渲染单个渠道，宽度固定为 tuiCellWidth
+func renderChannelCell(view ChannelView, thresholds UsageThresholds) string {
+	status := ansiGreen + view.StatusDisplay + ansiReset
+	if view.IsCoolingDown {
+		status = ansiYellow + view.StatusDisplay + " " + formatRemaining(view.CooldownSeconds).String() + ansiReset
+	} else if !view.IsAvailable {
+		status = ansiRed + view.StatusDisplay + ansiReset
+	}
+	tier := view.TagDisplay
+	if view.IsPaid {
+		tier = ansiBlue + tier + ansiReset
+	}
+	cell := fmt.Sprintf("%s %s %s 分%s%3d/%-3d 天%s%3d/%-3d",
+		padRight(ansiBold+"#"+strconv.Itoa(view.ID)+ansiReset, 6),
+		padRight(tier, 6),
+		padRight(status, 16),
+		bar(view.MinutePercentage, 8, thresholds), view.CountMinuteUsage, view.MinuteLimit,
+		bar(view.DayPercentage, 8, thresholds), view.CountDayUsage, view.DayLimit)
+	return padRight(cell, tuiCellWidth)
+}
+
+// renderTUI 将快照按当前状态渲染为一屏文本，返回实际使用的偏移（滚动到底时会被修正）
+func renderTUI(w io.Writer, snapshot *Snapshot, state tuiState, thresholds UsageThresholds, source string, width, height int) int {
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString("\x1b[K\n") // 清除行尾的旧内容
+	}
+
+	collected := "尚未采集"
+	if snapshot != nil {
+		collected = "采集于 " + snapshot.CollectedAt.Format("15:04:05")
+	}
+	line("%sGemini 监控%s  %s  来源: %s", ansiBold, ansiReset, collected, source)
+
+	search := state.search
+	if state.searching {
+		search = state.input + "▏"
+	}
+	order := "↑"
+	if state.descending {
+		order = "↓"
+	}
+	line("%s[f]状态:%s  [t]类型:%s  [s]排序:%s%s [o]  [/]搜索:%s  [r]刷新  [q]退出%s",
+		ansiDim, statusFilterNames[state.statusFilter], tierFilterNames[state.tierFilter],
+		sortKeyNames[state.sortKey], order, search, ansiReset)
+	line("")
+
+	if snapshot == nil {
+		line("正在加载数据...")
+		io.WriteString(w, b.String())
+		return state.offset
+	}
+
+	summary := snapshot.Summary
+	summaryBar := func(label string, used, total int, percentage float64) {
+		line("%s %s %5.1f%%  %d / %d", padRight(label, 32), bar(percentage, 30, thresholds), percentage, used, total)
+	}
+	summaryBar(snapshot.MinuteWindowLabel.String()+"总使用次数", summary.TotalMinuteUsage, summary.TotalMinuteLimit, summary.MinutePercentage)
+	summaryBar(snapshot.DayWindowLabel.String()+"总使用次数", summary.TotalDayUsage, summary.TotalDayLimit, summary.DayPercentage)
+	summaryBar("自动禁用普号数", summary.DisabledNormalChannels, summary.TotalNormalChannels, summary.DisabledNormalPercentage)
+	line("")
+
+	channels := state.apply(snapshot.Channels)
+	columns := width / (tuiCellWidth + 2)
+	if columns < 1 {
+		columns = 1
+	}
+	rows := (len(channels) + columns - 1) / columns
+	pageSize := tuiPageSize(height)
+	offset := state.offset
+	if offset > rows-pageSize {
+		offset = rows - pageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	for row := offset; row < rows && row < offset+pageSize; row++ {
+		var cells []string
+		for col := 0; col < columns; col++ {
+			if i := row*columns + col; i < len(channels) {
+				cells = append(cells, renderChannelCell(channels[i], thresholds))
+			}
+		}
+		line("%s", strings.Join(cells, "  "))
+	}
+	line("")
+	line("%s显示 %d 个渠道中的 %d 个，第 %d-%d 行 / 共 %d 行%s", ansiDim, len(snapshot.Channels), len(channels),
+		min(offset+1, rows), min(offset+pageSize, rows), rows, ansiReset)
+	b.WriteString("\x1b[J") // 清除屏幕剩余部分
+
+	io.WriteString(w, b.String())
+	return offset
+}
+
+// tuiPageSize 返回渠道列表可用的行数：除去标题、摘要和页脚共 10 行
+func tuiPageSize(height int) int {
+	if height-10 < 1 {
+		return 1
+	}
+	return height - 10
+}
+
+// snapshotSource 获取最新的面板数据
+type snapshotSource func(ctx context.Context) (*Snapshot, error)
+
+// remoteSource 从另一个监控实例的 /api/snapshot 接口获取数据
+func remoteSource(baseURL string) snapshotSource {
+	client := &http.Client{Timeout: 10 * time.Second}
+	url := strings.TrimRight(baseURL, "/") + "/api/snapshot"
+	return func(ctx context.Context) (*Snapshot, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s 返回 %s", url, resp.Status)
+		}
+		var snapshot Snapshot
+		if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+			return nil, fmt.Errorf("解析 %s 的响应失败: %w", url, err)
+		}
+		return &snapshot, nil
+	}
+}
+
+// readKeys 从终端读取按键并转换为 handleKey 能识别的名称
+func readKeys(r io.Reader, keys chan<- string) {
+	reader := bufio.NewReader(r)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			close(keys)
+			return
+		}
+		switch b {
+		case 3:
+			keys <- keyCtrlC
+		case '\r', '\n':
+			keys <- keyEnter
+		case 127, 8:
+			keys <- keyBack
+		case 0x1b:
+			// 方向键和翻页键以 ESC [ 开头，单独的 ESC 后面不会紧跟其他字节
+			if reader.Buffered() == 0 {
+				keys <- keyEscape
+				continue
+			}
+			seq := []byte{}
+			for reader.Buffered() > 0 {
+				c, _ := reader.ReadByte()
+				seq = append(seq, c)
+				if c >= 'A' && c <= 'Z' || c == '~' {
+					break
+				}
+			}
+			switch string(seq) {
+			case "[A":
+				keys <- keyUp
+			case "[B":
+				keys <- keyDown
+			case "[5~":
+				keys <- keyPageUp
+			case "[6~":
+				keys <- keyPageDown
+			}
+		default:
+			keys <- string(b)
+		}
+	}
+}
+
+// runTUI 在终端中显示与网页相同的总使用情况和渠道列表
+func runTUI(cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	remote := flags.String("remote", "", "从远程监控实例读取数据，例如 http://10.0.0.5:8080；为空时直接连接数据库")
+	interval := flags.Duration("interval", 5*time.Second, "数据刷新间隔")
+	flags.Parse(args)
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("tui 需要在终端中运行")
+	}
+
+	var fetch snapshotSource
+	source := *remote
+	if *remote != "" {
+		fetch = remoteSource(*remote)
+	} else {
+		db, err := openDB(cfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		collector := NewCollector(NewMySQLStore(db), cfg.Quota, cfg.Location, cfg.CollectInterval)
+		fetch = collector.Refresh
+		source = fmt.Sprintf("%s:%s/%s", cfg.DBHost, cfg.DBPort, cfg.DBName)
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	out := os.Stdout
+	io.WriteString(out, "\x1b[?1049h\x1b[?25l") // 切换到备用屏幕并隐藏光标
+	defer func() {
+		io.WriteString(out, "\x1b[?25h\x1b[?1049l")
+		term.Restore(fd, oldState)
+	}()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+
+	type fetchResult struct {
+		snapshot *Snapshot
+		err      error
+	}
+	results := make(chan fetchResult, 1)
+	fetching := false
+	startFetch := func() {
+		if fetching {
+			return
+		}
+		fetching = true
+		go func() {
+			snapshot, err := fetch(ctx)
+			results <- fetchResult{snapshot, err}
+		}()
+	}
+
+	var snapshot *Snapshot
+	var lastErr error
+	state := tuiState{}
+	refreshTicker := time.NewTicker(*interval)
+	defer refreshTicker.Stop()
+	// 每秒重绘一次，用于更新冷却倒计时和适应终端大小变化
+	redrawTicker := time.NewTicker(time.Second)
+	defer redrawTicker.Stop()
+
+	draw := func() {
+		width, height, err := term.GetSize(int(out.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		var buf bytes.Buffer
+		buf.WriteString("\x1b[H")
+		state.offset = renderTUI(&buf, snapshot, state, cfg.UI.Thresholds, source, width, height)
+		if lastErr != nil {
+			fmt.Fprintf(&buf, "%s刷新失败: %v%s\x1b[K", ansiRed, lastErr, ansiReset)
+		}
+		// 原始模式下换行不会自动回到行首
+		out.Write(bytes.ReplaceAll(buf.Bytes(), []byte("\n"), []byte("\r\n")))
+	}
+
+	startFetch()
+	draw()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			_, height, _ := term.GetSize(int(out.Fd()))
+			var quit, refresh bool
+			state, quit, refresh = state.handleKey(key, tuiPageSize(height))
+			if quit {
+				return nil
+			}
+			if refresh {
+				startFetch()
+			}
+		case result := <-results:
+			fetching = false
+			lastErr = result.err
+			if result.err == nil {
+				snapshot = result.snapshot
+			}
+		case <-refreshTicker.C:
+			startFetch()
+		case <-redrawTicker.C:
+		}
+		draw()
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func tuiChannels() []ChannelView {
+	// 已按默认规则排序：付费号在前，再按天使用率升序
+	return []ChannelView{
+		{ID: 7, IsPaid: true, IsAvailable: true, MinutePercentage: 50, DayPercentage: 10},
+		{ID: 12, IsAvailable: true, MinutePercentage: 20, DayPercentage: 40},
+		{ID: 3, IsCoolingDown: true, MinutePercentage: 100, DayPercentage: 60},
+		{ID: 21, MinutePercentage: 0, DayPercentage: 100},
+	}
+}
+
+func channelIDs(views []ChannelView) []int {
+	ids := []int{}
+	for _, view := range views {
+		ids = append(ids, view.ID)
+	}
+	return ids
+}
+
+func TestTUIStateApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		state tuiState
+		want  []int
+	}{
+		{"默认", tuiState{}, []int{7, 12, 3, 21}},
+		{"可用", tuiState{statusFilter: statusAvailable}, []int{7, 12}},
+		{"自动禁用包括冷却中", tuiState{statusFilter: statusDisabled}, []int{3, 21}},
+		{"冷却中", tuiState{statusFilter: statusCooling}, []int{3}},
+		{"付费号", tuiState{tierFilter: tierPaid}, []int{7}},
+		{"普号", tuiState{tierFilter: tierNormal}, []int{12, 3, 21}},
+		{"搜索", tuiState{search: "2"}, []int{12, 21}},
+		{"输入中的搜索词", tuiState{search: "2", searching: true, input: "3"}, []int{3}},
+		{"按 ID 排序", tuiState{sortKey: sortID}, []int{3, 7, 12, 21}},
+		{"按分钟使用率倒序", tuiState{sortKey: sortMinute, descending: true}, []int{3, 7, 12, 21}},
+		{"默认排序倒序", tuiState{descending: true}, []int{21, 3, 12, 7}},
+		{"按天使用率排序并筛选", tuiState{sortKey: sortDay, tierFilter: tierNormal, descending: true}, []int{21, 3, 12}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := channelIDs(tt.state.apply(tuiChannels())); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("apply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTUIStateHandleKey(t *testing.T) {
+	state := tuiState{}
+	press := func(keys ...string) (quit, refresh bool) {
+		for _, key := range keys {
+			state, quit, refresh = state.handleKey(key, 10)
+		}
+		return quit, refresh
+	}
+
+	press("f", "f", "t")
+	if state.statusFilter != statusDisabled || state.tierFilter != tierPaid {
+		t.Errorf("筛选 = %d/%d, want %d/%d", state.statusFilter, state.tierFilter, statusDisabled, tierPaid)
+	}
+
+	// 搜索模式下只接受数字，字母不会被当作快捷键
+	press("/", "1", "q", "2", keyBack, "5", keyEnter)
+	if state.search != "15" || state.searching {
+		t.Errorf("search = %q, searching = %v", state.search, state.searching)
+	}
+	press("/", "9", keyEscape)
+	if state.search != "15" {
+		t.Errorf("取消输入后 search = %q, want 15", state.search)
+	}
+	press(keyEscape)
+	if state.search != "" {
+		t.Errorf("Esc 后 search = %q, want 空", state.search)
+	}
+
+	press(keyPageDown, keyDown, "k", "k")
+	if state.offset != 9 {
+		t.Errorf("offset = %d, want 9", state.offset)
+	}
+	press(keyPageUp)
+	if state.offset != 0 {
+		t.Errorf("offset = %d, want 0", state.offset)
+	}
+
+	if _, refresh := press("r"); !refresh {
+		t.Error("r 应当触发刷新")
+	}
+	if quit, _ := press("q"); !quit {
+		t.Error("q 应当退出")
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	tests := map[string]int{
+		"#12":                                    3,
+		"付费号":                                    6,
+		ansiRed + "自动禁用" + ansiReset:             8,
+		bar(50, 8, defaultUIConfig().Thresholds): 8,
+	}
+	for s, want := range tests {
+		if got := displayWidth(s); got != want {
+			t.Errorf("displayWidth(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+func TestRenderTUI(t *testing.T) {
+	snapshot := goldenSnapshot()
+	var b strings.Builder
+	offset := renderTUI(&b, snapshot, tuiState{offset: 100}, defaultUIConfig().Thresholds, "test", 80, 24)
+	if offset != 0 {
+		t.Errorf("渠道不足一页时 offset = %d, want 0", offset)
+	}
+	out := b.String()
+	for _, want := range []string{"采集于", snapshot.MinuteWindowLabel.String() + "总使用次数", "自动禁用普号数"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("输出中缺少 %q", want)
+		}
+	}
+	for _, view := range snapshot.Channels {
+		if !strings.Contains(out, "#"+strconv.Itoa(view.ID)) {
+			t.Errorf("输出中缺少渠道 %d", view.ID)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// 页面主题，auto 跟随浏览器（操作系统）的深色模式设置
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+	ThemeAuto  = "auto"
+)
+
+// 使用率的级别，对应页面上 level-ok 等 CSS 类
+const (
+	LevelOK       = "ok"
+	LevelWarn     = "warn"
+	LevelCritical = "critical"
+)
+
+// UsageThresholds 使用率颜色的阈值（百分比）：超过 Critical 为红色，超过 Warn 为橙色
+type UsageThresholds struct {
+	Warn     float64
+	Critical float64
+}
+
+// Level 返回使用率所在的级别
+func (t UsageThresholds) Level(percentage float64) string {
+	switch {
+	case percentage > t.Critical:
+		return LevelCritical
+	case percentage > t.Warn:
+		return LevelWarn
+	default:
+		return LevelOK
+	}
+}
+
+// validate 检查阈值是否满足 0 <= Warn < Critical <= 100
+func (t UsageThresholds) validate() error {
+	if t.Warn < 0 || t.Warn >= t.Critical || t.Critical > 100 {
+		return fmt.Errorf("需要满足 0 <= USAGE_WARN_PERCENT (%g) < USAGE_CRITICAL_PERCENT (%g) <= 100", t.Warn, t.Critical)
+	}
+	return nil
+}
+
+// UIConfig 页面外观相关的配置
+type UIConfig struct {
+	Thresholds    UsageThresholds
+	Theme         string        // 默认主题，用户可以在页面上切换
+	KioskInterval time.Duration // 大屏模式下切换分组或翻页的间隔
+}
+
+// defaultUIConfig 未配置时的外观，与之前写死在模板中的阈值相同
+func defaultUIConfig() UIConfig {
+	return UIConfig{
+		Thresholds:    UsageThresholds{Warn: 50, Critical: 80},
+		Theme:         ThemeLight,
+		KioskInterval: 20 * time.Second,
+	}
+}
+
+// KioskSeconds 返回大屏模式的切换间隔（秒），供页面脚本使用
+func (c UIConfig) KioskSeconds() int {
+	return int(c.KioskInterval / time.Second)
+}
+
+// validTheme 判断是否为支持的主题
+func validTheme(theme string) bool {
+	return theme == ThemeLight || theme == ThemeDark || theme == ThemeAuto
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestUsageThresholdsLevel(t *testing.T) {
+	thresholds := UsageThresholds{Warn: 50, Critical: 80}
+	tests := []struct {
+		percentage float64
+		want       string
+	}{
+		{0, LevelOK},
+		{50, LevelOK},
+		{50.1, LevelWarn},
+		{80, LevelWarn},
+		{80.1, LevelCritical},
+		{120, LevelCritical},
+	}
+	for _, tt := range tests {
+		if got := thresholds.Level(tt.percentage); got != tt.want {
+			t.Errorf("Level(%g) = %s, want %s", tt.percentage, got, tt.want)
+		}
+	}
+}
+
+func TestUsageThresholdsValidate(t *testing.T) {
+	tests := []struct {
+		thresholds UsageThresholds
+		wantErr    bool
+	}{
+		{UsageThresholds{Warn: 50, Critical: 80}, false},
+		{UsageThresholds{Warn: 0, Critical: 100}, false},
+		{UsageThresholds{Warn: 80, Critical: 80}, true},
+		{UsageThresholds{Warn: 90, Critical: 80}, true},
+		{UsageThresholds{Warn: -1, Critical: 80}, true},
+		{UsageThresholds{Warn: 50, Critical: 101}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.thresholds.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v: err = %v, wantErr %v", tt.thresholds, err, tt.wantErr)
+		}
+	}
+}
@@ -1,6 +1,27 @@
+-- 本文件是模板，由 `gemini-monitor install-procedure` 按配置的限制渲染后安装，
+-- 也可以用 `gemini-monitor install-procedure --print` 输出渲染后的 SQL 再手动导入
+
 -- 定义分隔符，因为存储过程内部有分号
 DELIMITER //
 
+-- 冷却状态表：记录每个渠道因超限被禁用后的解禁时间，用于避免渠道反复启用/禁用
+CREATE TABLE IF NOT EXISTS channel_cooldowns (
+    channel_id INT NOT NULL PRIMARY KEY,
+    -- 触发冷却的原因：'minute' 表示分钟超限，'day' 表示天超限，'budget' 表示付费号超出花费预算
+    reason VARCHAR(16) NOT NULL,
+    -- 解禁时间（Unix 时间戳），在此之前渠道保持禁用
+    disabled_until BIGINT NOT NULL,
+    -- 最近一次写入时间（Unix 时间戳）
+    updated_at BIGINT NOT NULL
+) //
+
+-- 心跳表：存储过程（或 serve --enforce）每次写回计数后更新，监控据此判断计数是否停止更新
+CREATE TABLE IF NOT EXISTS channel_stats_heartbeat (
+    id TINYINT NOT NULL PRIMARY KEY,
+    -- 最近一次写回计数的时间（Unix 时间戳），与计数使用同一个时刻
+    updated_at BIGINT NOT NULL
+) //
+
 -- 如果存在同名存储过程，则先删除
 DROP PROCEDURE IF EXISTS UpdateChannelStats;
 
@@ -9,60 +30,108 @@ DROP PROCEDURE IF EXISTS UpdateChannelStats;
 -- 创建存储过程，用于更新 channels 表的使用情况和状态
 CREATE PROCEDURE UpdateChannelStats()
 BEGIN
-    -- 定义变量，存储1分钟前和今天早上8点的时间戳
+    -- 定义变量，存储当前时间、分钟窗口起点、天窗口起点和下次重置的时间戳
+    DECLARE now_ts BIGINT;
     DECLARE minute_ago BIGINT;
-    DECLARE day_start_8am BIGINT;
+    DECLARE day_start BIGINT;
+    DECLARE next_reset BIGINT;
+    -- 按监控的 TIMEZONE 表示的当前时刻，用于对齐天窗口
+    DECLARE local_now DATETIME;
+    -- 分钟超限后保持禁用的时长（秒）
+    DECLARE minute_cooldown_seconds INT DEFAULT {{.MinuteCooldownSeconds}};
 
-    -- 计算时间戳
-    SET minute_ago = UNIX_TIMESTAMP(NOW() - INTERVAL 1 MINUTE);
-    SET day_start_8am = UNIX_TIMESTAMP(
+    -- 计算时间戳。UNIX_TIMESTAMP() 与会话时区无关；天窗口按监控的 TIMEZONE 对齐（安装时相对 UTC 偏移 {{.UTCOffsetSeconds}} 秒），
+    -- 不使用 MySQL 会话的时区，否则两者不同时计数与监控按日志的统计对不上
+    SET now_ts = UNIX_TIMESTAMP();
+    SET minute_ago = now_ts - {{.MinuteWindowSeconds}};
+    SET local_now = TIMESTAMP('1970-01-01 00:00:00') + INTERVAL (now_ts + {{.UTCOffsetSeconds}}) SECOND;
+    SET day_start = now_ts - TIMESTAMPDIFF(SECOND,
         CASE
-            -- 如果当前时间是早上8点或之后，则取今天早上8点
-            WHEN TIME(NOW()) >= '08:00:00'
-            THEN TIMESTAMP(DATE(NOW()), '08:00:00')
-            -- 如果当前时间在早上8点之前，则取昨天早上8点
-            ELSE TIMESTAMP(DATE(NOW()) - INTERVAL 1 DAY, '08:00:00')
-        END
-    );
+            -- 如果当前时间已过今天的重置时间，则取今天的重置时间
+            WHEN TIME(local_now) >= '{{.DayReset}}'
+            THEN TIMESTAMP(DATE(local_now), '{{.DayReset}}')
+            -- 否则取昨天的重置时间
+            ELSE TIMESTAMP(DATE(local_now) - INTERVAL 1 DAY, '{{.DayReset}}')
+        END,
+        local_now);
+    -- 天超限的渠道冷却到下一次重置
+    SET next_reset = day_start + 86400;
 
-    -- 更新 channels 表 (移除数据库名前缀，使用当前数据库)
-    UPDATE channels
-    -- 左连接 logs 表，统计每个 channel 的使用次数
-    LEFT JOIN (
+    -- 统计每个 channel 的使用次数，结果在下面多次使用，所以放入临时表
+    DROP TEMPORARY TABLE IF EXISTS tmp_channel_stats;
+    CREATE TEMPORARY TABLE tmp_channel_stats AS
         SELECT
             channel_id,
-            -- 计算过去一分钟的日志数量
+            -- 计算分钟窗口内的日志数量
             SUM(created_at >= minute_ago) AS minute_count,
-            -- 计算从早上8点开始的总日志数量
-            COUNT(*) AS day_count
+            -- 计算从天窗口起点开始的日志数量
+            SUM(created_at >= day_start) AS day_count
         FROM logs -- 移除数据库名前缀
-        -- 只考虑从早上8点开始的日志
-        WHERE created_at >= day_start_8am
-        GROUP BY channel_id
-    ) AS logs_stats ON channels.id = logs_stats.channel_id -- 移除数据库名前缀
+        -- 分钟窗口可能跨过天窗口起点，所以从两者中较早的时间开始统计
+        WHERE created_at >= LEAST(minute_ago, day_start)
+        GROUP BY channel_id;
+
+    -- 为超限的渠道写入或延长冷却时间
+    INSERT INTO channel_cooldowns (channel_id, reason, disabled_until, updated_at)
+    SELECT
+        channels.id,
+        -- 天超限优先，因为它的冷却时间更长
+        CASE
+            WHEN (channels.tag != {{sqlString .PaidTag}} AND logs_stats.day_count >= {{.Normal.Day}})
+              OR (channels.tag = {{sqlString .PaidTag}} AND logs_stats.day_count >= {{.Paid.Day}})
+            THEN 'day'
+            ELSE 'minute'
+        END,
+        CASE
+            WHEN (channels.tag != {{sqlString .PaidTag}} AND logs_stats.day_count >= {{.Normal.Day}})
+              OR (channels.tag = {{sqlString .PaidTag}} AND logs_stats.day_count >= {{.Paid.Day}})
+            THEN next_reset
+            ELSE now_ts + minute_cooldown_seconds
+        END,
+        now_ts
+    FROM channels
+    JOIN tmp_channel_stats AS logs_stats ON channels.id = logs_stats.channel_id
+    -- 普号：分钟使用达到 {{.Normal.Minute}} 次或天使用达到 {{.Normal.Day}} 次
+    WHERE (channels.tag != {{sqlString .PaidTag}} AND (logs_stats.minute_count >= {{.Normal.Minute}} OR logs_stats.day_count >= {{.Normal.Day}}))
+    -- 付费号：分钟使用达到 {{.Paid.Minute}} 次或天使用达到 {{.Paid.Day}} 次
+       OR (channels.tag = {{sqlString .PaidTag}} AND (logs_stats.minute_count >= {{.Paid.Minute}} OR logs_stats.day_count >= {{.Paid.Day}}))
+    -- 已在冷却中的渠道只延长，不缩短（先更新 reason，因为它要和旧的 disabled_until 比较）
+    ON DUPLICATE KEY UPDATE
+        reason = IF(VALUES(disabled_until) >= disabled_until, VALUES(reason), reason),
+        disabled_until = GREATEST(disabled_until, VALUES(disabled_until)),
+        updated_at = VALUES(updated_at);
+
+    -- 清理已经到期的冷却记录
+    DELETE FROM channel_cooldowns WHERE disabled_until <= now_ts;
+
+    -- 更新 channels 表 (移除数据库名前缀，使用当前数据库)
+    UPDATE channels
+    -- 左连接统计结果和冷却记录
+    LEFT JOIN tmp_channel_stats AS logs_stats ON channels.id = logs_stats.channel_id -- 移除数据库名前缀
+    LEFT JOIN channel_cooldowns ON channels.id = channel_cooldowns.channel_id
     -- 设置更新的值
     SET
         -- 更新分钟使用次数，如果 logs_stats 中没有记录则为 0
         channels.count_minute_usage = IFNULL(logs_stats.minute_count, 0), -- 移除数据库名前缀
         -- 更新天使用次数，如果 logs_stats 中没有记录则为 0
         channels.count_day_usage = IFNULL(logs_stats.day_count, 0), -- 移除数据库名前缀
-        -- 更新状态
+        -- 更新状态：仍在冷却中的渠道为 2 (自动禁用)，否则为 1 (可用)
         channels.status = CASE -- 移除数据库名前缀
-            -- 如果是普号 (tag != 'gcp') 且分钟使用超限(>4) 或 天使用超限(>25)
-            WHEN (channels.tag != 'gcp' AND (IFNULL(logs_stats.minute_count, 0) > 4 OR IFNULL(logs_stats.day_count, 0) > 25)) -- 移除数据库名前缀
-            -- 或者 如果是付费号 (tag = 'gcp') 且分钟使用超限(>19) 或 天使用超限(>99)
-            OR (channels.tag = 'gcp' AND (IFNULL(logs_stats.minute_count, 0) > 19 OR IFNULL(logs_stats.day_count, 0) > 99)) -- 移除数据库名前缀
-            -- 则将状态设置为 2 (自动禁用)
-            THEN 2
-            -- 否则将状态设置为 1 (可用)
+            WHEN channel_cooldowns.channel_id IS NOT NULL THEN 2
             ELSE 1
         END,
-        -- 更新权重 (监控应用目前未使用此字段)
+        -- 更新权重：当天剩余次数越多权重越高
         channels.weight = CASE -- 移除数据库名前缀
-            WHEN channels.tag = 'gcp' THEN GREATEST(1, 100 - IFNULL(logs_stats.day_count, 0)) -- 移除数据库名前缀
-            ELSE GREATEST(1, 25 - IFNULL(logs_stats.day_count, 0))
+            WHEN channels.tag = {{sqlString .PaidTag}} THEN GREATEST(1, {{.Paid.Day}} - IFNULL(logs_stats.day_count, 0)) -- 移除数据库名前缀
+            ELSE GREATEST(1, {{.Normal.Day}} - IFNULL(logs_stats.day_count, 0))
         END;
 
+    DROP TEMPORARY TABLE IF EXISTS tmp_channel_stats;
+
+    -- 记录本次更新的时间
+    INSERT INTO channel_stats_heartbeat (id, updated_at) VALUES (1, now_ts)
+    ON DUPLICATE KEY UPDATE updated_at = VALUES(updated_at);
+
 END //
 
 -- 将分隔符改回默认的分号
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 渠道的状态和类型，页面和筛选按这些值判断，显示文本由 statusNames、tierNames 给出
+const (
+	StatusAvailable   = "available"
+	StatusUnavailable = "unavailable" // 自动禁用
+	StatusCooling     = "cooling"     // 自动禁用且仍在冷却期内
+
+	TierPaid   = "paid"
+	TierNormal = "normal"
+)
+
+// statusNames、tierNames 状态和类型的中文显示名，同时是翻译的原文
+var (
+	statusNames = map[string]string{StatusAvailable: "可用", StatusUnavailable: "自动禁用", StatusCooling: "冷却中"}
+	tierNames   = map[string]string{TierPaid: "付费号", TierNormal: "普号"}
+)
+
+// buildSnapshot 根据渠道记录和窗口内的使用量构建面板数据，不访问数据库，便于测试
+func buildSnapshot(channels []Channel, usage map[int]UsageCounts, quota QuotaConfig, nowTime time.Time) *Snapshot {
+	var channelViews []ChannelView
+	now := nowTime.Unix()
+
+	for _, channel := range channels {
+		// 使用量按配置的窗口从 logs 表统计，而不是读取存储过程写入的计数
+		counts := usage[channel.ID]
+		view := ChannelView{
+			ID:               channel.ID,
+			CountMinuteUsage: counts.Minute,
+			CountDayUsage:    counts.Day,
+			Tag:              channel.Tag,
+			Name:             channel.Name,
+			Groups:           splitList(channel.Group),
+			Type:             channel.Type,
+			TypeDisplay:      channelTypeName(channel.Type),
+			Models:           splitList(channel.Models),
+			Priority:         channel.Priority,
+			Weight:           channel.Weight,
+			CreatedAt:        unixTime(channel.CreatedTime, nowTime.Location()),
+			TestedAt:         unixTime(channel.TestTime, nowTime.Location()),
+			ResponseTime:     channel.ResponseTime,
+		}
+		if counts.Day > 0 {
+			view.ErrorRate = float64(counts.DayErrors) / float64(counts.Day) * 100
+		}
+
+		// 根据SQL脚本逻辑调整：status 1 为可用，其他（包括 2）为自动禁用
+		if channel.Status == "1" {
+			view.Status = StatusAvailable
+			view.IsAvailable = true
+		} else {
+			view.Status = StatusUnavailable // 包括 status 2 或其他非 1 的值
+			view.IsAvailable = false
+			// 存储过程写入的冷却记录尚未到期时，显示为冷却中
+			if channel.DisabledUntil.Valid && channel.DisabledUntil.Int64 > now {
+				view.Status = StatusCooling
+				view.IsCoolingDown = true
+				view.CooldownSeconds = channel.DisabledUntil.Int64 - now
+				switch channel.CooldownReason.String {
+				case "day":
+					view.CooldownReason = "天超限"
+				case "budget":
+					view.CooldownReason = "超出预算"
+				default:
+					view.CooldownReason = "分钟超限"
+				}
+			}
+		}
+
+		limits, isPaid := quota.LimitsFor(channel)
+		view.MinuteLimit = limits.Minute
+		view.DayLimit = limits.Day
+		view.IsPaid = isPaid
+		view.Tier = TierNormal
+		if isPaid {
+			view.Tier = TierPaid
+		}
+		view.StatusDisplay = statusNames[view.Status]
+		view.TagDisplay = tierNames[view.Tier]
+
+		if view.MinuteLimit > 0 {
+			view.MinutePercentage = float64(view.CountMinuteUsage) / float64(view.MinuteLimit) * 100
+			if view.MinutePercentage > 100 {
+				view.MinutePercentage = 100
+			}
+		} else {
+			view.MinutePercentage = 0
+		}
+
+		if view.DayLimit > 0 {
+			view.DayPercentage = float64(view.CountDayUsage) / float64(view.DayLimit) * 100
+			if view.DayPercentage > 100 {
+				view.DayPercentage = 100
+			}
+		} else {
+			view.DayPercentage = 0
+		}
+
+		channelViews = append(channelViews, view)
+	}
+
+	sort.SliceStable(channelViews, func(i, j int) bool {
+		if channelViews[i].IsPaid != channelViews[j].IsPaid {
+			return channelViews[i].IsPaid
+		}
+		if channelViews[i].DayPercentage != channelViews[j].DayPercentage {
+			return channelViews[i].DayPercentage < channelViews[j].DayPercentage
+		}
+		return channelViews[i].MinutePercentage < channelViews[j].MinutePercentage
+	})
+
+	return &Snapshot{
+		Channels:          channelViews,
+		Summary:           summarize(channelViews),
+		MinuteWindowLabel: quota.MinuteWindow.Label(nowTime),
+		DayWindowLabel:    quota.DayWindow.Label(nowTime),
+		CollectedAt:       nowTime,
+	}
+}
+
+// summarize 汇总一组渠道的使用情况，用于总使用情况和每个分组的小计
+func summarize(channels []ChannelView) SummaryData {
+	var summary SummaryData
+	availableNormalChannels := 0
+	for _, view := range channels {
+		if !view.IsPaid {
+			summary.TotalNormalChannels++
+			if view.IsAvailable {
+				availableNormalChannels++
+			}
+		}
+		summary.TotalMinuteUsage += view.CountMinuteUsage
+		summary.TotalDayUsage += view.CountDayUsage
+		summary.TotalMinuteLimit += view.MinuteLimit
+		summary.TotalDayLimit += view.DayLimit
+	}
+
+	summary.DisabledNormalChannels = summary.TotalNormalChannels - availableNormalChannels
+	summary.Trend = sumTrends(channels)
+
+	if summary.TotalMinuteLimit > 0 {
+		summary.MinutePercentage = float64(summary.TotalMinuteUsage) / float64(summary.TotalMinuteLimit) * 100
+		if summary.MinutePercentage > 100 {
+			summary.MinutePercentage = 100
+		}
+	}
+	if summary.TotalDayLimit > 0 {
+		summary.DayPercentage = float64(summary.TotalDayUsage) / float64(summary.TotalDayLimit) * 100
+		if summary.DayPercentage > 100 {
+			summary.DayPercentage = 100
+		}
+	}
+
+	if summary.TotalNormalChannels > 0 {
+		summary.DisabledNormalPercentage = float64(summary.DisabledNormalChannels) / float64(summary.TotalNormalChannels) * 100
+	}
+	return summary
+}
+
+// unixTime 把 newapi 中的 Unix 时间戳转换为 loc 时区的时间，0 表示没有记录
+func unixTime(sec int64, loc *time.Location) *time.Time {
+	if sec <= 0 {
+		return nil
+	}
+	t := time.Unix(sec, 0).In(loc)
+	return &t
+}
+
+// SearchText 返回用于搜索的文本：ID、名称、类型、分组、模型和自定义标签，已转为小写
+func (v ChannelView) SearchText() string {
+	fields := []string{strconv.Itoa(v.ID), v.Name, v.TypeDisplay.String(), v.Tag}
+	fields = append(fields, v.Groups...)
+	fields = append(fields, v.Models...)
+	for _, value := range v.Labels {
+		fields = append(fields, value)
+	}
+	fields = slices.DeleteFunc(fields, func(field string) bool { return field == "" })
+	return strings.ToLower(strings.Join(fields, " "))
+}
+
+// MatchesSearch 判断渠道是否匹配搜索词，不区分大小写，空搜索词匹配所有渠道
+func (v ChannelView) MatchesSearch(term string) bool {
+	return strings.Contains(v.SearchText(), strings.ToLower(strings.TrimSpace(term)))
+}
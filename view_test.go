@@ -0,0 +1,207 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	testNow   = time.Date(2026, 10, 18, 9, 30, 0, 0, time.FixedZone("CST", 8*3600))
+	testQuota = QuotaConfig{
+		MinuteWindow:   Window{Kind: WindowRolling, Length: time.Minute},
+		DayWindow:      Window{Kind: WindowFixed, Length: 24 * time.Hour, Offset: 8 * time.Hour},
+		Normal:         TierLimits{Minute: 5, Day: 25},
+		Paid:           TierLimits{Minute: 20, Day: 100},
+		PaidTag:        "gcp",
+		MinuteCooldown: 5 * time.Minute,
+	}
+)
+
+// cooldown 构造 channel_cooldowns 中的一条冷却记录
+func cooldown(reason string, until time.Time) (sql.NullString, sql.NullInt64) {
+	return sql.NullString{String: reason, Valid: true}, sql.NullInt64{Int64: until.Unix(), Valid: true}
+}
+
+func TestBuildSnapshotChannelView(t *testing.T) {
+	minuteReason, minuteUntil := cooldown("minute", testNow.Add(125*time.Second))
+	dayReason, dayUntil := cooldown("day", testNow.Add(22*time.Hour))
+	expiredReason, expiredUntil := cooldown("minute", testNow.Add(-time.Second))
+
+	tests := []struct {
+		name    string
+		channel Channel
+		usage   UsageCounts
+		want    ChannelView
+	}{
+		{
+			name:    "available normal channel",
+			channel: Channel{ID: 1, Status: "1", Tag: ""},
+			usage:   UsageCounts{Minute: 2, Day: 10, DayErrors: 2},
+			want: ChannelView{ID: 1, Status: StatusAvailable, StatusDisplay: "可用", Tier: TierNormal, TagDisplay: "普号", TypeDisplay: newMessage("未知"), CountMinuteUsage: 2, CountDayUsage: 10,
+				MinuteLimit: 5, DayLimit: 25, MinutePercentage: 40, DayPercentage: 40, IsAvailable: true, ErrorRate: 20},
+		},
+		{
+			name:    "available paid channel",
+			channel: Channel{ID: 2, Status: "1", Tag: "gcp"},
+			usage:   UsageCounts{Minute: 5, Day: 50},
+			want: ChannelView{ID: 2, Status: StatusAvailable, StatusDisplay: "可用", Tag: "gcp", Tier: TierPaid, TagDisplay: "付费号", TypeDisplay: newMessage("未知"), CountMinuteUsage: 5, CountDayUsage: 50,
+				MinuteLimit: 20, DayLimit: 100, MinutePercentage: 25, DayPercentage: 50, IsPaid: true, IsAvailable: true},
+		},
+		{
+			name:    "percentages are capped at 100",
+			channel: Channel{ID: 3, Status: "2", Tag: "free"},
+			usage:   UsageCounts{Minute: 9, Day: 40},
+			want: ChannelView{ID: 3, Status: StatusUnavailable, StatusDisplay: "自动禁用", Tag: "free", Tier: TierNormal, TagDisplay: "普号", TypeDisplay: newMessage("未知"), CountMinuteUsage: 9, CountDayUsage: 40,
+				MinuteLimit: 5, DayLimit: 25, MinutePercentage: 100, DayPercentage: 100},
+		},
+		{
+			name:    "minute cooldown",
+			channel: Channel{ID: 4, Status: "2", CooldownReason: minuteReason, DisabledUntil: minuteUntil},
+			want: ChannelView{ID: 4, Status: StatusCooling, StatusDisplay: "冷却中", Tier: TierNormal, TagDisplay: "普号", TypeDisplay: newMessage("未知"), MinuteLimit: 5, DayLimit: 25,
+				IsCoolingDown: true, CooldownReason: "分钟超限", CooldownSeconds: 125},
+		},
+		{
+			name:    "day cooldown",
+			channel: Channel{ID: 5, Status: "2", Tag: "gcp", CooldownReason: dayReason, DisabledUntil: dayUntil},
+			usage:   UsageCounts{Day: 100},
+			want: ChannelView{ID: 5, Status: StatusCooling, StatusDisplay: "冷却中", Tag: "gcp", Tier: TierPaid, TagDisplay: "付费号", TypeDisplay: newMessage("未知"), CountDayUsage: 100, MinuteLimit: 20, DayLimit: 100,
+				DayPercentage: 100, IsPaid: true, IsCoolingDown: true, CooldownReason: "天超限", CooldownSeconds: 22 * 3600},
+		},
+		{
+			name:    "expired cooldown is plain disabled",
+			channel: Channel{ID: 6, Status: "2", CooldownReason: expiredReason, DisabledUntil: expiredUntil},
+			want:    ChannelView{ID: 6, Status: StatusUnavailable, StatusDisplay: "自动禁用", Tier: TierNormal, TagDisplay: "普号", TypeDisplay: newMessage("未知"), MinuteLimit: 5, DayLimit: 25},
+		},
+		{
+			name:    "enabled channel ignores leftover cooldown",
+			channel: Channel{ID: 7, Status: "1", CooldownReason: minuteReason, DisabledUntil: minuteUntil},
+			want:    ChannelView{ID: 7, Status: StatusAvailable, StatusDisplay: "可用", Tier: TierNormal, TagDisplay: "普号", TypeDisplay: newMessage("未知"), MinuteLimit: 5, DayLimit: 25, IsAvailable: true},
+		},
+		{
+			name: "newapi metadata",
+			channel: Channel{ID: 8, Status: "1", Name: "gcp-account-1", Group: "default, vip", Type: 24, Models: "gemini-2.5-pro,gemini-2.5-flash",
+				Priority: 10, Weight: 3, CreatedTime: testNow.Add(-48 * time.Hour).Unix(), TestTime: testNow.Add(-time.Hour).Unix(), ResponseTime: 850},
+			want: ChannelView{ID: 8, Status: StatusAvailable, StatusDisplay: "可用", Tier: TierNormal, TagDisplay: "普号", Name: "gcp-account-1", Groups: []string{"default", "vip"},
+				Type: 24, TypeDisplay: newMessage("Gemini"), Models: []string{"gemini-2.5-pro", "gemini-2.5-flash"}, MinuteLimit: 5, DayLimit: 25, IsAvailable: true,
+				Priority: 10, Weight: 3, CreatedAt: timePtr(testNow.Add(-48 * time.Hour)), TestedAt: timePtr(testNow.Add(-time.Hour)), ResponseTime: 850},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage := map[int]UsageCounts{tt.channel.ID: tt.usage}
+			snapshot := buildSnapshot([]Channel{tt.channel}, usage, testQuota, testNow)
+			if len(snapshot.Channels) != 1 {
+				t.Fatalf("got %d channels, want 1", len(snapshot.Channels))
+			}
+			if got := snapshot.Channels[0]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("view mismatch\n got: %+v\nwant: %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSnapshotSummary(t *testing.T) {
+	channels := []Channel{
+		{ID: 1, Status: "1", Tag: "gcp"},
+		{ID: 2, Status: "1"},
+		{ID: 3, Status: "2"},
+		{ID: 4, Status: "2"},
+		{ID: 5, Status: "1"},
+	}
+	usage := map[int]UsageCounts{
+		1: {Minute: 10, Day: 60},
+		2: {Minute: 1, Day: 5},
+		3: {Minute: 5, Day: 25},
+	}
+	got := buildSnapshot(channels, usage, testQuota, testNow).Summary
+
+	want := SummaryData{
+		TotalMinuteUsage:         16,
+		TotalDayUsage:            90,
+		TotalMinuteLimit:         20 + 4*5,
+		TotalDayLimit:            100 + 4*25,
+		MinutePercentage:         40,
+		DayPercentage:            45,
+		DisabledNormalChannels:   2,
+		TotalNormalChannels:      4,
+		DisabledNormalPercentage: 50,
+	}
+	if got != want {
+		t.Errorf("summary mismatch\n got: %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestBuildSnapshotEmpty(t *testing.T) {
+	snapshot := buildSnapshot(nil, nil, testQuota, testNow)
+	if len(snapshot.Channels) != 0 {
+		t.Errorf("got %d channels, want 0", len(snapshot.Channels))
+	}
+	if snapshot.Summary != (SummaryData{}) {
+		t.Errorf("summary = %+v, want zero value", snapshot.Summary)
+	}
+	if snapshot.MinuteWindowLabel.String() != "过去1分钟" || snapshot.DayWindowLabel.String() != "今日（每天 08:00 重置）" {
+		t.Errorf("labels = %q, %q", snapshot.MinuteWindowLabel, snapshot.DayWindowLabel)
+	}
+}
+
+func TestBuildSnapshotSorting(t *testing.T) {
+	// 付费号在前，然后按天使用率、分钟使用率升序，相同时保持原有顺序
+	channels := []Channel{
+		{ID: 1, Status: "1"},
+		{ID: 2, Status: "1", Tag: "gcp"},
+		{ID: 3, Status: "1"},
+		{ID: 4, Status: "1", Tag: "gcp"},
+		{ID: 5, Status: "1"},
+		{ID: 6, Status: "1"},
+	}
+	usage := map[int]UsageCounts{
+		1: {Minute: 1, Day: 10},
+		2: {Minute: 0, Day: 50},
+		3: {Minute: 3, Day: 5},
+		4: {Minute: 0, Day: 10},
+		5: {Minute: 1, Day: 5},
+		6: {Minute: 1, Day: 5},
+	}
+	snapshot := buildSnapshot(channels, usage, testQuota, testNow)
+
+	want := []int{4, 2, 5, 6, 3, 1}
+	var got []int
+	for _, view := range snapshot.Channels {
+		got = append(got, view.ID)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("order = %v, want %v", got, want)
+		}
+	}
+}
+
+func timePtr(t time.Time) *time.Time { return &t }
+
+func TestChannelViewMatchesSearch(t *testing.T) {
+	view := ChannelView{ID: 12, Name: "GCP-Account-3", TypeDisplay: newMessage("Vertex AI"), Groups: []string{"vip"},
+		Models: []string{"gemini-2.5-pro"}, Labels: map[string]string{"owner": "张三"}}
+	tests := []struct {
+		term string
+		want bool
+	}{
+		{"", true},
+		{"12", true},
+		{"account-3", true},
+		{"vertex", true},
+		{"VIP", true},
+		{"2.5-pro", true},
+		{"张三", true},
+		{"flash", false},
+	}
+	for _, tt := range tests {
+		if got := view.MatchesSearch(tt.term); got != tt.want {
+			t.Errorf("MatchesSearch(%q) = %v, want %v", tt.term, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+document.addEventListener('DOMContentLoaded', function() {
+    const groups = document.querySelectorAll('.channel-group');
+
+    // 筛选、排序和分组在服务端完成，下拉框修改地址中对应的参数并回到第一页
+    document.querySelectorAll('select[data-param]').forEach(select => {
+        select.addEventListener('change', function() {
+            const url = new URL(location.href);
+            url.searchParams.set(this.getAttribute('data-param'), this.value);
+            url.searchParams.delete('page');
+            location.href = url.toString();
+        });
+    });
+
+    // 大屏模式：依次显示每个分组，轮播一遍后刷新；不分组时按间隔翻页，最后一页之后回到第一页
+    const kiosk = document.body.classList.contains('kiosk');
+    if (kiosk) {
+        const interval = parseInt(document.body.getAttribute('data-kiosk-interval'), 10) * 1000;
+        document.addEventListener('keydown', function(e) {
+            if (e.key === 'Escape') {
+                location.href = document.body.getAttribute('data-kiosk-exit');
+            }
+        });
+        if (groups.length > 1) {
+            let current = 0;
+            const show = function(index) {
+                groups.forEach((group, i) => {
+                    group.hidden = i !== index;
+                    group.open = true;
+                });
+            };
+            show(current);
+            setInterval(function() {
+                current++;
+                if (current >= groups.length) {
+                    location.reload();
+                    return;
+                }
+                show(current);
+            }, interval);
+        } else {
+            setTimeout(function() {
+                location.href = document.body.getAttribute('data-kiosk-next');
+            }, interval);
+        }
+    }
+
+    // 记住折叠的分组，自动刷新后保持
+    const collapsedKey = 'collapsedGroups:' + document.querySelector('select[data-param="group_by"]').value;
+    const collapsed = new Set(JSON.parse(localStorage.getItem(collapsedKey) || '[]'));
+    groups.forEach(group => {
+        const name = group.getAttribute('data-group');
+        if (collapsed.has(name) && !kiosk) {
+            group.open = false;
+        }
+        group.addEventListener('toggle', function() {
+            if (group.open) {
+                collapsed.delete(name);
+            } else {
+                collapsed.add(name);
+            }
+            localStorage.setItem(collapsedKey, JSON.stringify(Array.from(collapsed)));
+        });
+    });
+
+    // 表格视图的多选和批量操作
+    const bulkForm = document.getElementById('bulkForm');
+    function selectedIDs() {
+        const ids = new Set();
+        document.querySelectorAll('.row-select:checked').forEach(box => ids.add(box.value));
+        return Array.from(ids);
+    }
+    function updateSelection() {
+        const count = selectedIDs().length;
+        document.getElementById('selectedCount').textContent = count;
+        bulkForm.querySelectorAll('.bulk-action').forEach(button => { button.disabled = count === 0; });
+    }
+    if (bulkForm) {
+        document.querySelectorAll('.channel-table').forEach(table => {
+            const boxes = table.querySelectorAll('.row-select');
+            table.querySelector('.select-all').addEventListener('change', function() {
+                boxes.forEach(box => { box.checked = this.checked; });
+                updateSelection();
+            });
+            boxes.forEach(box => box.addEventListener('change', updateSelection));
+        });
+        bulkForm.querySelector('[data-action="copy"]').addEventListener('click', function() {
+            navigator.clipboard.writeText(selectedIDs().join(','));
+        });
+        bulkForm.querySelector('[data-action="csv"]').addEventListener('click', function() {
+            // 导出勾选的行中显示的内容，分组时同一渠道只导出一次
+            const table = document.querySelector('.channel-table');
+            const header = Array.from(table.querySelectorAll('thead th')).slice(1).map(th => th.textContent.trim());
+            const seen = new Set();
+            const rows = [header];
+            document.querySelectorAll('.row-select:checked').forEach(box => {
+                if (seen.has(box.value)) return;
+                seen.add(box.value);
+                const cells = Array.from(box.closest('tr').children).slice(1);
+                rows.push(cells.map(td => td.textContent.trim().replace(/\s+/g, ' ')));
+            });
+            const csv = rows.map(row => row.map(cell => '"' + cell.replace(/"/g, '""') + '"').join(',')).join('\n');
+            const link = document.createElement('a');
+            link.href = URL.createObjectURL(new Blob(['\ufeff' + csv], {type: 'text/csv'}));
+            link.download = 'channels.csv';
+            link.click();
+            URL.revokeObjectURL(link.href);
+        });
+    }
+
+    // Cooldown countdown
+    // 与服务端的 formatRemaining 及其翻译保持一致
+    const english = document.documentElement.lang === 'en';
+    function formatRemaining(seconds) {
+        const h = Math.floor(seconds / 3600);
+        const m = Math.floor(seconds / 60) % 60;
+        const s = seconds % 60;
+        if (english) {
+            if (h > 0) return h + 'h ' + m + 'm';
+            if (m > 0) return m + 'm ' + s + 's';
+            return s + ' s';
+        }
+        if (h > 0) return h + '小时' + m + '分';
+        if (m > 0) return m + '分' + s + '秒';
+        return s + '秒';
+    }
+    const cooldowns = document.querySelectorAll('.cooldown-remaining');
+    const loadedAt = Date.now();
+    setInterval(function() {
+        const elapsed = Math.floor((Date.now() - loadedAt) / 1000);
+        cooldowns.forEach(el => {
+            const left = Math.max(0, parseInt(el.getAttribute('data-seconds'), 10) - elapsed);
+            el.textContent = formatRemaining(left);
+        });
+    }, 1000);
+
+    // Auto-refresh，勾选了渠道时推迟刷新，避免丢失选择
+    setInterval(function() {
+        if (!kiosk && document.querySelectorAll('.row-select:checked').length === 0) {
+            location.reload();
+        }
+    }, 60000); // Refresh every 60 seconds
+});
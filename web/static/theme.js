@@ -0,0 +1,25 @@
+// 主题在页面渲染前应用，避免闪烁。默认主题来自服务端配置（html 的 data-theme），
+// 用户的选择保存在 localStorage 中；大屏等不便点击的场景可以使用 ?theme=dark 指定
+(function() {
+    const themes = ['light', 'dark', 'auto'];
+    const root = document.documentElement;
+    const param = new URLSearchParams(location.search).get('theme');
+    if (themes.includes(param)) {
+        localStorage.setItem('theme', param);
+    }
+    const saved = localStorage.getItem('theme');
+    if (themes.includes(saved)) {
+        root.setAttribute('data-theme', saved);
+    }
+
+    document.addEventListener('DOMContentLoaded', function() {
+        const toggle = document.querySelector('.theme-toggle');
+        if (!toggle) return;
+        toggle.addEventListener('click', function() {
+            const current = themes.indexOf(root.getAttribute('data-theme'));
+            const next = themes[(current + 1) % themes.length];
+            root.setAttribute('data-theme', next);
+            localStorage.setItem('theme', next);
+        });
+    });
+})();
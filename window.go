@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WindowKind 表示配额窗口的计数方式
+type WindowKind int
+
+const (
+	// WindowRolling 滚动窗口：统计当前时刻往前 Length 时长内的请求
+	WindowRolling WindowKind = iota
+	// WindowFixed 固定窗口：按自然周期对齐，每到周期边界清零（例如每天 08:00）
+	WindowFixed
+)
+
+// Window 描述一个配额限制的统计窗口
+type Window struct {
+	Kind   WindowKind
+	Length time.Duration // 滚动窗口的长度，或固定窗口的周期
+	Offset time.Duration // 固定窗口相对于当天 00:00 的对齐偏移
+}
+
+// parseWindow 解析窗口配置，支持以下格式：
+//
+//	rolling:60s        过去 60 秒
+//	rolling:5m         过去 5 分钟
+//	fixed:1m           自然分钟
+//	fixed:24h@08:00    每天 08:00 重置
+func parseWindow(spec string) (Window, error) {
+	kind, rest, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok {
+		return Window{}, fmt.Errorf("窗口配置 %q 缺少类型前缀（rolling: 或 fixed:）", spec)
+	}
+
+	var w Window
+	switch kind {
+	case "rolling":
+		w.Kind = WindowRolling
+	case "fixed":
+		w.Kind = WindowFixed
+		if length, offset, hasOffset := strings.Cut(rest, "@"); hasOffset {
+			rest = length
+			at, err := parseClock(offset)
+			if err != nil {
+				return Window{}, fmt.Errorf("窗口配置 %q 的对齐时间无效: %w", spec, err)
+			}
+			w.Offset = at
+		}
+	default:
+		return Window{}, fmt.Errorf("未知的窗口类型 %q", kind)
+	}
+
+	length, err := time.ParseDuration(rest)
+	if err != nil {
+		return Window{}, fmt.Errorf("窗口配置 %q 的时长无效: %w", spec, err)
+	}
+	if length <= 0 {
+		return Window{}, fmt.Errorf("窗口配置 %q 的时长必须大于 0", spec)
+	}
+	w.Length = length
+
+	if w.Kind == WindowFixed {
+		// 固定窗口需要能整除一天，否则每天的边界会漂移
+		if length > 24*time.Hour || (24*time.Hour)%length != 0 {
+			return Window{}, fmt.Errorf("固定窗口 %q 的周期必须能整除 24h", spec)
+		}
+		// 日志的时间戳精确到秒，更短的周期没有意义
+		if length < time.Second || length%time.Second != 0 {
+			return Window{}, fmt.Errorf("固定窗口 %q 的周期必须是整数秒", spec)
+		}
+		if w.Offset >= length {
+			w.Offset %= length
+		}
+	}
+	return w, nil
+}
+
+// parseClock 解析 HH:MM 或 HH:MM:SS 形式的时刻，返回相对于 00:00 的偏移
+func parseClock(s string) (time.Duration, error) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Duration(t.Hour())*time.Hour +
+				time.Duration(t.Minute())*time.Minute +
+				time.Duration(t.Second())*time.Second, nil
+		}
+	}
+	return 0, fmt.Errorf("无法解析时刻 %q", s)
+}
+
+// Start 返回在 now 时刻该窗口的起点，统计时包含起点
+func (w Window) Start(now time.Time) time.Time {
+	if w.Kind == WindowRolling {
+		return now.Add(-w.Length)
+	}
+
+	// 以当天 00:00（按 now 所在时区）加上偏移作为对齐基准
+	y, m, d := now.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, now.Location()).Add(w.Offset)
+	if start.After(now) {
+		start = start.Add(-24 * time.Hour)
+	}
+	return start.Add(now.Sub(start) / w.Length * w.Length)
+}
+
+// String 返回与配置格式相同的窗口描述
+func (w Window) String() string {
+	if w.Kind == WindowRolling {
+		return "rolling:" + w.Length.String()
+	}
+	s := "fixed:" + w.Length.String()
+	if w.Offset > 0 {
+		s += fmt.Sprintf("@%02d:%02d", int(w.Offset.Hours()), int(w.Offset.Minutes())%60)
+		if seconds := int(w.Offset.Seconds()) % 60; seconds > 0 {
+			s += fmt.Sprintf(":%02d", seconds)
+		}
+	}
+	return s
+}
+
+// Label 返回用于卡片标签的窗口描述
+func (w Window) Label(now time.Time) Message {
+	if w.Kind == WindowRolling {
+		return newMessage("过去%s", formatWindowLength(w.Length))
+	}
+
+	start := w.Start(now)
+	switch w.Length {
+	case 24 * time.Hour:
+		return newMessage("今日（每天 %s 重置）", start.Format("15:04"))
+	case time.Hour:
+		return newMessage("本小时（自 %s 起）", start.Format("15:04"))
+	case time.Minute:
+		return newMessage("本分钟（自 %s 起）", start.Format("15:04"))
+	default:
+		return newMessage("本周期（自 %s 起，每%s重置）", start.Format("15:04"), formatWindowLength(w.Length))
+	}
+}
+
+// formatWindowLength 将窗口时长格式化为最大的整数单位，例如 1分钟、24小时、90秒
+func formatWindowLength(d time.Duration) Message {
+	switch {
+	case d%time.Hour == 0:
+		return newMessage("%d小时", int(d/time.Hour))
+	case d%time.Minute == 0:
+		return newMessage("%d分钟", int(d/time.Minute))
+	default:
+		return newMessage("%d秒", int(d/time.Second))
+	}
+}
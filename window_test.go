@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Window
+		wantErr bool
+	}{
+		{spec: "rolling:60s", want: Window{Kind: WindowRolling, Length: time.Minute}},
+		{spec: " rolling:5m ", want: Window{Kind: WindowRolling, Length: 5 * time.Minute}},
+		{spec: "fixed:1m", want: Window{Kind: WindowFixed, Length: time.Minute}},
+		{spec: "fixed:24h@08:00", want: Window{Kind: WindowFixed, Length: 24 * time.Hour, Offset: 8 * time.Hour}},
+		{spec: "fixed:1h@00:30", want: Window{Kind: WindowFixed, Length: time.Hour, Offset: 30 * time.Minute}},
+		{spec: "fixed:1h@08:15", want: Window{Kind: WindowFixed, Length: time.Hour, Offset: 15 * time.Minute}},
+		{spec: "60s", wantErr: true},
+		{spec: "sliding:60s", wantErr: true},
+		{spec: "rolling:abc", wantErr: true},
+		{spec: "rolling:0s", wantErr: true},
+		{spec: "fixed:7h", wantErr: true},
+		{spec: "fixed:48h", wantErr: true},
+		{spec: "fixed:24h@25:00", wantErr: true},
+		{spec: "fixed:1ms", wantErr: true},
+		{spec: "fixed:1ns", wantErr: true},
+		{spec: "fixed:1500ms", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseWindow(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseWindow(%q) = %+v, want error", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseWindow(%q) error: %v", tt.spec, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseWindow(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowStartAndLabel(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	at := func(day, hour, min, sec int) time.Time {
+		return time.Date(2026, 10, day, hour, min, sec, 0, loc)
+	}
+	tests := []struct {
+		name      string
+		spec      string
+		now       time.Time
+		wantStart time.Time
+		wantLabel string
+	}{
+		{"rolling minute", "rolling:60s", at(18, 7, 30, 15), at(18, 7, 29, 15), "过去1分钟"},
+		{"rolling odd length", "rolling:90s", at(18, 7, 30, 15), at(18, 7, 28, 45), "过去90秒"},
+		{"fixed day before reset", "fixed:24h@08:00", at(18, 7, 59, 59), at(17, 8, 0, 0), "今日（每天 08:00 重置）"},
+		{"fixed day at reset", "fixed:24h@08:00", at(18, 8, 0, 0), at(18, 8, 0, 0), "今日（每天 08:00 重置）"},
+		{"fixed minute", "fixed:1m", at(18, 7, 30, 15), at(18, 7, 30, 0), "本分钟（自 07:30 起）"},
+		{"fixed hour with offset", "fixed:1h@00:30", at(18, 7, 10, 0), at(18, 6, 30, 0), "本小时（自 06:30 起）"},
+		{"fixed six hours", "fixed:6h@02:00", at(18, 1, 0, 0), at(17, 20, 0, 0), "本周期（自 20:00 起，每6小时重置）"},
+		{"fixed second", "fixed:1s", at(18, 23, 59, 59), at(18, 23, 59, 59), "本周期（自 23:59 起，每1秒重置）"},
+		{"fixed day before midnight", "fixed:24h", at(18, 23, 59, 59), at(18, 0, 0, 0), "今日（每天 00:00 重置）"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := parseWindow(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := w.Start(tt.now); !got.Equal(tt.wantStart) {
+				t.Errorf("Start(%v) = %v, want %v", tt.now, got, tt.wantStart)
+			}
+			if got := w.Label(tt.now).String(); got != tt.wantLabel {
+				t.Errorf("Label(%v) = %q, want %q", tt.now, got, tt.wantLabel)
+			}
+		})
+	}
+}
+
+// TestWindowStringRoundTrip 确保 String 的输出可以解析回同一个窗口
+func TestWindowStringRoundTrip(t *testing.T) {
+	for _, spec := range []string{"rolling:1m0s", "fixed:1m0s", "fixed:24h0m0s@08:00", "fixed:24h0m0s@08:00:30", "fixed:1h0m0s@00:00:15"} {
+		w, err := parseWindow(spec)
+		if err != nil {
+			t.Fatalf("parseWindow(%q) error: %v", spec, err)
+		}
+		if got := w.String(); got != spec {
+			t.Errorf("String() = %q, want %q", got, spec)
+		}
+		if again, err := parseWindow(w.String()); err != nil || again != w {
+			t.Errorf("parseWindow(%q) = %+v, %v, want %+v", w.String(), again, err, w)
+		}
+	}
+}